@@ -21,6 +21,10 @@ type ServerConfig struct {
 	Environment  string
 	AllowOrigins []string
 	TimeZone     *time.Location
+
+	// Domain calendar event UIDs end in. It must not change, or calendar
+	// apps show every event twice.
+	CalendarDomain string
 }
 
 // DatabaseConfig holds database-related settings
@@ -45,6 +49,28 @@ type BookingConfig struct {
 	ClosingHour      int
 	SlotDuration     time.Duration
 	CancellationTime time.Duration
+
+	// Recurring series may reach further ahead than one-off bookings
+	SeriesMaxDaysAhead int
+
+	// Check-in window around a booking's start time
+	CheckInOpensBefore time.Duration
+	CheckInGracePeriod time.Duration
+
+	// How long a waitlisted player has to claim a freed slot
+	WaitlistHoldDuration time.Duration
+
+	// How long a slot is held while a player confirms a booking
+	SlotHoldDuration time.Duration
+
+	// How long before a booking or training session players are reminded
+	ReminderLeadTime         time.Duration
+	TrainingReminderLeadTime time.Duration
+
+	// Limits applied to training bookings made by coaches
+	TrainingMaxDaysAhead    int
+	TrainingMinHoursAdvance int
+	TrainingMaxHoursPerWeek int
 }
 
 // EmailConfig holds email-related settings
@@ -74,6 +100,8 @@ func Load() *Config {
 			Environment:  getEnv("ENV", "development"),
 			AllowOrigins: []string{"http://localhost:8000"},
 			TimeZone:     timezone,
+
+			CalendarDomain: getEnv("CALENDAR_DOMAIN", "picklecourt.com"),
 		},
 		Database: DatabaseConfig{
 			Path: getEnv("DB_PATH", "./pickleball.db"),
@@ -90,8 +118,23 @@ func Load() *Config {
 			MaxHoursPerWeek:  getEnvAsInt("BOOKING_MAX_HOURS_PER_WEEK", 10),
 			OpeningHour:      getEnvAsInt("OPENING_HOUR", 6),  // 6 AM
 			ClosingHour:      getEnvAsInt("CLOSING_HOUR", 22), // 10 PM
-			SlotDuration:     time.Hour,                        // 1 hour slots
-			CancellationTime: time.Hour * 24,                   // 24 hours notice required
+			SlotDuration:     time.Duration(getEnvAsInt("BOOKING_SLOT_MINUTES", 60)) * time.Minute,
+			CancellationTime: time.Hour * 24, // 24 hours notice required
+
+			SeriesMaxDaysAhead: getEnvAsInt("SERIES_MAX_DAYS_AHEAD", 180),
+
+			CheckInOpensBefore: time.Duration(getEnvAsInt("CHECKIN_OPENS_MINUTES", 30)) * time.Minute,
+			CheckInGracePeriod: time.Duration(getEnvAsInt("CHECKIN_GRACE_MINUTES", 15)) * time.Minute,
+
+			WaitlistHoldDuration: time.Duration(getEnvAsInt("WAITLIST_HOLD_MINUTES", 15)) * time.Minute,
+			SlotHoldDuration:     time.Duration(getEnvAsInt("SLOT_HOLD_MINUTES", 10)) * time.Minute,
+
+			ReminderLeadTime:         time.Duration(getEnvAsInt("REMINDER_LEAD_MINUTES", 24*60)) * time.Minute,
+			TrainingReminderLeadTime: time.Duration(getEnvAsInt("TRAINING_REMINDER_LEAD_MINUTES", 24*60)) * time.Minute,
+
+			TrainingMaxDaysAhead:    getEnvAsInt("TRAINING_MAX_DAYS_AHEAD", 90),
+			TrainingMinHoursAdvance: getEnvAsInt("TRAINING_MIN_HOURS_ADVANCE", 1),
+			TrainingMaxHoursPerWeek: getEnvAsInt("TRAINING_MAX_HOURS_PER_WEEK", 0), // 0 means unlimited
 		},
 		Email: EmailConfig{
 			Enabled:  getEnvAsBool("EMAIL_ENABLED", false),
@@ -175,6 +218,36 @@ func (c *Config) GetMaxBookingHoursPerWeek() int {
 	return c.Booking.MaxHoursPerWeek
 }
 
+// GetSlotDuration returns the length of a single booking slot
+func (c *Config) GetSlotDuration() time.Duration {
+	if c.Booking.SlotDuration <= 0 {
+		return time.Hour
+	}
+	return c.Booking.SlotDuration
+}
+
+// GetCheckInWindow returns how long before and after a booking's start time
+// players can check in
+func (c *Config) GetCheckInWindow() (opensBefore, gracePeriod time.Duration) {
+	return c.Booking.CheckInOpensBefore, c.Booking.CheckInGracePeriod
+}
+
+// GetWaitlistHoldDuration returns how long a waitlist offer is held
+func (c *Config) GetWaitlistHoldDuration() time.Duration {
+	return c.Booking.WaitlistHoldDuration
+}
+
+// GetSlotHoldDuration returns how long a slot is held during checkout
+func (c *Config) GetSlotHoldDuration() time.Duration {
+	return c.Booking.SlotHoldDuration
+}
+
+// GetReminderLeadTimes returns how long before a booking and before a
+// training session players are reminded of it
+func (c *Config) GetReminderLeadTimes() (booking, training time.Duration) {
+	return c.Booking.ReminderLeadTime, c.Booking.TrainingReminderLeadTime
+}
+
 // GetCancellationNoticeRequired returns the required notice period for cancellations
 func (c *Config) GetCancellationNoticeRequired() time.Duration {
 	return c.Booking.CancellationTime
@@ -0,0 +1,238 @@
+// Package api is the versioned JSON API under /api/v1, for the mobile app
+// and other clients. Requests and responses use the explicit types in this
+// package rather than the models, so the API only changes on purpose.
+//
+// A single resource is returned as {"data": ...} and a listing as
+// {"data": [...], "pagination": {...}}. Listings take page and per_page
+// query parameters. Every error is returned as
+// {"error": {"code": ..., "message": ..., "fields": {...}}}, where fields
+// names the request fields that failed validation.
+package api
+
+import (
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"reflect"
+	"strconv"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Error codes
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthenticated  = "unauthenticated"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodePolicyViolation  = "policy_violation"
+	CodeInternal         = "internal_error"
+)
+
+// Error is the body of every error response
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	// Rule names the booking policy rule that was broken
+	Rule string `json:"rule,omitempty"`
+}
+
+// Pagination describes the page of a listing that was returned
+type Pagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Validation errors name fields by their JSON name, as clients know them
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// AuthRequired rejects requests without a signed-in user, answering with
+// an error rather than redirecting to the login page
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.GetCurrentUser(c) == nil {
+			fail(c, http.StatusUnauthorized, CodeUnauthenticated, "Sign in to use the API")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// NotFoundHandler answers unknown API routes with an error envelope
+func NotFoundHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fail(c, http.StatusNotFound, CodeNotFound, "No such endpoint")
+	}
+}
+
+// respond writes a single resource
+func respond(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, gin.H{"data": data})
+}
+
+// respondList writes one page of a listing
+func respondList(c *gin.Context, data interface{}, page models.Page, total int) {
+	perPage := page.Limit
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"pagination": Pagination{
+			Page:       page.Offset/perPage + 1,
+			PerPage:    perPage,
+			Total:      total,
+			TotalPages: (total + perPage - 1) / perPage,
+		},
+	})
+}
+
+// fail writes an error
+func fail(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"error": Error{Code: code, Message: message}})
+}
+
+// failBinding writes the error from binding a request body, naming each
+// field that failed validation by its JSON name
+func failBinding(c *gin.Context, err error) {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		fail(c, http.StatusBadRequest, CodeBadRequest, "Request body is not valid JSON for this endpoint")
+		return
+	}
+
+	fields := make(map[string]string)
+	for _, fieldErr := range invalid {
+		fields[fieldErr.Field()] = validationMessage(fieldErr)
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": Error{
+		Code:    CodeValidationFailed,
+		Message: "Some fields are missing or invalid",
+		Fields:  fields,
+	}})
+}
+
+// failField writes a validation error for a single field, such as a query
+// parameter
+func failField(c *gin.Context, field, message string) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": Error{
+		Code:    CodeValidationFailed,
+		Message: "Some fields are missing or invalid",
+		Fields:  map[string]string{field: message},
+	}})
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fieldErr.Param()
+	case "max", "lte":
+		return "must be at most " + fieldErr.Param()
+	case "oneof":
+		return "must be one of " + fieldErr.Param()
+	case "email":
+		return "must be an email address"
+	}
+	return "is invalid"
+}
+
+// pageParams reads the page and per_page query parameters, writing an
+// error response if they are malformed
+func pageParams(c *gin.Context) (models.Page, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		failField(c, "page", "must be a positive number")
+		return models.Page{}, false
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		failField(c, "per_page", "must be between 1 and "+strconv.Itoa(maxPerPage))
+		return models.Page{}, false
+	}
+	return models.Page{Limit: perPage, Offset: (page - 1) * perPage}, true
+}
+
+// idParam reads an ID from the URL, writing an error response if it is
+// not a number
+func idParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		fail(c, http.StatusNotFound, CodeNotFound, "Not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// int64Query reads an optional ID from the query string, returning 0 when
+// it is absent and writing an error response when it is malformed
+func int64Query(c *gin.Context, name string) (int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		failField(c, name, "must be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+// boolQuery reads an optional boolean from the query string, returning nil
+// when it is absent and writing an error response when it is malformed
+func boolQuery(c *gin.Context, name string) (*bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		failField(c, name, "must be true or false")
+		return nil, false
+	}
+	return &b, true
+}
+
+// currentClubID returns the ID of the club the request is for
+func currentClubID(c *gin.Context) int64 {
+	if club := middleware.GetCurrentClub(c); club != nil {
+		return club.ID
+	}
+	return 0
+}
+
+// requireRole writes an error response unless the current user has one of
+// the given roles
+func requireRole(c *gin.Context, roles ...string) (*models.User, bool) {
+	user := middleware.GetCurrentUser(c)
+	for _, role := range roles {
+		if user != nil && user.Role == role {
+			return user, true
+		}
+	}
+	fail(c, http.StatusForbidden, CodeForbidden, "You do not have permission to do that")
+	return nil, false
+}
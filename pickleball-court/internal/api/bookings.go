@@ -0,0 +1,231 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/handlers"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// ListBookingsHandler lists bookings, latest first. Players and coaches see
+// their own; admins see every booking they manage and can narrow them to
+// one player with user_id. All can filter by court_id, status and a
+// from/to date range.
+func ListBookingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		page, ok := pageParams(c)
+		if !ok {
+			return
+		}
+
+		search := models.BookingSearch{ClubID: currentClubID(c), Status: c.Query("status"), Page: page}
+		if search.CourtID, ok = int64Query(c, "court_id"); !ok {
+			return
+		}
+		if search.From, search.To, ok = dateRangeParams(c); !ok {
+			return
+		}
+		if user.Role == models.RoleAdmin {
+			search.FacilityID = user.FacilityID
+			if search.UserID, ok = int64Query(c, "user_id"); !ok {
+				return
+			}
+		} else {
+			search.UserID = user.ID
+		}
+
+		bookings, total, err := models.SearchBookings(db, search)
+		if err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load bookings")
+			return
+		}
+
+		data := []Booking{}
+		for _, booking := range bookings {
+			data = append(data, newBooking(booking))
+		}
+		respondList(c, data, page, total)
+	}
+}
+
+// GetBookingHandler returns a booking to its player or an admin who
+// manages its court
+func GetBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := bookingParam(c, db)
+		if !ok {
+			return
+		}
+		respond(c, http.StatusOK, newBooking(booking))
+	}
+}
+
+// CreateBookingHandler books a court for the signed-in player. The booking
+// is checked against the booking policy and starts out pending unless the
+// court or player is set to confirm automatically.
+func CreateBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireRole(c, models.RolePlayer)
+		if !ok {
+			return
+		}
+
+		var req CreateBookingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			failBinding(c, err)
+			return
+		}
+
+		duration := config.Get().GetSlotDuration()
+		if req.DurationMinutes > 0 {
+			duration = time.Duration(req.DurationMinutes) * time.Minute
+		}
+
+		booking := &models.Booking{
+			CourtID:     req.CourtID,
+			UserID:      user.ID,
+			StartTime:   req.StartTime,
+			EndTime:     req.StartTime.Add(duration),
+			Status:      models.BookingStatusPending,
+			BookingType: models.BookingTypeRegular,
+		}
+		if err := models.CreateBooking(db, booking); err != nil {
+			failBooking(c, err, "Failed to create booking")
+			return
+		}
+		notify.BookingCreated(db, booking)
+
+		// Reload for the court and player names
+		if created, err := models.GetBookingByID(db, currentClubID(c), booking.ID); err == nil {
+			booking = created
+		}
+		respond(c, http.StatusCreated, newBooking(booking))
+	}
+}
+
+// CancelBookingHandler cancels a booking. Players cancel their own, subject
+// to the cancellation policy; admins may cancel any booking they manage,
+// with a reason, and are not held to the policy.
+func CancelBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		booking, ok := bookingParam(c, db)
+		if !ok {
+			return
+		}
+
+		var req CancelBookingRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				failBinding(c, err)
+				return
+			}
+		}
+
+		override := user.Role == models.RoleAdmin
+		if override && req.Reason == "" {
+			failField(c, "reason", "is required")
+			return
+		}
+
+		cancellation, err := models.CancelBooking(db, booking.ID, models.CancelOptions{
+			CancelledBy: user.ID,
+			Reason:      req.Reason,
+			Override:    override,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrBookingAlreadyCancelled),
+				errors.Is(err, models.ErrInvalidTransition),
+				errors.Is(err, models.ErrBookingStarted):
+				fail(c, http.StatusConflict, CodeConflict, err.Error())
+			case errors.Is(err, models.ErrLateCancelReasonRequired):
+				failField(c, "reason", err.Error())
+			default:
+				fail(c, http.StatusInternalServerError, CodeInternal, "Failed to cancel booking")
+			}
+			return
+		}
+		notify.BookingCancelled(db, booking, req.Reason, nil)
+		handlers.NotifyWaitlistOffers(db, cancellation.WaitlistOffers)
+
+		booking.Status = models.BookingStatusCancelled
+		respond(c, http.StatusOK, Cancellation{
+			Booking: newBooking(booking),
+			Late:    cancellation.Late,
+			Waived:  cancellation.Waived,
+		})
+	}
+}
+
+// bookingParam loads the booking in the URL, writing an error response
+// unless it belongs to the current user or to a court they administer.
+// Bookings the user may not see are reported as not found.
+func bookingParam(c *gin.Context, db *sql.DB) (*models.Booking, bool) {
+	bookingID, ok := idParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	user := middleware.GetCurrentUser(c)
+	booking, err := models.GetBookingByID(db, currentClubID(c), bookingID)
+	if err != nil ||
+		(booking.UserID != user.ID && !user.ManagesFacility(booking.FacilityID)) {
+		fail(c, http.StatusNotFound, CodeNotFound, "Booking not found")
+		return nil, false
+	}
+	return booking, true
+}
+
+// failBooking writes a booking error, exposing policy violations to the
+// client and hiding everything else behind fallback
+func failBooking(c *gin.Context, err error, fallback string) {
+	var policyErr *models.PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": Error{
+			Code:    CodePolicyViolation,
+			Message: policyErr.Message,
+			Rule:    policyErr.Rule,
+		}})
+	case errors.Is(err, models.ErrCourtUnavailable):
+		fail(c, http.StatusConflict, CodeConflict, "Court is not available for the selected time slot")
+	case errors.Is(err, models.ErrOtherClub):
+		fail(c, http.StatusNotFound, CodeNotFound, "Court not found")
+	default:
+		fail(c, http.StatusInternalServerError, CodeInternal, fallback)
+	}
+}
+
+// dateRangeParams reads optional from and to dates, in the club's
+// timezone, returning the range from the start of from to the end of to.
+// Either end may be left open.
+func dateRangeParams(c *gin.Context) (time.Time, time.Time, bool) {
+	loc := config.Get().GetTimeZone()
+
+	var from, to time.Time
+	if value := c.Query("from"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			failField(c, "from", "must be a date like 2006-01-02")
+			return from, to, false
+		}
+		from = date
+	}
+	if value := c.Query("to"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			failField(c, "to", "must be a date like 2006-01-02")
+			return from, to, false
+		}
+		to = date.AddDate(0, 0, 1)
+	}
+	return from, to, true
+}
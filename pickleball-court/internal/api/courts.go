@@ -0,0 +1,164 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/models"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// ListCourtsHandler lists the club's courts by name, filtered by
+// facility_id, indoor, lighting, accessible, surface and lines
+func ListCourtsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, ok := pageParams(c)
+		if !ok {
+			return
+		}
+		filter, ok := courtFilterParams(c)
+		if !ok {
+			return
+		}
+
+		courts, err := models.GetCourts(db, filter)
+		if err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load courts")
+			return
+		}
+
+		// Clubs have few courts, so they are paged in memory
+		data := []Court{}
+		for i := page.Offset; i < len(courts) && i < page.Offset+page.Limit; i++ {
+			data = append(data, newCourt(courts[i]))
+		}
+		respondList(c, data, page, len(courts))
+	}
+}
+
+// GetCourtHandler returns one of the club's courts
+func GetCourtHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courtID, ok := idParam(c, "id")
+		if !ok {
+			return
+		}
+
+		court, err := models.GetCourtByID(db, currentClubID(c), courtID)
+		if err != nil {
+			fail(c, http.StatusNotFound, CodeNotFound, "Court not found")
+			return
+		}
+
+		respond(c, http.StatusOK, newCourt(court))
+	}
+}
+
+// AvailabilityHandler returns court availability for a date, or a from/to
+// range of up to models.MaxAvailabilityDays days. Courts can be narrowed by
+// court_id, which may be repeated or comma-separated, and by the court
+// list filters. after and before limit each day to a time of day, and
+// duration_minutes sets the length of the slots offered.
+func AvailabilityHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loc := config.Get().GetTimeZone()
+
+		from := c.DefaultQuery("from", c.Query("date"))
+		to := c.DefaultQuery("to", from)
+		if from == "" {
+			failField(c, "date", "is required")
+			return
+		}
+		startDate, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			failField(c, "from", "must be a date like 2006-01-02")
+			return
+		}
+		endDate, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			failField(c, "to", "must be a date like 2006-01-02")
+			return
+		}
+
+		slotDuration := config.Get().GetSlotDuration()
+		duration := slotDuration
+		if value := c.Query("duration_minutes"); value != "" {
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes < 1 || time.Duration(minutes)*time.Minute%slotDuration != 0 {
+				failField(c, "duration_minutes", "must be a multiple of the slot size")
+				return
+			}
+			duration = time.Duration(minutes) * time.Minute
+		}
+
+		var courtIDs []int64
+		for _, param := range c.QueryArray("court_id") {
+			for _, value := range strings.Split(param, ",") {
+				id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+				if err != nil {
+					failField(c, "court_id", "must be a list of numbers")
+					return
+				}
+				courtIDs = append(courtIDs, id)
+			}
+		}
+
+		filter, ok := courtFilterParams(c)
+		if !ok {
+			return
+		}
+
+		courts, err := models.GetCourtAvailability(db, models.AvailabilityRequest{
+			From:       startDate,
+			To:         endDate,
+			After:      c.Query("after"),
+			Before:     c.Query("before"),
+			CourtIDs:   courtIDs,
+			Filter:     filter,
+			SlotLength: duration,
+		}, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidAvailabilityRange) || errors.Is(err, models.ErrInvalidTimeOfDay) {
+				fail(c, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+				return
+			}
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load availability")
+			return
+		}
+
+		data := []CourtAvailability{}
+		for _, court := range courts {
+			data = append(data, newCourtAvailability(court))
+		}
+		respond(c, http.StatusOK, data)
+	}
+}
+
+// courtFilterParams reads the court list filters for the current club from
+// the query string, writing an error response if one is malformed
+func courtFilterParams(c *gin.Context) (models.CourtFilter, bool) {
+	filter := models.CourtFilter{
+		ClubID:  currentClubID(c),
+		Surface: c.Query("surface"),
+		Lines:   c.Query("lines"),
+	}
+
+	var ok bool
+	if filter.FacilityID, ok = int64Query(c, "facility_id"); !ok {
+		return filter, false
+	}
+	if filter.Indoor, ok = boolQuery(c, "indoor"); !ok {
+		return filter, false
+	}
+	if filter.Lighting, ok = boolQuery(c, "lighting"); !ok {
+		return filter, false
+	}
+	if filter.Accessible, ok = boolQuery(c, "accessible"); !ok {
+		return filter, false
+	}
+	return filter, true
+}
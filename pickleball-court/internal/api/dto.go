@@ -0,0 +1,218 @@
+package api
+
+import (
+	"pickleball-court/internal/models"
+	"time"
+)
+
+// Court is a court as clients see it
+type Court struct {
+	ID                int64  `json:"id"`
+	FacilityID        int64  `json:"facility_id"`
+	FacilityName      string `json:"facility_name"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Status            string `json:"status"`
+	MaxBookingMinutes int    `json:"max_booking_minutes"`
+	Indoor            bool   `json:"indoor"`
+	Surface           string `json:"surface"`
+	Lighting          bool   `json:"lighting"`
+	Lines             string `json:"lines"`
+	Accessible        bool   `json:"accessible"`
+}
+
+// Booking is a booking as clients see it
+type Booking struct {
+	ID          int64      `json:"id"`
+	CourtID     int64      `json:"court_id"`
+	CourtName   string     `json:"court_name"`
+	UserID      int64      `json:"user_id"`
+	UserName    string     `json:"user_name"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     time.Time  `json:"end_time"`
+	Status      string     `json:"status"`
+	BookingType string     `json:"booking_type"`
+	SeriesID    int64      `json:"series_id,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateBookingRequest books a court. DurationMinutes defaults to one
+// slot.
+type CreateBookingRequest struct {
+	CourtID         int64     `json:"court_id" binding:"required,min=1"`
+	StartTime       time.Time `json:"start_time" binding:"required"`
+	DurationMinutes int       `json:"duration_minutes" binding:"omitempty,min=1,max=1440"`
+}
+
+// CancelBookingRequest cancels a booking. A reason is needed for late
+// cancellations and when an admin cancels.
+type CancelBookingRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// Cancellation is the outcome of cancelling a booking
+type Cancellation struct {
+	Booking Booking `json:"booking"`
+	Late    bool    `json:"late"`
+	Waived  bool    `json:"waived"`
+}
+
+// Interval is a stretch of time on a court
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Slot is a block of time that could be booked
+type Slot struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Available  bool      `json:"available"`
+	Players    int       `json:"players"`
+	Full       bool      `json:"full"`
+	Joinable   bool      `json:"joinable"`
+	OpenPlayID int64     `json:"open_play_id,omitempty"`
+}
+
+// CourtAvailability is one court's busy and free time and bookable slots.
+// Times are in the court's facility timezone.
+type CourtAvailability struct {
+	Court    Court      `json:"court"`
+	TimeZone string     `json:"timezone"`
+	Busy     []Interval `json:"busy"`
+	Free     []Interval `json:"free"`
+	Slots    []Slot     `json:"slots"`
+}
+
+// TrainingSession is a coached session players can enroll in
+type TrainingSession struct {
+	ID              int64     `json:"id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	CoachID         int64     `json:"coach_id"`
+	CoachName       string    `json:"coach_name"`
+	CourtID         int64     `json:"court_id"`
+	CourtName       string    `json:"court_name"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	MaxParticipants int       `json:"max_participants"`
+	Enrolled        int       `json:"enrolled"`
+}
+
+// User is a club member. Email is only shown to the member and admins.
+type User struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email,omitempty"`
+	Role       string    `json:"role"`
+	SkillLevel float64   `json:"skill_level"`
+	FacilityID int64     `json:"facility_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LoginRequest signs in with a username and password
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func newCourt(court *models.Court) Court {
+	return Court{
+		ID:                court.ID,
+		FacilityID:        court.FacilityID,
+		FacilityName:      court.FacilityName,
+		Name:              court.Name,
+		Description:       court.Description,
+		Status:            court.Status,
+		MaxBookingMinutes: court.MaxBookingMinutes,
+		Indoor:            court.Indoor,
+		Surface:           court.Surface,
+		Lighting:          court.Lighting,
+		Lines:             court.Lines,
+		Accessible:        court.Accessible,
+	}
+}
+
+func newBooking(booking *models.Booking) Booking {
+	b := Booking{
+		ID:          booking.ID,
+		CourtID:     booking.CourtID,
+		CourtName:   booking.CourtName,
+		UserID:      booking.UserID,
+		UserName:    booking.UserName,
+		StartTime:   booking.StartTime,
+		EndTime:     booking.EndTime,
+		Status:      booking.Status,
+		BookingType: booking.BookingType,
+		SeriesID:    booking.SeriesID,
+		CreatedAt:   booking.CreatedAt,
+	}
+	if !booking.CheckedInAt.IsZero() {
+		checkedInAt := booking.CheckedInAt
+		b.CheckedInAt = &checkedInAt
+	}
+	return b
+}
+
+func newCourtAvailability(availability *models.CourtAvailability) CourtAvailability {
+	a := CourtAvailability{
+		Court:    newCourt(availability.Court),
+		TimeZone: availability.Location.String(),
+		Busy:     newIntervals(availability.Busy),
+		Free:     newIntervals(availability.Free),
+		Slots:    []Slot{},
+	}
+	for _, slot := range availability.Slots {
+		a.Slots = append(a.Slots, Slot{
+			Start:      slot.Start,
+			End:        slot.End,
+			Available:  slot.Available,
+			Players:    slot.Players,
+			Full:       slot.Full,
+			Joinable:   slot.Joinable,
+			OpenPlayID: slot.OpenPlayID,
+		})
+	}
+	return a
+}
+
+func newIntervals(intervals []models.Interval) []Interval {
+	result := []Interval{}
+	for _, interval := range intervals {
+		result = append(result, Interval{Start: interval.Start, End: interval.End})
+	}
+	return result
+}
+
+func newTrainingSession(session *models.TrainingSession) TrainingSession {
+	return TrainingSession{
+		ID:              session.ID,
+		Title:           session.Title,
+		Description:     session.Description,
+		CoachID:         session.CoachID,
+		CoachName:       session.CoachName,
+		CourtID:         session.CourtID,
+		CourtName:       session.CourtName,
+		StartTime:       session.StartTime,
+		EndTime:         session.EndTime,
+		MaxParticipants: session.MaxParticipants,
+		Enrolled:        session.Enrolled,
+	}
+}
+
+// newUser describes a member; their email is left out unless showEmail
+func newUser(user *models.User, showEmail bool) User {
+	u := User{
+		ID:         user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		SkillLevel: user.SkillLevel,
+		FacilityID: user.FacilityID,
+		CreatedAt:  user.CreatedAt,
+	}
+	if showEmail {
+		u.Email = user.Email
+	}
+	return u
+}
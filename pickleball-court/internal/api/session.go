@@ -0,0 +1,52 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginHandler signs in to the current club and starts a session, carried
+// by the session cookie like the web app's
+func LoginHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			failBinding(c, err)
+			return
+		}
+
+		user, err := models.AuthenticateUser(db, currentClubID(c), req.Username, req.Password)
+		if err != nil {
+			fail(c, http.StatusUnauthorized, CodeUnauthenticated, "Invalid username or password")
+			return
+		}
+
+		if err := middleware.SetUserSession(c, user.ID); err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to start session")
+			return
+		}
+
+		respond(c, http.StatusOK, newUser(user, true))
+	}
+}
+
+// LogoutHandler ends the session
+func LogoutHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := middleware.ClearUserSession(c); err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to end session")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// MeHandler returns the signed-in member
+func MeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, newUser(middleware.GetCurrentUser(c), true))
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrainingSessionsHandler lists the club's training sessions, soonest
+// first, with how many players are enrolled. Sessions that have ended are
+// left out unless a from date is given; coach_id narrows them to one
+// coach.
+func ListTrainingSessionsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, ok := pageParams(c)
+		if !ok {
+			return
+		}
+
+		search := models.TrainingSearch{ClubID: currentClubID(c), Page: page}
+		if search.CoachID, ok = int64Query(c, "coach_id"); !ok {
+			return
+		}
+		if search.From, search.To, ok = dateRangeParams(c); !ok {
+			return
+		}
+		if search.From.IsZero() {
+			search.From = time.Now()
+		}
+
+		sessions, total, err := models.SearchTrainingSessions(db, search)
+		if err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load training sessions")
+			return
+		}
+
+		data := []TrainingSession{}
+		for _, session := range sessions {
+			data = append(data, newTrainingSession(session))
+		}
+		respondList(c, data, page, total)
+	}
+}
+
+// EnrollTrainingHandler enrolls the signed-in player in a training session
+func EnrollTrainingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireRole(c, models.RolePlayer)
+		if !ok {
+			return
+		}
+		session, ok := trainingSessionParam(c, db)
+		if !ok {
+			return
+		}
+		if !session.StartTime.After(time.Now()) {
+			fail(c, http.StatusConflict, CodeConflict, "This session has already started")
+			return
+		}
+
+		if err := models.EnrollInTrainingSession(db, user.ID, session.ID); err != nil {
+			switch {
+			case errors.Is(err, models.ErrOtherClub):
+				fail(c, http.StatusNotFound, CodeNotFound, "Training session not found")
+			case errors.Is(err, models.ErrTrainingSessionFull), errors.Is(err, models.ErrAlreadyEnrolled):
+				fail(c, http.StatusConflict, CodeConflict, err.Error())
+			default:
+				fail(c, http.StatusInternalServerError, CodeInternal, "Failed to enroll in training session")
+			}
+			return
+		}
+		notify.TrainingEnrolled(db, user.ID, session)
+		webhook.TrainingEnrolled(db, user.ID, session)
+
+		session.Enrolled++
+		respond(c, http.StatusCreated, newTrainingSession(session))
+	}
+}
+
+// CancelEnrollmentHandler takes the signed-in player out of a training
+// session
+func CancelEnrollmentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireRole(c, models.RolePlayer)
+		if !ok {
+			return
+		}
+		session, ok := trainingSessionParam(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.CancelTrainingEnrollment(db, user.ID, session.ID); err != nil {
+			fail(c, http.StatusNotFound, CodeNotFound, "You are not enrolled in this session")
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// trainingSessionParam loads the club training session in the URL, with
+// its enrollment count, writing an error response if there is none
+func trainingSessionParam(c *gin.Context, db *sql.DB) (*models.TrainingSession, bool) {
+	sessionID, ok := idParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	session, err := models.GetTrainingSessionByID(db, currentClubID(c), sessionID)
+	if err != nil {
+		fail(c, http.StatusNotFound, CodeNotFound, "Training session not found")
+		return nil, false
+	}
+
+	participants, err := models.GetTrainingSessionParticipantIDs(db, session.ID)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load training session")
+		return nil, false
+	}
+	session.Enrolled = len(participants)
+	return session, true
+}
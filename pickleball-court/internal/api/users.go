@@ -0,0 +1,68 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ListUsersHandler lists the club's members by username, for admins. role
+// narrows them to one role and q matches part of a username or email.
+func ListUsersHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := requireRole(c, models.RoleAdmin); !ok {
+			return
+		}
+		page, ok := pageParams(c)
+		if !ok {
+			return
+		}
+
+		role := c.Query("role")
+		switch role {
+		case "", models.RolePlayer, models.RoleCoach, models.RoleAdmin:
+		default:
+			failField(c, "role", "must be one of player coach admin")
+			return
+		}
+
+		users, total, err := models.SearchUsers(db, models.UserSearch{
+			ClubID: currentClubID(c),
+			Role:   role,
+			Query:  c.Query("q"),
+			Page:   page,
+		})
+		if err != nil {
+			fail(c, http.StatusInternalServerError, CodeInternal, "Failed to load users")
+			return
+		}
+
+		data := []User{}
+		for _, user := range users {
+			data = append(data, newUser(user, true))
+		}
+		respondList(c, data, page, total)
+	}
+}
+
+// GetUserHandler returns a member of the club. Only admins and the member
+// themselves see their email address.
+func GetUserHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := idParam(c, "id")
+		if !ok {
+			return
+		}
+
+		user, err := models.GetUserByID(db, currentClubID(c), userID)
+		if err != nil {
+			fail(c, http.StatusNotFound, CodeNotFound, "User not found")
+			return
+		}
+
+		current := middleware.GetCurrentUser(c)
+		respond(c, http.StatusOK, newUser(user, current.ID == user.ID || current.Role == models.RoleAdmin))
+	}
+}
@@ -0,0 +1,66 @@
+// Package email delivers plain-text email through a pluggable Sender: SMTP
+// in production, the log when email is disabled, and memory in tests.
+package email
+
+import (
+	"log"
+	"pickleball-court/config"
+	"sync"
+)
+
+// Message is a plain-text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a message or returns why it could not
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender returns an SMTP sender when email is enabled and a log sender
+// otherwise
+func NewSender(cfg config.EmailConfig) Sender {
+	if cfg.Enabled {
+		return NewSMTPSender(cfg)
+	}
+	return LogSender{}
+}
+
+// LogSender logs messages instead of sending them, for development
+type LogSender struct{}
+
+// Send logs the recipient and subject. The body is left out because it
+// can hold secrets such as password reset links.
+func (LogSender) Send(msg Message) error {
+	log.Printf("Email to %s: %s\n", msg.To, msg.Subject)
+	return nil
+}
+
+// MemorySender keeps messages in memory so tests can inspect them. Set Err
+// to make every send fail.
+type MemorySender struct {
+	mu   sync.Mutex
+	sent []Message
+	Err  error
+}
+
+// Send records the message, or returns Err if set
+func (s *MemorySender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// Sent returns the messages sent so far
+func (s *MemorySender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.sent...)
+}
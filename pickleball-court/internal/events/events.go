@@ -0,0 +1,100 @@
+// Package events announces changes to court availability, such as bookings
+// being made or cancelled, to whoever is listening. Publishers and
+// subscribers go through a Broker; the default one works within a single
+// process and can be replaced with SetBroker by one that spans instances.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of event
+const (
+	BookingCreated   = "booking.created"
+	BookingUpdated   = "booking.updated"   // status changed but the court is still taken
+	BookingCancelled = "booking.cancelled" // cancelled, rejected or a no-show: the slot is free
+	BookingMoved     = "booking.moved"     // From holds the slot it left
+	SlotHeld         = "slot.held"         // held while a player confirms a booking
+	SlotReleased     = "slot.released"
+	CourtClosed      = "court.closed" // a blackout or maintenance window
+	CourtReopened    = "court.reopened"
+)
+
+// Slot is a court between two times
+type Slot struct {
+	CourtID   int64     `json:"court_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// Event is a change to when a court is free. It says which slot changed
+// but not who booked it.
+type Event struct {
+	Type      string `json:"type"`
+	ClubID    int64  `json:"club_id"`
+	BookingID int64  `json:"booking_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Slot
+	From *Slot `json:"from,omitempty"`
+}
+
+// Slots returns the slots the event affects
+func (e Event) Slots() []Slot {
+	if e.From != nil {
+		return []Slot{e.Slot, *e.From}
+	}
+	return []Slot{e.Slot}
+}
+
+// Broker delivers published events to subscribers
+type Broker interface {
+	Publish(event Event)
+	Subscribe() *Subscription
+}
+
+// Subscription receives events on C until closed. C is also closed if the
+// subscriber falls too far behind, so that it reconnects and catches up
+// rather than silently missing changes.
+type Subscription struct {
+	C      <-chan Event
+	cancel func()
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// NewSubscription returns a subscription reading from c, calling cancel
+// when closed. It is for Broker implementations.
+func NewSubscription(c <-chan Event, cancel func()) *Subscription {
+	var once sync.Once
+	return &Subscription{C: c, cancel: func() { once.Do(cancel) }}
+}
+
+var (
+	mu     sync.RWMutex
+	broker Broker = NewMemoryBroker()
+)
+
+// SetBroker replaces the broker events go through
+func SetBroker(b Broker) {
+	mu.Lock()
+	defer mu.Unlock()
+	broker = b
+}
+
+// Publish announces an event
+func Publish(event Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	broker.Publish(event)
+}
+
+// Subscribe starts receiving events
+func Subscribe() *Subscription {
+	mu.RLock()
+	defer mu.RUnlock()
+	return broker.Subscribe()
+}
@@ -0,0 +1,50 @@
+package events
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber can fall behind by
+// before it is dropped
+const subscriberBuffer = 64
+
+// MemoryBroker delivers events to subscribers in the same process
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewMemoryBroker returns a broker with no subscribers
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish sends the event to every subscriber without waiting. A
+// subscriber whose buffer is full is dropped.
+func (b *MemoryBroker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.subscribers {
+		select {
+		case c <- event:
+		default:
+			delete(b.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// Subscribe adds a subscriber
+func (b *MemoryBroker) Subscribe() *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[c] = struct{}{}
+	b.mu.Unlock()
+
+	return NewSubscription(c, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[c]; ok {
+			delete(b.subscribers, c)
+			close(c)
+		}
+	})
+}
@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"pickleball-court/config"
 	"pickleball-court/internal/middleware"
 	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
+	"strconv"
+	"strings"
 	"time"
 	"github.com/gin-gonic/gin"
 )
@@ -19,85 +25,133 @@ func AdminDashboardHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		clubID := currentClubID(c)
+
 		// Get statistics
 		var stats struct {
 			Courts          int
 			Users          int
 			Bookings       int
 			TrainingSessions int
+			CheckInsToday    int
+			NoShows30Days    int
 		}
 
 		// Get court count
-		err := db.QueryRow("SELECT COUNT(*) FROM courts").Scan(&stats.Courts)
+		err := db.QueryRow("SELECT COUNT(*) FROM courts WHERE "+clubCourts, clubID).Scan(&stats.Courts)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
 			return
 		}
 
 		// Get user count
-		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.Users)
+		err = db.QueryRow("SELECT COUNT(*) FROM users WHERE club_id = ?", clubID).Scan(&stats.Users)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
 			return
 		}
 
 		// Get active bookings count
-		err = db.QueryRow("SELECT COUNT(*) FROM bookings WHERE status != 'cancelled'").Scan(&stats.Bookings)
+		err = db.QueryRow("SELECT COUNT(*) FROM bookings WHERE court_id IN (SELECT id FROM courts WHERE "+clubCourts+") AND status NOT IN ('cancelled', 'rejected', 'no_show')", clubID).Scan(&stats.Bookings)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
 			return
 		}
 
 		// Get training sessions count
-		err = db.QueryRow("SELECT COUNT(*) FROM training_sessions").Scan(&stats.TrainingSessions)
+		err = db.QueryRow("SELECT COUNT(*) FROM training_sessions WHERE court_id IN (SELECT id FROM courts WHERE "+clubCourts+")", clubID).Scan(&stats.TrainingSessions)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
+			return
+		}
+
+		// Get today's check-ins
+		err = db.QueryRow("SELECT COUNT(*) FROM bookings WHERE court_id IN (SELECT id FROM courts WHERE "+clubCourts+") AND date(checked_in_at) = date('now')", clubID).Scan(&stats.CheckInsToday)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
+			return
+		}
+
+		// Get no-shows over the last 30 days
+		err = db.QueryRow("SELECT COUNT(*) FROM bookings WHERE court_id IN (SELECT id FROM courts WHERE "+clubCourts+") AND status = 'no_show' AND julianday(start_time) > julianday('now', '-30 days')", clubID).Scan(&stats.NoShows30Days)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
 			return
 		}
 
 		// Get all courts
-		courts, err := models.GetAllCourts(db)
+		courts, err := models.GetAllCourts(db, clubID)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load courts"})
 			return
 		}
 
 		// Get all users
-		users, err := models.GetAllUsers(db)
+		users, err := models.GetAllUsers(db, clubID)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load users"})
 			return
 		}
 
 		// Get recent bookings
-		bookings, err := models.GetRecentBookings(db, 10) // Get last 10 bookings
+		bookings, err := models.GetRecentBookings(db, clubID, 10) // Get last 10 bookings
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load bookings"})
 			return
 		}
 
+		// Facility admins only see their own venue's courts and bookings
+		if user.FacilityID != 0 {
+			courts = models.CourtFilter{FacilityID: user.FacilityID}.Apply(courts)
+			bookings = facilityBookings(user, bookings)
+		}
+
+		// Unread notifications, shown as a badge on the inbox
+		unreadNotifications, err := models.CountUnreadNotifications(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load notifications"})
+			return
+		}
+
 		c.HTML(http.StatusOK, "admin_dashboard.html", gin.H{
 			"title": "Admin Dashboard",
+			"club":  middleware.GetCurrentClub(c),
 			"user":  user,
 			"stats": stats,
 			"courts": courts,
 			"users": users,
 			"bookings": bookings,
+			"unreadNotifications": unreadNotifications,
 		})
 	}
 }
 
-// CreateCourtHandler handles court creation
+// CreateCourtHandler handles court creation. Courts created by a facility
+// admin always belong to their facility.
 func CreateCourtHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
 		var court models.Court
 		if err := c.ShouldBindJSON(&court); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		court.ClubID = currentClubID(c)
+		if user.FacilityID != 0 {
+			court.FacilityID = user.FacilityID
+		}
 
 		err := models.CreateCourt(db, &court)
 		if err != nil {
+			if errors.Is(err, models.ErrInvalidCourtAttributes) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create court"})
 			return
 		}
@@ -106,17 +160,31 @@ func CreateCourtHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// UpdateCourtHandler handles court updates
+// UpdateCourtHandler handles court updates. Facility admins cannot move a
+// court to another facility.
 func UpdateCourtHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		existing, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
 		var court models.Court
 		if err := c.ShouldBindJSON(&court); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		court.ID = existing.ID
+		if user := middleware.GetCurrentUser(c); user.FacilityID != 0 {
+			court.FacilityID = user.FacilityID
+		}
 
 		err := models.UpdateCourt(db, &court)
 		if err != nil {
+			if errors.Is(err, models.ErrInvalidCourtAttributes) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update court"})
 			return
 		}
@@ -128,8 +196,12 @@ func UpdateCourtHandler(db *sql.DB) gin.HandlerFunc {
 // DeleteCourtHandler handles court deletion
 func DeleteCourtHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		courtID := c.Param("id")
-		err := models.DeleteCourt(db, courtID)
+		court, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
+		err := models.DeleteCourt(db, court.ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete court"})
 			return
@@ -139,33 +211,47 @@ func DeleteCourtHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// CreateUserHandler handles user creation by admin
+// CreateUserHandler handles user creation by a club-wide admin
 func CreateUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
 		var user models.User
 		if err := c.ShouldBindJSON(&user); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		user.ClubID = currentClubID(c)
 
 		err := models.CreateUser(db, &user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
+		webhook.UserRegistered(db, &user)
 
 		c.JSON(http.StatusOK, user)
 	}
 }
 
-// UpdateUserHandler handles user updates by admin
+// UpdateUserHandler handles user updates by a club-wide admin, including
+// assigning staff and admins to a facility
 func UpdateUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
 		var user models.User
 		if err := c.ShouldBindJSON(&user); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if !requireClubUser(c, db, user.ID) {
+			return
+		}
 
 		err := models.UpdateUser(db, &user)
 		if err != nil {
@@ -187,7 +273,7 @@ func ListUsersHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		users, err := models.GetAllUsers(db)
+		users, err := models.GetAllUsers(db, currentClubID(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
 			return
@@ -197,7 +283,8 @@ func ListUsersHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// ListCourtsHandler handles listing all courts
+// ListCourtsHandler handles listing all courts, optionally filtered by
+// attribute
 func ListCourtsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Verify admin role
@@ -207,7 +294,15 @@ func ListCourtsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		courts, err := models.GetAllCourts(db)
+		filter, ok := courtFilterParams(c)
+		if !ok {
+			return
+		}
+		if user.FacilityID != 0 {
+			filter.FacilityID = user.FacilityID
+		}
+
+		courts, err := models.GetCourts(db, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load courts"})
 			return
@@ -217,6 +312,73 @@ func ListCourtsHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// courtFilterParams reads court filters for the current club from the query
+// string: facility_id
+// takes an ID, indoor, lighting and accessible a boolean, surface and lines
+// a value. It writes an error response if a parameter is malformed.
+func courtFilterParams(c *gin.Context) (models.CourtFilter, bool) {
+	filter := models.CourtFilter{
+		ClubID:  currentClubID(c),
+		Surface: c.Query("surface"),
+		Lines:   c.Query("lines"),
+	}
+
+	if value := c.Query("facility_id"); value != "" {
+		facilityID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid facility ID"})
+			return filter, false
+		}
+		filter.FacilityID = facilityID
+	}
+
+	var ok bool
+	if filter.Indoor, ok = boolParam(c, "indoor"); !ok {
+		return filter, false
+	}
+	if filter.Lighting, ok = boolParam(c, "lighting"); !ok {
+		return filter, false
+	}
+	if filter.Accessible, ok = boolParam(c, "accessible"); !ok {
+		return filter, false
+	}
+	return filter, true
+}
+
+// courtIDParams reads the court_id query parameter, which may be repeated
+// or given as a comma-separated list. It writes an error response if an ID
+// is malformed.
+func courtIDParams(c *gin.Context) ([]int64, bool) {
+	var courtIDs []int64
+	for _, param := range c.QueryArray("court_id") {
+		for _, value := range strings.Split(param, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid court ID"})
+				return nil, false
+			}
+			courtIDs = append(courtIDs, id)
+		}
+	}
+	return courtIDs, true
+}
+
+// boolParam reads an optional boolean query parameter, returning nil when
+// it is absent and writing an error response when it is malformed
+func boolParam(c *gin.Context, name string) (*bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for " + name})
+		return nil, false
+	}
+	return &parsed, true
+}
+
 // ListTrainingSessionsHandler handles listing all training sessions
 func ListTrainingSessionsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -234,7 +396,7 @@ func ListTrainingSessionsHandler(db *sql.DB) gin.HandlerFunc {
 			sessions, err = models.GetTrainingSessionsByCoach(db, user.ID)
 		} else {
 			// For admin, get all sessions
-			sessions, err = models.GetAvailableTrainingSessions(db)
+			sessions, err = models.GetAvailableTrainingSessions(db, currentClubID(c))
 		}
 
 		if err != nil {
@@ -246,79 +408,138 @@ func ListTrainingSessionsHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// GetCourtAvailabilityHandler handles retrieving court availability
+// GetCourtAvailabilityHandler returns court availability for a single date
+// or a from/to range of up to MaxAvailabilityDays days. Courts can be
+// narrowed with one or more court_id parameters or by attribute, after and
+// before limit each day to a time of day, and duration sets the length of
+// the slots offered. Courts at every facility are included unless narrowed
+// by facility_id, and each court's times are laid out in its facility's
+// timezone.
 func GetCourtAvailabilityHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		date := c.Query("date")
-		if date == "" {
+		loc := config.Get().GetTimeZone()
+
+		from := c.DefaultQuery("from", c.Query("date"))
+		to := c.DefaultQuery("to", from)
+		if from == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Date parameter is required"})
 			return
 		}
 
-		// Parse the date
-		startDate, err := time.Parse("2006-01-02", date)
+		// Parse the dates
+		startDate, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+			return
+		}
+		endDate, err := time.ParseInLocation("2006-01-02", to, loc)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
 			return
 		}
 
-		// Get all courts
-		courts, err := models.GetAllCourts(db)
+		// Slots step by the configured slot size; each one reports whether a
+		// block of the requested duration starting there is free
+		slotDuration := config.Get().GetSlotDuration()
+		duration := slotDuration
+		if minutes, err := strconv.Atoi(c.DefaultQuery("duration", "0")); err == nil && minutes > 0 {
+			duration = time.Duration(minutes) * time.Minute
+		}
+		if duration%slotDuration != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must be a multiple of the slot size"})
+			return
+		}
+
+		courtIDs, ok := courtIDParams(c)
+		if !ok {
+			return
+		}
+
+		filter, ok := courtFilterParams(c)
+		if !ok {
+			return
+		}
+
+		courts, err := models.GetCourtAvailability(db, models.AvailabilityRequest{
+			From:       startDate,
+			To:         endDate,
+			After:      c.Query("after"),
+			Before:     c.Query("before"),
+			CourtIDs:   courtIDs,
+			Filter:     filter,
+			SlotLength: duration,
+		}, time.Now())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load courts"})
+			if errors.Is(err, models.ErrInvalidAvailabilityRange) || errors.Is(err, models.ErrInvalidTimeOfDay) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load availability"})
 			return
 		}
 
-		// For each court, get its availability for the day
 		type TimeSlot struct {
 			StartTime     time.Time `json:"start_time"`
+			EndTime       time.Time `json:"end_time"`
 			Available     bool      `json:"available"`
+			Players       int       `json:"players"`
+			Full          bool      `json:"full"`
+			Joinable      bool      `json:"joinable"`
+			OpenPlayID    int64     `json:"open_play_id,omitempty"`
+			Date          string    `json:"date"`
 			FormattedTime string    `json:"formatted_time"`
 		}
 
 		type CourtAvailability struct {
-			ID          int64      `json:"id"`
-			Name        string     `json:"name"`
-			Description string     `json:"description"`
-			TimeSlots   []TimeSlot `json:"time_slots"`
-		}
-
-		var availability []CourtAvailability
-
-		// Operating hours (6 AM to 10 PM)
-		openingHour := 6
-		closingHour := 22
-
+			ID                int64             `json:"id"`
+			Name              string            `json:"name"`
+			Description       string            `json:"description"`
+			FacilityID        int64             `json:"facility_id"`
+			FacilityName      string            `json:"facility_name"`
+			TimeZone          string            `json:"timezone"`
+			MaxBookingMinutes int               `json:"max_booking_minutes"`
+			Indoor            bool              `json:"indoor"`
+			Surface           string            `json:"surface"`
+			Lighting          bool              `json:"lighting"`
+			Lines             string            `json:"lines"`
+			Accessible        bool              `json:"accessible"`
+			Busy              []models.Interval `json:"busy"`
+			Free              []models.Interval `json:"free"`
+			TimeSlots         []TimeSlot        `json:"time_slots"`
+		}
+
+		availability := []CourtAvailability{}
 		for _, court := range courts {
 			courtAvail := CourtAvailability{
-				ID:          court.ID,
-				Name:        court.Name,
-				Description: court.Description,
+				ID:                court.Court.ID,
+				Name:              court.Court.Name,
+				Description:       court.Court.Description,
+				FacilityID:        court.Court.FacilityID,
+				FacilityName:      court.Court.FacilityName,
+				TimeZone:          court.Location.String(),
+				MaxBookingMinutes: court.Court.MaxBookingMinutes,
+				Indoor:            court.Court.Indoor,
+				Surface:           court.Court.Surface,
+				Lighting:          court.Court.Lighting,
+				Lines:             court.Court.Lines,
+				Accessible:        court.Court.Accessible,
+				Busy:              court.Busy,
+				Free:              court.Free,
 			}
 
-			// Generate time slots for the day
-			for hour := openingHour; hour < closingHour; hour++ {
-				slotStart := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), hour, 0, 0, 0, time.Local)
-				slotEnd := slotStart.Add(time.Hour)
-
-				// Skip if the slot is in the past
-				if slotStart.Before(time.Now()) {
-					continue
-				}
-
-				// Check availability
-				available, err := models.IsCourtAvailable(db, court.ID, slotStart, slotEnd)
-				if err != nil {
-					continue
-				}
-
-				timeSlot := TimeSlot{
-					StartTime:     slotStart,
-					Available:     available,
-					FormattedTime: slotStart.Format("15:04"),
-				}
-
-				courtAvail.TimeSlots = append(courtAvail.TimeSlots, timeSlot)
+			for _, slot := range court.Slots {
+				start := slot.Start.In(court.Location)
+				courtAvail.TimeSlots = append(courtAvail.TimeSlots, TimeSlot{
+					StartTime:     start,
+					EndTime:       slot.End.In(court.Location),
+					Available:     slot.Available,
+					Players:       slot.Players,
+					Full:          slot.Full,
+					Joinable:      slot.Joinable,
+					OpenPlayID:    slot.OpenPlayID,
+					Date:          start.Format("2006-01-02"),
+					FormattedTime: start.Format("15:04"),
+				})
 			}
 
 			availability = append(availability, courtAvail)
@@ -328,11 +549,23 @@ func GetCourtAvailabilityHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// DeleteUserHandler handles user deletion by admin
+// DeleteUserHandler handles user deletion by a club-wide admin
 func DeleteUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Param("id")
-		err := models.DeleteUser(db, userID)
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
+		userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if !requireClubUser(c, db, userID) {
+			return
+		}
+
+		err = models.DeleteUser(db, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 			return
@@ -342,21 +575,62 @@ func DeleteUserHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// UpdateBookingHandler handles booking updates by admin
+// UpdateBookingHandler moves a booking to a new status through the approval
+// workflow. Cancellations are routed through the cancellation rules.
 func UpdateBookingHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		bookingID := c.Param("id")
-		var status struct {
-			Status string `json:"status"`
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, ok := bookingParam(c, db)
+		if !ok {
+			return
 		}
-		if err := c.ShouldBindJSON(&status); err != nil {
+
+		var req struct {
+			Status string `json:"status" binding:"required"`
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		err := models.UpdateBookingStatus(db, bookingID, status.Status)
+		if !models.IsValidBookingStatus(req.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking status"})
+			return
+		}
+
+		var err error
+		if req.Status == models.BookingStatusCancelled {
+			var cancellation *models.Cancellation
+			cancellation, err = models.CancelBooking(db, booking.ID, models.CancelOptions{
+				CancelledBy: user.ID,
+				Reason:      req.Reason,
+				Override:    true,
+			})
+			if err == nil {
+				notify.BookingCancelled(db, booking, req.Reason, nil)
+				NotifyWaitlistOffers(db, cancellation.WaitlistOffers)
+			}
+		} else {
+			err = models.TransitionBooking(db, booking.ID, req.Status, user.ID, req.Reason)
+			if err == nil && req.Status == models.BookingStatusConfirmed && booking.Status != models.BookingStatusConfirmed {
+				notify.BookingConfirmed(db, booking)
+			}
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+			switch {
+			case errors.Is(err, models.ErrInvalidTransition):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, models.ErrCourtUnavailable):
+				c.JSON(http.StatusConflict, gin.H{"error": "Court is not available for the selected time slot"})
+			default:
+				respondCancelError(c, err)
+			}
 			return
 		}
 
@@ -364,15 +638,302 @@ func UpdateBookingHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// ListPendingBookingsHandler lists bookings waiting for approval
+func ListPendingBookingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookings, err := models.GetBookingsByStatus(db, currentClubID(c), models.BookingStatusPending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bookings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, facilityBookings(middleware.GetCurrentUser(c), bookings))
+	}
+}
+
+// BookingHistoryHandler returns the status history and the reschedule and
+// transfer audit trail of a booking
+func BookingHistoryHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := bookingParam(c, db)
+		if !ok {
+			return
+		}
+
+		history, err := models.GetBookingStatusHistory(db, booking.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking history"})
+			return
+		}
+
+		audit, err := models.GetBookingAudit(db, booking.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"booking": booking, "history": history, "audit": audit})
+	}
+}
+
+// AdminCancelBookingHandler cancels any booking, overriding the notice window
+func AdminCancelBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, ok := bookingParam(c, db)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		cancellation, err := models.CancelBooking(db, booking.ID, models.CancelOptions{
+			CancelledBy: user.ID,
+			Reason:      req.Reason,
+			Override:    true,
+		})
+		if err != nil {
+			respondCancelError(c, err)
+			return
+		}
+		notify.BookingCancelled(db, booking, req.Reason, nil)
+		NotifyWaitlistOffers(db, cancellation.WaitlistOffers)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled successfully", "cancellation": cancellation})
+	}
+}
+
+// GetPenaltyPolicyHandler returns the club's late-cancellation penalty policy
+func GetPenaltyPolicyHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, err := models.GetPenaltyPolicy(db, currentClubID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load penalty policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdatePenaltyPolicyHandler updates the club's late-cancellation penalty
+// policy
+func UpdatePenaltyPolicyHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
+		var policy models.PenaltyPolicy
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := policy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := models.UpdatePenaltyPolicy(db, currentClubID(c), &policy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update penalty policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// GetNoShowPolicyHandler returns the no-show policy that applies at the
+// facility given by facility_id, or the club default without one
+func GetNoShowPolicyHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facilityID, ok := policyFacilityParam(c, db)
+		if !ok {
+			return
+		}
+
+		policy, err := models.GetNoShowPolicy(db, currentClubID(c), facilityID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load no-show policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdateNoShowPolicyHandler sets the no-show policy of the facility given by
+// facility_id, or the club default without one. Facility admins can only
+// set their own facility's.
+func UpdateNoShowPolicyHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facilityID, ok := policyFacilityParam(c, db)
+		if !ok || !requirePolicyScope(c, facilityID) {
+			return
+		}
+
+		var policy models.NoShowPolicy
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		policy.FacilityID = facilityID
+
+		if err := policy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := models.UpdateNoShowPolicy(db, currentClubID(c), &policy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update no-show policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// DeleteNoShowPolicyHandler returns the facility given by facility_id to the
+// club's default no-show policy
+func DeleteNoShowPolicyHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facilityID, ok := policyFacilityParam(c, db)
+		if !ok {
+			return
+		}
+		if facilityID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The club default cannot be removed"})
+			return
+		}
+		if !requirePolicyScope(c, facilityID) {
+			return
+		}
+
+		if err := models.DeleteNoShowPolicy(db, currentClubID(c), facilityID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove no-show policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Facility now uses the club's no-show policy"})
+	}
+}
+
+// policyFacilityParam reads the optional facility_id of a policy, writing an
+// error response unless it is a facility of the current club
+func policyFacilityParam(c *gin.Context, db *sql.DB) (int64, bool) {
+	value := c.Query("facility_id")
+	if value == "" {
+		return 0, true
+	}
+
+	facilityID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid facility ID"})
+		return 0, false
+	}
+	return facilityID, requireClubFacility(c, db, facilityID)
+}
+
+// requirePolicyScope writes an error response unless the current user may
+// change the policy of a facility, or the club default for facility 0
+func requirePolicyScope(c *gin.Context, facilityID int64) bool {
+	if facilityID == 0 {
+		_, ok := requireClubAdmin(c)
+		return ok
+	}
+	return requireFacility(c, facilityID)
+}
+
+// SweepNoShowsHandler runs the no-show sweep for every club immediately
+func SweepNoShowsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		result, err := models.SweepNoShows(db, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sweep no-shows"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListLateCancellationsHandler lists recent late cancellations
+func ListLateCancellationsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cancellations, err := models.GetLateCancellations(db, currentClubID(c), 100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cancellations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cancellations)
+	}
+}
+
 // ListAllBookingsHandler handles listing all bookings for admin
 func ListAllBookingsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		bookings, err := models.GetAllBookings(db)
+		bookings, err := models.GetAllBookings(db, currentClubID(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bookings"})
 			return
 		}
 
-		c.JSON(http.StatusOK, bookings)
+		c.JSON(http.StatusOK, facilityBookings(middleware.GetCurrentUser(c), bookings))
+	}
+}
+
+// bookingParam loads the booking in the URL, writing an error response if
+// it does not exist at this club or is at a facility the current admin does
+// not manage
+func bookingParam(c *gin.Context, db *sql.DB) (*models.Booking, bool) {
+	booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return nil, false
+	}
+	if !requireFacility(c, booking.FacilityID) {
+		return nil, false
+	}
+	return booking, true
+}
+
+// facilityBookings keeps the bookings at facilities the user manages
+func facilityBookings(user *models.User, bookings []*models.Booking) []*models.Booking {
+	visible := make([]*models.Booking, 0, len(bookings))
+	for _, booking := range bookings {
+		if user.ManagesFacility(booking.FacilityID) {
+			visible = append(visible, booking)
+		}
+	}
+	return visible
+}
+
+// requireClubUser writes an error response unless the given user belongs to
+// the current club
+func requireClubUser(c *gin.Context, db *sql.DB, userID int64) bool {
+	_, err := models.GetUserByID(db, currentClubID(c), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
 	}
+	return true
 }
@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"pickleball-court/internal/middleware"
 	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
+	"time"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/sessions"
 )
@@ -14,6 +20,7 @@ func ShowLoginHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.HTML(http.StatusOK, "login.html", gin.H{
 			"title": "Login",
+			"club":  middleware.GetCurrentClub(c),
 		})
 	}
 }
@@ -24,7 +31,7 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 		username := c.PostForm("username")
 		password := c.PostForm("password")
 
-		user, err := models.AuthenticateUser(db, username, password)
+		user, err := models.AuthenticateUser(db, currentClubID(c), username, password)
 		if err != nil {
 			c.HTML(http.StatusUnauthorized, "login.html", gin.H{
 				"title": "Login",
@@ -40,6 +47,8 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 
 		// Redirect based on user role
 		switch user.Role {
+		case models.RoleSuperAdmin:
+			c.Redirect(http.StatusFound, "/super/clubs")
 		case models.RoleAdmin:
 			c.Redirect(http.StatusFound, "/admin/dashboard")
 		case models.RoleCoach:
@@ -55,6 +64,7 @@ func ShowRegisterHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.HTML(http.StatusOK, "register.html", gin.H{
 			"title": "Register",
+			"club":  middleware.GetCurrentClub(c),
 		})
 	}
 }
@@ -88,6 +98,7 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 			Password: password,
 			Email:    email,
 			Role:     role,
+			ClubID:   currentClubID(c),
 		}
 
 		err := models.CreateUser(db, user)
@@ -98,6 +109,7 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 			})
 			return
 		}
+		webhook.UserRegistered(db, user)
 
 		// Set user session
 		session := sessions.Default(c)
@@ -145,6 +157,7 @@ func ProfileHandler(db *sql.DB) gin.HandlerFunc {
 
 		c.HTML(http.StatusOK, "profile.html", gin.H{
 			"title": "My Profile",
+			"club":  middleware.GetCurrentClub(c),
 			"user": user,
 			"bookings": bookings,
 		})
@@ -188,7 +201,7 @@ func UpdatePasswordHandler(db *sql.DB) gin.HandlerFunc {
 		confirmPassword := c.PostForm("confirm_password")
 
 		// Verify current password
-		_, err := models.AuthenticateUser(db, user.Username, currentPassword)
+		_, err := models.AuthenticateUser(db, user.ClubID, user.Username, currentPassword)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
 			return
@@ -210,3 +223,54 @@ func UpdatePasswordHandler(db *sql.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 	}
 }
+
+// ForgotPasswordHandler emails a password reset link to the club member
+// with the given email address. It answers the same whether or not the
+// address belongs to anyone, so it cannot be used to find out who is a
+// member.
+func ForgotPasswordHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.PostForm("email")
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+			return
+		}
+
+		user, token, err := models.CreatePasswordReset(db, currentClubID(c), email, time.Now())
+		if err != nil {
+			log.Println("Password reset failed:", err)
+		} else if user != nil {
+			link := middleware.ClubURL(c, "/password/reset?token="+url.QueryEscape(token))
+			notify.PasswordReset(db, user, link)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If an account uses that email, a reset link is on its way"})
+	}
+}
+
+// ResetPasswordHandler sets a new password using the token from a reset
+// email
+func ResetPasswordHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.PostForm("token")
+		newPassword := c.PostForm("new_password")
+		confirmPassword := c.PostForm("confirm_password")
+
+		if newPassword == "" || newPassword != confirmPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New passwords do not match"})
+			return
+		}
+
+		err := models.ResetPassword(db, token, newPassword, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidResetToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+	}
+}
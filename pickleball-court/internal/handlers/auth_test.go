@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"pickleball-court/internal/email"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPasswordResetByEmailedLink(t *testing.T) {
+	db := openTestDB(t)
+	player := createTestUser(t, db, 1, "player", models.RolePlayer)
+
+	router := gin.New()
+	router.POST("/password/forgot", ForgotPasswordHandler(db))
+	router.POST("/password/reset", ResetPasswordHandler(db))
+	handler := middleware.Tenant(db, router, "")
+
+	post := func(path string, form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Unknown addresses get the same answer and no email
+	for _, address := range []string{"nobody@example.com", player.Email} {
+		if code := post("/password/forgot", url.Values{"email": {address}}); code != http.StatusOK {
+			t.Fatalf("forgot %s: got status %d, want 200", address, code)
+		}
+	}
+
+	sender := &email.MemorySender{}
+	if _, err := notify.Deliver(db, sender, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	sent := sender.Sent()
+	if len(sent) != 1 || sent[0].To != player.Email {
+		t.Fatalf("got %d emails, want one to %s", len(sent), player.Email)
+	}
+	match := regexp.MustCompile(`/password/reset\?token=([0-9a-f]+)`).FindStringSubmatch(sent[0].Body)
+	if match == nil {
+		t.Fatalf("no reset link in email:\n%s", sent[0].Body)
+	}
+	token := match[1]
+
+	reset := func(token, password string) int {
+		return post("/password/reset", url.Values{
+			"token":            {token},
+			"new_password":     {password},
+			"confirm_password": {password},
+		})
+	}
+	if code := reset("not-a-token", "new-password"); code != http.StatusBadRequest {
+		t.Errorf("unknown token: got status %d, want 400", code)
+	}
+	if code := reset(token, "new-password"); code != http.StatusOK {
+		t.Fatalf("reset: got status %d, want 200", code)
+	}
+	if _, err := models.AuthenticateUser(db, 1, player.Username, "new-password"); err != nil {
+		t.Errorf("sign in with the new password: %v", err)
+	}
+	if _, err := models.AuthenticateUser(db, 1, player.Username, "password"); err == nil {
+		t.Error("signed in with the old password")
+	}
+
+	// A link works only once
+	if code := reset(token, "another-password"); code != http.StatusBadRequest {
+		t.Errorf("reused token: got status %d, want 400", code)
+	}
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	db := openTestDB(t)
+	player := createTestUser(t, db, 1, "player", models.RolePlayer)
+
+	_, token, err := models.CreatePasswordReset(db, 1, player.Email, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = models.ResetPassword(db, token, "new-password", time.Now())
+	if err != models.ErrInvalidResetToken {
+		t.Errorf("got error %v, want %v", err, models.ErrInvalidResetToken)
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/ical"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"strconv"
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarLinkHandler returns the URL of the current user's personal
+// calendar feed, for subscribing from Google or Apple Calendar
+func CalendarLinkHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		token, err := models.GetCalendarToken(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar link"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"url": userFeedURL(c, token)})
+	}
+}
+
+// ResetCalendarLinkHandler replaces the current user's calendar feed URL,
+// cutting off anyone the old one was shared with
+func ResetCalendarLinkHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		token, err := models.ResetCalendarToken(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset calendar link"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"url": userFeedURL(c, token)})
+	}
+}
+
+// UserCalendarFeedHandler serves a user's personal feed: their bookings and
+// the training sessions they are enrolled in, plus, for coaches, the
+// sessions they run. The secret token in the URL stands in for a login so
+// that calendar apps can poll it.
+func UserCalendarFeedHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := models.GetUserByCalendarToken(db, c.Param("token"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+
+		bookings, err := models.GetUserBookings(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+			return
+		}
+		enrolled, err := models.GetUserTrainingSessions(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+			return
+		}
+		var coaching []*models.TrainingSession
+		if user.Role == models.RoleCoach {
+			coaching, err = models.GetTrainingSessionsByCoach(db, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+				return
+			}
+		}
+
+		cal := &ical.Calendar{Name: user.Username + " - Court Bookings"}
+		for _, booking := range bookings {
+			// A coach's sessions are listed below with their titles instead
+			if len(coaching) > 0 && booking.BookingType == models.BookingTypeTraining {
+				continue
+			}
+			cal.Events = append(cal.Events, bookingEvent(booking))
+		}
+		for _, session := range append(enrolled, coaching...) {
+			cal.Events = append(cal.Events, sessionEvent(session))
+		}
+
+		writeCalendar(c, cal, "")
+	}
+}
+
+// CourtCalendarFeedHandler serves a court's public feed of upcoming and
+// past bookings. It shows when the court is taken but not who booked it.
+func CourtCalendarFeedHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courtID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid court ID"})
+			return
+		}
+
+		court, err := models.GetCourtByID(db, currentClubID(c), courtID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Court not found"})
+			return
+		}
+
+		bookings, err := models.GetCourtBookings(db, court.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+			return
+		}
+
+		cal := &ical.Calendar{Name: court.Name}
+		for _, booking := range bookings {
+			if !isActiveBooking(booking) {
+				continue
+			}
+			event := bookingEvent(booking)
+			event.Summary = court.Name + " booked"
+			event.Description = ""
+			cal.Events = append(cal.Events, event)
+		}
+
+		writeCalendar(c, cal, "")
+	}
+}
+
+// BookingCalendarHandler downloads a single booking as an .ics file to add
+// to a calendar. Players can download their own bookings and admins and
+// staff any booking at the facilities they manage.
+func BookingCalendarHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if booking.UserID != user.ID {
+			if (user.Role != models.RoleAdmin && user.Role != models.RoleStaff) || !user.ManagesFacility(booking.FacilityID) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+		}
+
+		cal := &ical.Calendar{Events: []ical.Event{bookingEvent(booking)}}
+		writeCalendar(c, cal, fmt.Sprintf("booking-%d.ics", booking.ID))
+	}
+}
+
+// bookingEvent converts a booking to a calendar event
+func bookingEvent(booking *models.Booking) ical.Event {
+	summary := "Pickleball at " + booking.CourtName
+	switch booking.BookingType {
+	case models.BookingTypeTraining:
+		summary = "Training at " + booking.CourtName
+	case models.BookingTypeOpenPlay:
+		summary = "Open play at " + booking.CourtName
+	}
+
+	status := ical.StatusConfirmed
+	switch booking.Status {
+	case models.BookingStatusPending:
+		status = ical.StatusTentative
+	case models.BookingStatusCancelled, models.BookingStatusRejected, models.BookingStatusNoShow:
+		status = ical.StatusCancelled
+	}
+
+	return ical.Event{
+		UID:          fmt.Sprintf("booking-%d@%s", booking.ID, config.Get().Server.CalendarDomain),
+		Sequence:     booking.Sequence,
+		Start:        booking.StartTime,
+		End:          booking.EndTime,
+		Summary:      summary,
+		Location:     booking.CourtName,
+		Description:  "Booking #" + strconv.FormatInt(booking.ID, 10) + " (" + booking.Status + ")",
+		Status:       status,
+		LastModified: booking.UpdatedAt,
+	}
+}
+
+// sessionEvent converts a training session to a calendar event
+func sessionEvent(session *models.TrainingSession) ical.Event {
+	description := "Coach: " + session.CoachName
+	if session.Description != "" {
+		description = session.Description + "\n" + description
+	}
+
+	return ical.Event{
+		UID:         fmt.Sprintf("training-%d@%s", session.ID, config.Get().Server.CalendarDomain),
+		Start:       session.StartTime,
+		End:         session.EndTime,
+		Summary:     session.Title,
+		Location:    session.CourtName,
+		Description: description,
+		Status:      ical.StatusConfirmed,
+	}
+}
+
+// isActiveBooking reports whether a booking still holds its court
+func isActiveBooking(booking *models.Booking) bool {
+	switch booking.Status {
+	case models.BookingStatusCancelled, models.BookingStatusRejected, models.BookingStatusNoShow:
+		return false
+	}
+	return true
+}
+
+// writeCalendar writes cal as the response, as an attachment when a
+// filename is given
+func writeCalendar(c *gin.Context, cal *ical.Calendar, filename string) {
+	if filename != "" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	c.Header("Content-Type", ical.ContentType)
+	c.Status(http.StatusOK)
+	if err := ical.Write(c.Writer, cal); err != nil {
+		c.Error(err)
+	}
+}
+
+// userFeedURL returns the address of the personal feed with the given token
+func userFeedURL(c *gin.Context, token string) string {
+	return middleware.ClubURL(c, "/calendar/users/"+token+"/bookings.ics")
+}
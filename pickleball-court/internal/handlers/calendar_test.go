@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCalendarFeedTracksBookingChanges(t *testing.T) {
+	db := openTestDB(t)
+	court := createTestCourt(t, db, 1, "Court 1")
+	player := createTestUser(t, db, 1, "player", models.RolePlayer)
+
+	start := tomorrowAt(10)
+	booking := &models.Booking{
+		CourtID:     court.ID,
+		UserID:      player.ID,
+		StartTime:   start,
+		EndTime:     start.Add(time.Hour),
+		Status:      models.BookingStatusConfirmed,
+		BookingType: models.BookingTypeRegular,
+	}
+	if err := models.CreateBooking(db, booking); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/calendar/courts/:id/bookings.ics", CourtCalendarFeedHandler(db))
+	handler := middleware.Tenant(db, router, "example.com")
+
+	// fetch returns the UID, DTSTAMP and SEQUENCE of the feed's one event
+	property := regexp.MustCompile(`(?m)^(UID|DTSTAMP|SEQUENCE):(.*)\r$`)
+	fetch := func(host, path string) map[string]string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s%s: got status %d", host, path, w.Code)
+		}
+		event := map[string]string{}
+		for _, match := range property.FindAllStringSubmatch(w.Body.String(), -1) {
+			event[match[1]] = match[2]
+		}
+		return event
+	}
+	path := fmt.Sprintf("/calendar/courts/%d/bookings.ics", court.ID)
+
+	bySubdomain := fetch("main.example.com", path)
+	byPath := fetch("localhost:8000", "/c/main"+path)
+	if bySubdomain["UID"] == "" || bySubdomain["UID"] != byPath["UID"] {
+		t.Errorf("got UIDs %q and %q, want the same UID however the feed is reached", bySubdomain["UID"], byPath["UID"])
+	}
+	if bySubdomain["SEQUENCE"] != "0" {
+		t.Errorf("got sequence %q for a new booking, want 0", bySubdomain["SEQUENCE"])
+	}
+	stamp, err := time.Parse("20060102T150405Z", bySubdomain["DTSTAMP"])
+	if err != nil || time.Since(stamp) > time.Minute {
+		t.Errorf("got DTSTAMP %q, want the time the feed was generated", bySubdomain["DTSTAMP"])
+	}
+
+	moved := tomorrowAt(12)
+	if _, _, err := models.RescheduleBooking(db, booking.ID, court.ID, moved, moved.Add(time.Hour), player.ID); err != nil {
+		t.Fatal(err)
+	}
+	if event := fetch("main.example.com", path); event["SEQUENCE"] != "1" || event["UID"] != bySubdomain["UID"] {
+		t.Errorf("after a reschedule got sequence %q and UID %q, want 1 and the same UID", event["SEQUENCE"], event["UID"])
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"strconv"
+	"github.com/gin-gonic/gin"
+)
+
+// clubCourts restricts a query on courts to the current club's facilities
+const clubCourts = "facility_id IN (SELECT id FROM facilities WHERE club_id = ?)"
+
+// ListClubsHandler lists every club hosted on this deployment
+func ListClubsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		clubs, err := models.GetAllClubs(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clubs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, clubs)
+	}
+}
+
+// CreateClubHandler adds a club, reachable straight away at its slug
+func CreateClubHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		var club models.Club
+		if err := c.ShouldBindJSON(&club); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := models.CreateClub(db, &club); err != nil {
+			respondClubError(c, err, "Failed to create club")
+			return
+		}
+
+		c.JSON(http.StatusCreated, club)
+	}
+}
+
+// UpdateClubHandler changes a club's name, slug and branding
+func UpdateClubHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		clubID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid club ID"})
+			return
+		}
+
+		var club models.Club
+		if err := c.ShouldBindJSON(&club); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		club.ID = clubID
+
+		if err := models.UpdateClub(db, &club); err != nil {
+			respondClubError(c, err, "Failed to update club")
+			return
+		}
+
+		c.JSON(http.StatusOK, club)
+	}
+}
+
+// DeleteClubHandler removes a club that has no users or courts left
+func DeleteClubHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		clubID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid club ID"})
+			return
+		}
+
+		if err := models.DeleteClub(db, clubID); err != nil {
+			respondClubError(c, err, "Failed to delete club")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Club deleted successfully"})
+	}
+}
+
+// CreateClubAdminHandler creates the first admin of a club, who can then
+// manage its users, facilities and courts
+func CreateClubAdminHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		club, err := models.GetClubByID(db, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Club not found"})
+			return
+		}
+
+		var user models.User
+		if err := c.ShouldBindJSON(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.ClubID = club.ID
+		user.Role = models.RoleAdmin
+		user.FacilityID = 0
+
+		if err := models.CreateUser(db, &user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// requireSuperAdmin writes an error response unless the current user
+// manages the clubs themselves
+func requireSuperAdmin(c *gin.Context) bool {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RoleSuperAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	return true
+}
+
+// currentClubID returns the ID of the club the request is for
+func currentClubID(c *gin.Context) int64 {
+	if club := middleware.GetCurrentClub(c); club != nil {
+		return club.ID
+	}
+	return 0
+}
+
+// respondClubError writes a club error as JSON
+func respondClubError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidClub):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrClubInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err.Error() == "club not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Club not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
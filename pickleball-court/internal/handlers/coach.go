@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"pickleball-court/internal/middleware"
 	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
 	"github.com/gin-gonic/gin"
 	"time"
 )
@@ -65,7 +67,7 @@ func CoachDashboardHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		// Get all available courts
-		courts, err := models.GetAvailableCourts(db)
+		courts, err := models.GetAvailableCourts(db, currentClubID(c))
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load courts"})
 			return
@@ -78,12 +80,21 @@ func CoachDashboardHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Unread notifications, shown as a badge on the inbox
+		unreadNotifications, err := models.CountUnreadNotifications(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load notifications"})
+			return
+		}
+
 		c.HTML(http.StatusOK, "coach_dashboard.html", gin.H{
 			"title": "Coach Dashboard",
+			"club":  middleware.GetCurrentClub(c),
 			"user":  user,
 			"stats": stats,
 			"courts": courts,
 			"sessions": sessions,
+			"unreadNotifications": unreadNotifications,
 		})
 	}
 }
@@ -105,20 +116,10 @@ func CreateTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 
 		session.CoachID = user.ID
 
-		// Validate time slot availability
-		available, err := models.IsCourtAvailable(db, session.CourtID, session.StartTime, session.EndTime)
+		// Availability is checked inside the same transaction as the insert
+		err := models.CreateTrainingSession(db, &session)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check court availability"})
-			return
-		}
-		if !available {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Court is not available for the selected time slot"})
-			return
-		}
-
-		err = models.CreateTrainingSession(db, &session)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create training session"})
+			respondBookingError(c, err, "Failed to create training session")
 			return
 		}
 
@@ -142,7 +143,7 @@ func UpdateTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		// Verify the session belongs to this coach
-		existingSession, err := models.GetTrainingSessionByID(db, session.ID)
+		existingSession, err := models.GetTrainingSessionByID(db, currentClubID(c), session.ID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
 			return
@@ -154,6 +155,10 @@ func UpdateTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 
 		// Check if the time slot is still available
 		if session.StartTime != existingSession.StartTime || session.EndTime != existingSession.EndTime {
+			if err := models.CheckCourtOpen(db, session.CourtID, session.StartTime, session.EndTime); err != nil {
+				respondBookingError(c, err, "Failed to check court hours")
+				return
+			}
 			available, err := models.IsCourtAvailable(db, session.CourtID, session.StartTime, session.EndTime)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check court availability"})
@@ -187,7 +192,7 @@ func DeleteTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 		sessionID := c.Param("id")
 
 		// Verify the session belongs to this coach
-		session, err := models.GetTrainingSessionByID(db, sessionID)
+		session, err := models.GetTrainingSessionByID(db, currentClubID(c), sessionID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
 			return
@@ -203,11 +208,20 @@ func DeleteTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Load the players to tell before their enrollments go with the session
+		participants, err := models.GetTrainingSessionParticipantIDs(db, session.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete training session"})
+			return
+		}
+
 		err = models.DeleteTrainingSession(db, sessionID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete training session"})
 			return
 		}
+		notify.TrainingCancelled(db, participants, session)
+		webhook.TrainingCancelled(db, participants, session)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Training session deleted successfully"})
 	}
@@ -223,7 +237,7 @@ func GetTrainingSessionHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		sessionID := c.Param("id")
-		session, err := models.GetTrainingSessionByID(db, sessionID)
+		session, err := models.GetTrainingSessionByID(db, currentClubID(c), sessionID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
 			return
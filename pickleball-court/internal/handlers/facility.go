@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"strconv"
+	"github.com/gin-gonic/gin"
+)
+
+// ListFacilitiesHandler lists the club's facilities so players can pick a
+// venue
+func ListFacilitiesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facilities, err := models.GetAllFacilities(db, currentClubID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load facilities"})
+			return
+		}
+
+		c.JSON(http.StatusOK, facilities)
+	}
+}
+
+// CreateFacilityHandler adds a facility. Only club-wide admins may do this.
+func CreateFacilityHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
+		var facility models.Facility
+		if err := c.ShouldBindJSON(&facility); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		facility.ClubID = currentClubID(c)
+
+		if err := models.CreateFacility(db, &facility); err != nil {
+			respondFacilityError(c, err, "Failed to create facility")
+			return
+		}
+
+		c.JSON(http.StatusCreated, facility)
+	}
+}
+
+// UpdateFacilityHandler changes a facility's details, timezone and booking
+// rules. Facility admins may only update their own facility.
+func UpdateFacilityHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facilityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid facility ID"})
+			return
+		}
+		if !requireClubFacility(c, db, facilityID) || !requireFacility(c, facilityID) {
+			return
+		}
+
+		var facility models.Facility
+		if err := c.ShouldBindJSON(&facility); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		facility.ID = facilityID
+
+		if err := models.UpdateFacility(db, &facility); err != nil {
+			respondFacilityError(c, err, "Failed to update facility")
+			return
+		}
+
+		c.JSON(http.StatusOK, facility)
+	}
+}
+
+// DeleteFacilityHandler removes a facility that no longer has courts
+func DeleteFacilityHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
+		facilityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid facility ID"})
+			return
+		}
+		if !requireClubFacility(c, db, facilityID) {
+			return
+		}
+
+		if err := models.DeleteFacility(db, facilityID); err != nil {
+			respondFacilityError(c, err, "Failed to delete facility")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Facility deleted successfully"})
+	}
+}
+
+// requireClubAdmin writes an error response unless the current user is an
+// admin not tied to a single facility
+func requireClubAdmin(c *gin.Context) (*models.User, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || !user.IsClubAdmin() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return user, true
+}
+
+// requireFacility writes an error response unless the current user
+// administers the given facility
+func requireFacility(c *gin.Context, facilityID int64) bool {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || !user.ManagesFacility(facilityID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	return true
+}
+
+// requireClubFacility writes an error response unless the given facility
+// belongs to the current club
+func requireClubFacility(c *gin.Context, db *sql.DB, facilityID int64) bool {
+	_, err := models.GetFacilityByID(db, currentClubID(c), facilityID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Facility not found"})
+		return false
+	}
+	return true
+}
+
+// respondFacilityError writes a facility error as JSON
+func respondFacilityError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidFacility):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrFacilityInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err.Error() == "facility not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Facility not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
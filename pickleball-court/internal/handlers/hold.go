@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// CreateSlotHoldHandler holds a slot while the player confirms the booking
+func CreateSlotHoldHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			CourtID         int64     `json:"court_id" binding:"required"`
+			StartTime       time.Time `json:"start_time" binding:"required"`
+			DurationMinutes int       `json:"duration_minutes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Default to a single slot when no duration is given
+		duration := config.Get().GetSlotDuration()
+		if req.DurationMinutes > 0 {
+			duration = time.Duration(req.DurationMinutes) * time.Minute
+		}
+
+		hold := models.SlotHold{
+			UserID:    user.ID,
+			CourtID:   req.CourtID,
+			StartTime: req.StartTime,
+			EndTime:   req.StartTime.Add(duration),
+		}
+		if err := models.CreateSlotHold(db, &hold); err != nil {
+			respondBookingError(c, err, "Failed to hold slot")
+			return
+		}
+
+		c.JSON(http.StatusCreated, hold)
+	}
+}
+
+// ConfirmSlotHoldHandler turns the player's hold into a booking
+func ConfirmSlotHoldHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hold, ok := ownSlotHold(c, db)
+		if !ok {
+			return
+		}
+
+		booking, err := models.ConfirmSlotHold(db, hold.ID, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrSlotHoldExpired) {
+				c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+				return
+			}
+			respondBookingError(c, err, "Failed to create booking")
+			return
+		}
+		notify.BookingCreated(db, booking)
+
+		c.JSON(http.StatusCreated, booking)
+	}
+}
+
+// ReleaseSlotHoldHandler gives up the player's hold
+func ReleaseSlotHoldHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hold, ok := ownSlotHold(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.ReleaseSlotHold(db, hold.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release hold"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Hold released"})
+	}
+}
+
+// ownSlotHold loads the slot hold in the URL and checks that it belongs to
+// the current player, writing an error response if not
+func ownSlotHold(c *gin.Context, db *sql.DB) (*models.SlotHold, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RolePlayer {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	hold, err := models.GetSlotHoldByID(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found"})
+		return nil, false
+	}
+	if hold.UserID != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return hold, true
+}
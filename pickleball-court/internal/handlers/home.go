@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"pickleball-court/internal/middleware"
 	"time"
 	"github.com/gin-gonic/gin"
 )
@@ -12,14 +13,16 @@ func HomeHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, _ := c.Get("user")
 		
-		// Get some basic stats for the home page
+		// Get some basic stats for the club's home page
+		clubID := currentClubID(c)
 		var courtCount, userCount, bookingCount int
-		db.QueryRow("SELECT COUNT(*) FROM courts").Scan(&courtCount)
-		db.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
-		db.QueryRow("SELECT COUNT(*) FROM bookings WHERE status != 'cancelled'").Scan(&bookingCount)
+		db.QueryRow("SELECT COUNT(*) FROM courts WHERE "+clubCourts, clubID).Scan(&courtCount)
+		db.QueryRow("SELECT COUNT(*) FROM users WHERE club_id = ?", clubID).Scan(&userCount)
+		db.QueryRow("SELECT COUNT(*) FROM bookings WHERE court_id IN (SELECT id FROM courts WHERE "+clubCourts+") AND status NOT IN ('cancelled', 'rejected', 'no_show')", clubID).Scan(&bookingCount)
 
 		c.HTML(http.StatusOK, "home.html", gin.H{
 			"title": "Welcome to PickleCourt",
+			"club":  middleware.GetCurrentClub(c),
 			"user": user,
 			"currentYear": time.Now().Year(),
 			"stats": gin.H{
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobsHandler lists the background jobs, soonest due first. Pass
+// ?status=pending or ?status=failed to see only those.
+func ListJobsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		status := c.Query("status")
+		switch status {
+		case "", models.JobStatusPending, models.JobStatusRunning, models.JobStatusFailed:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job status"})
+			return
+		}
+
+		jobs, err := models.GetJobs(db, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
+// JobsPageHandler displays the jobs that are waiting to run or whose last
+// run failed, with a button to run each one now
+func JobsPageHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleSuperAdmin {
+			c.HTML(http.StatusForbidden, "error.html", gin.H{"code": http.StatusForbidden})
+			return
+		}
+
+		pending, err := models.GetJobs(db, models.JobStatusPending)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load jobs"})
+			return
+		}
+		failed, err := models.GetJobs(db, models.JobStatusFailed)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load jobs"})
+			return
+		}
+
+		c.HTML(http.StatusOK, "super_jobs.html", gin.H{
+			"title":   "Background Jobs",
+			"user":    user,
+			"pending": pending,
+			"failed":  failed,
+		})
+	}
+}
+
+// RunJobHandler makes a job due straight away, for retrying one that failed
+func RunJobHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireSuperAdmin(c) {
+			return
+		}
+
+		job, err := models.GetJobByID(db, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		if err := models.RunJobNow(db, job.ID, time.Now()); err != nil {
+			if errors.Is(err, models.ErrJobRunning) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run job"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Job will run shortly"})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// ListCourtMaintenanceHandler lists a court's current and upcoming
+// maintenance windows
+func ListCourtMaintenanceHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		court, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
+		windows, err := models.GetMaintenanceWindows(db, court.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load maintenance windows"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"court": court, "maintenance": windows})
+	}
+}
+
+// ScheduleMaintenanceHandler takes a court out of use for maintenance.
+// Upcoming bookings in the window are moved to another court or cancelled,
+// and their players are notified.
+func ScheduleMaintenanceHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		court, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			StartTime time.Time `json:"start_time" binding:"required"`
+			EndTime   time.Time `json:"end_time" binding:"required"`
+			Reason    string    `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := models.ScheduleMaintenance(db, &models.CourtBlackout{
+			CourtID:   court.ID,
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+			Reason:    req.Reason,
+			CreatedBy: user.ID,
+		}, time.Now())
+		if err != nil {
+			respondScheduleError(c, err, "Failed to schedule maintenance")
+			return
+		}
+		NotifyMaintenanceChanges(db, result)
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+// NotifyMaintenanceChanges tells the booker and accepted participants of
+// each displaced booking that it was moved or cancelled, and passes freed
+// slots on to the waitlist
+func NotifyMaintenanceChanges(db *sql.DB, result *models.MaintenanceResult) {
+	var bookings []*models.Booking
+	for _, change := range result.Moved {
+		bookings = append(bookings, change.Booking)
+	}
+	for _, change := range result.Cancelled {
+		bookings = append(bookings, change.Booking)
+	}
+	if err := models.AttachParticipants(db, bookings); err != nil {
+		log.Printf("Maintenance: failed to load participants: %v\n", err)
+	}
+
+	for _, change := range result.Moved {
+		old := *change.Booking
+		if court, err := models.GetCourtByID(db, change.Booking.ClubID, change.OldCourtID); err == nil {
+			old.CourtName = court.Name
+		}
+		notify.BookingRescheduled(db, change.Booking, &old, result.Window.Reason, change.Booking.Participants)
+	}
+	for _, change := range result.Cancelled {
+		notify.BookingCancelled(db, change.Booking, result.Window.Reason, change.Booking.Participants)
+		NotifyWaitlistOffers(db, change.Cancellation.WaitlistOffers)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"strconv"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// notificationPageSize is how many notifications the inbox lists
+const notificationPageSize = 50
+
+// ListNotificationsHandler lists the current user's latest notifications
+// with their unread count. Pass ?unread=true to see only unread ones.
+func ListNotificationsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		unreadOnly := c.Query("unread") == "true"
+		notifications, err := models.GetUserNotifications(db, user.ID, unreadOnly, notificationPageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+			return
+		}
+		unread, err := models.CountUnreadNotifications(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread": unread})
+	}
+}
+
+// MarkNotificationReadHandler marks one of the current user's
+// notifications as read
+func MarkNotificationReadHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			return
+		}
+
+		err = models.MarkNotificationRead(db, user.ID, notificationID, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrNotificationNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	}
+}
+
+// MarkAllNotificationsReadHandler empties the current user's unread count
+func MarkAllNotificationsReadHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		marked, err := models.MarkAllNotificationsRead(db, user.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "marked": marked})
+	}
+}
+
+// GetNotificationPreferencesHandler returns where the current user gets
+// each kind of notification
+func GetNotificationPreferencesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		preferences, err := models.GetNotificationPreferences(db, user.ID, notify.Kinds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+			return
+		}
+
+		c.JSON(http.StatusOK, preferences)
+	}
+}
+
+// UpdateNotificationPreferencesHandler sets where the current user gets
+// the kinds of notification given, e.g.
+// {"booking_confirmed": {"inbox": true, "email": false}}. Kinds left out
+// keep their current setting.
+func UpdateNotificationPreferencesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var req map[string]struct {
+			Inbox bool `json:"inbox"`
+			Email bool `json:"email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var preferences []*models.NotificationPreference
+		for _, kind := range notify.Kinds {
+			if choice, ok := req[kind]; ok {
+				preferences = append(preferences, &models.NotificationPreference{
+					Kind:  kind,
+					Inbox: choice.Inbox,
+					Email: choice.Email,
+				})
+			}
+		}
+		if len(preferences) != len(req) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification kind"})
+			return
+		}
+
+		if err := models.SetNotificationPreferences(db, user.ID, preferences); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+			return
+		}
+
+		updated, err := models.GetNotificationPreferences(db, user.ID, notify.Kinds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+			return
+		}
+
+		c.JSON(http.StatusOK, updated)
+	}
+}
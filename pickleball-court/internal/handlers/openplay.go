@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// CreateOpenPlayHandler lets an admin or coach block out a court for open play
+func CreateOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || (user.Role != models.RoleAdmin && user.Role != models.RoleCoach) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			CourtID   int64     `json:"court_id" binding:"required"`
+			Title     string    `json:"title" binding:"required"`
+			StartTime time.Time `json:"start_time" binding:"required"`
+			EndTime   time.Time `json:"end_time" binding:"required"`
+			Capacity  int       `json:"capacity" binding:"required"`
+			MinSkill  float64   `json:"min_skill"`
+			MaxSkill  float64   `json:"max_skill"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if user.Role == models.RoleAdmin && !requireCourtScope(c, db, req.CourtID) {
+			return
+		}
+
+		session := &models.OpenPlaySession{
+			CourtID:   req.CourtID,
+			CreatedBy: user.ID,
+			Title:     req.Title,
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+			Capacity:  req.Capacity,
+			MinSkill:  req.MinSkill,
+			MaxSkill:  req.MaxSkill,
+		}
+		if err := models.CreateOpenPlaySession(db, session); err != nil {
+			respondOpenPlayError(c, err, "Failed to create open play session")
+			return
+		}
+
+		c.JSON(http.StatusCreated, session)
+	}
+}
+
+// CancelOpenPlayHandler cancels an open play session and frees its court.
+// Admins can cancel any session at their facility; coaches only their own,
+// under the usual cancellation rules.
+func CancelOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || (user.Role != models.RoleAdmin && user.Role != models.RoleCoach) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		session, err := models.GetOpenPlaySessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open play session not found"})
+			return
+		}
+		if user.Role != models.RoleAdmin && session.CreatedBy != user.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if user.Role == models.RoleAdmin && !requireCourtScope(c, db, session.CourtID) {
+			return
+		}
+
+		cancellation, err := models.CancelBooking(db, session.BookingID, models.CancelOptions{
+			CancelledBy: user.ID,
+			Reason:      "open play cancelled",
+			Override:    user.Role == models.RoleAdmin,
+		})
+		if err != nil {
+			respondCancelError(c, err)
+			return
+		}
+		NotifyWaitlistOffers(db, cancellation.WaitlistOffers)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Open play session cancelled"})
+	}
+}
+
+// ListOpenPlayHandler lists open play sessions that have not ended yet
+func ListOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := models.GetUpcomingOpenPlaySessions(db, currentClubID(c), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load open play sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// GetOpenPlayQueueHandler returns a session with its rotation queue, the
+// players on court first
+func GetOpenPlayQueueHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := models.GetOpenPlaySessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open play session not found"})
+			return
+		}
+
+		queue, err := models.GetOpenPlayQueue(db, session.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load queue"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"session": session, "queue": queue})
+	}
+}
+
+// JoinOpenPlayHandler signs the player up for an open play session
+func JoinOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		session, err := models.GetOpenPlaySessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open play session not found"})
+			return
+		}
+
+		signup, err := models.SignUpForOpenPlay(db, session.ID, user.ID, time.Now())
+		if err != nil {
+			respondOpenPlayError(c, err, "Failed to join open play session")
+			return
+		}
+
+		c.JSON(http.StatusCreated, signup)
+	}
+}
+
+// LeaveOpenPlayHandler takes the player out of an open play session
+func LeaveOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		session, err := models.GetOpenPlaySessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open play session not found"})
+			return
+		}
+
+		if err := models.LeaveOpenPlay(db, session.ID, user.ID); err != nil {
+			respondOpenPlayError(c, err, "Failed to leave open play session")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Left open play session"})
+	}
+}
+
+// RotateOpenPlayHandler ends the current game and brings the next players
+// on court. The organizer, admins, staff and anyone signed up can rotate.
+func RotateOpenPlayHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		session, err := models.GetOpenPlaySessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open play session not found"})
+			return
+		}
+
+		allowed := user.Role == models.RoleAdmin || user.Role == models.RoleStaff || session.CreatedBy == user.ID
+		if !allowed {
+			queue, err := models.GetOpenPlayQueue(db, session.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load queue"})
+				return
+			}
+			for _, signup := range queue {
+				if signup.UserID == user.ID {
+					allowed = true
+				}
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		queue, err := models.RotateOpenPlay(db, session.ID, time.Now())
+		if err != nil {
+			respondOpenPlayError(c, err, "Failed to rotate players")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"session": session, "queue": queue})
+	}
+}
+
+// respondOpenPlayError writes an open play error as JSON
+func respondOpenPlayError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrOpenPlayFull),
+		errors.Is(err, models.ErrAlreadySignedUp):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrOpenPlayClosed),
+		errors.Is(err, models.ErrSkillOutOfRange),
+		errors.Is(err, models.ErrNotSignedUp),
+		errors.Is(err, models.ErrInvalidOpenPlay):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondBookingError(c, err, fallback)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// ListParticipantsHandler returns the roster of a booking. The booker and
+// anyone on the roster can see it.
+func ListParticipantsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		participants, err := models.GetBookingParticipants(db, booking.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participants"})
+			return
+		}
+
+		allowed := booking.UserID == user.ID
+		for _, participant := range participants {
+			if participant.UserID == user.ID {
+				allowed = true
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking.Participants = participants
+		c.JSON(http.StatusOK, booking)
+	}
+}
+
+// AddParticipantHandler invites a member by username, or adds a guest by
+// name and email, to one of the player's bookings
+func AddParticipantHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := ownBooking(c, db)
+		if !ok {
+			return
+		}
+		user := middleware.GetCurrentUser(c)
+
+		var req struct {
+			Username   string `json:"username"`
+			GuestName  string `json:"guest_name"`
+			GuestEmail string `json:"guest_email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var participant *models.BookingParticipant
+		var err error
+		if req.Username != "" {
+			invitee, lookupErr := models.GetUserByUsername(db, req.Username)
+			if lookupErr != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			participant, err = models.InviteParticipant(db, booking.ID, invitee.ID, user.ID)
+		} else {
+			participant, err = models.AddGuest(db, booking.ID, req.GuestName, req.GuestEmail, user.ID)
+		}
+		if err != nil {
+			respondParticipantError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, participant)
+	}
+}
+
+// RemoveParticipantHandler takes someone off a booking. The booker can
+// remove anyone; participants can remove themselves.
+func RemoveParticipantHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		participant, err := models.GetParticipantByID(db, c.Param("participant_id"))
+		if err != nil || participant.BookingID != booking.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Participant not found"})
+			return
+		}
+		if booking.UserID != user.ID && participant.UserID != user.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := models.RemoveParticipant(db, participant.ID, time.Now()); err != nil {
+			respondParticipantError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Participant removed"})
+	}
+}
+
+// ListInvitationsHandler lists the player's open invitations
+func ListInvitationsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		invitations, bookings, err := models.GetUserInvitations(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invitations": invitations, "bookings": bookings})
+	}
+}
+
+// AcceptInvitationHandler adds the player to the booking they were invited to
+func AcceptInvitationHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invitation, ok := ownInvitation(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.AcceptInvitation(db, invitation.ID, time.Now()); err != nil {
+			respondParticipantError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted"})
+	}
+}
+
+// DeclineInvitationHandler turns an invitation down
+func DeclineInvitationHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invitation, ok := ownInvitation(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.DeclineInvitation(db, invitation.ID, time.Now()); err != nil {
+			respondParticipantError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+	}
+}
+
+// ownInvitation loads the invitation in the URL and checks that it was sent
+// to the current player, writing an error response if not
+func ownInvitation(c *gin.Context, db *sql.DB) (*models.BookingParticipant, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RolePlayer {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	invitation, err := models.GetParticipantByID(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return nil, false
+	}
+	if invitation.UserID != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return invitation, true
+}
+
+// respondParticipantError writes a roster error as JSON
+func respondParticipantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrBookingFull),
+		errors.Is(err, models.ErrAlreadyParticipant):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvitationNotOpen),
+		errors.Is(err, models.ErrInvalidParticipant),
+		errors.Is(err, models.ErrGuestDetailsMissing),
+		errors.Is(err, models.ErrNotReschedulable),
+		errors.Is(err, models.ErrBookingStarted):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondBookingError(c, err, "Failed to update participants")
+	}
+}
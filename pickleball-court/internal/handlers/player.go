@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"pickleball-court/config"
 	"pickleball-court/internal/middleware"
 	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
 	"github.com/gin-gonic/gin"
 	"time"
 )
@@ -24,13 +28,14 @@ func PlayerDashboardHandler(db *sql.DB) gin.HandlerFunc {
 			UpcomingBookings  int
 			HoursPlayed      float64
 			TrainingSessions int
+			NoShows          int
 		}
 
 		// Get upcoming bookings count
 		err := db.QueryRow(`
 			SELECT COUNT(*) 
 			FROM bookings 
-			WHERE user_id = ? AND end_time > CURRENT_TIMESTAMP AND status != 'cancelled'
+			WHERE user_id = ? AND end_time > CURRENT_TIMESTAMP AND status NOT IN ('cancelled', 'rejected', 'no_show')
 		`, user.ID).Scan(&stats.UpcomingBookings)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
@@ -45,13 +50,22 @@ func PlayerDashboardHandler(db *sql.DB) gin.HandlerFunc {
 				AS REAL), 2)
 			), 0)
 			FROM bookings
-			WHERE user_id = ? AND end_time <= CURRENT_TIMESTAMP AND status = 'confirmed'
+			WHERE user_id = ? AND end_time <= CURRENT_TIMESTAMP AND status IN ('confirmed', 'completed')
 		`, user.ID).Scan(&stats.HoursPlayed)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
 			return
 		}
 
+		// Get no-show count
+		err = db.QueryRow(`
+			SELECT COUNT(*) FROM bookings WHERE user_id = ? AND status = 'no_show'
+		`, user.ID).Scan(&stats.NoShows)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load statistics"})
+			return
+		}
+
 		// Get training sessions count
 		err = db.QueryRow(`
 			SELECT COUNT(*) 
@@ -65,38 +79,122 @@ func PlayerDashboardHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		// Get available courts with time slots
-		courts, err := models.GetAvailableCourts(db)
+		courts, err := models.GetAvailableCourts(db, currentClubID(c))
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load courts"})
 			return
 		}
 
-		// Get user's bookings
+		// Get user's bookings with their rosters
 		bookings, err := models.GetUserBookings(db, user.ID)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load bookings"})
 			return
 		}
 
+		// Get bookings the user has joined as a participant
+		joinedBookings, err := models.GetParticipatingBookings(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load bookings"})
+			return
+		}
+
+		if err := models.AttachParticipants(db, append(bookings, joinedBookings...)); err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load bookings"})
+			return
+		}
+
+		// Get open invitations to other players' bookings
+		invitations, invitationBookings, err := models.GetUserInvitations(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load invitations"})
+			return
+		}
+
+		// Get waitlist entries, including slots being held for the player
+		waitlist, err := models.GetUserWaitlist(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load waitlist"})
+			return
+		}
+
+		// Get pending booking transfers to and from the player
+		transfers, err := models.GetPendingTransfers(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load transfers"})
+			return
+		}
+
+		// Get upcoming open play and the sessions the player has joined
+		openPlaySessions, err := models.GetUpcomingOpenPlaySessions(db, currentClubID(c), time.Now())
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load open play sessions"})
+			return
+		}
+		openPlaySignups, err := models.GetUserOpenPlaySignups(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load open play sessions"})
+			return
+		}
+		joinedOpenPlay := make(map[int64]bool)
+		for _, signup := range openPlaySignups {
+			joinedOpenPlay[signup.SessionID] = true
+		}
+
 		// Get available training sessions
-		trainingSessions, err := models.GetAvailableTrainingSessions(db)
+		trainingSessions, err := models.GetAvailableTrainingSessions(db, currentClubID(c))
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load training sessions"})
 			return
 		}
 
+		// Unread notifications, shown as a badge on the inbox
+		unreadNotifications, err := models.CountUnreadNotifications(db, user.ID)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load notifications"})
+			return
+		}
+
 		c.HTML(http.StatusOK, "player_dashboard.html", gin.H{
 			"title": "Player Dashboard",
+			"club":  middleware.GetCurrentClub(c),
 			"user":  user,
 			"stats": stats,
 			"courts": courts,
 			"bookings": bookings,
+			"joinedBookings": joinedBookings,
+			"invitations": invitations,
+			"invitationBookings": invitationBookings,
+			"waitlist": waitlist,
+			"transfers": transfers,
+			"openPlaySessions": openPlaySessions,
+			"joinedOpenPlay": joinedOpenPlay,
 			"trainingSessions": trainingSessions,
+			"unreadNotifications": unreadNotifications,
 			"today": time.Now().Format("2006-01-02"),
 		})
 	}
 }
 
+// SearchCourtsHandler lists the courts at every facility matching the
+// filters in the query string, e.g. ?facility_id=2&indoor=true
+func SearchCourtsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := courtFilterParams(c)
+		if !ok {
+			return
+		}
+
+		courts, err := models.GetCourts(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load courts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, courts)
+	}
+}
+
 // CreateBookingHandler handles court booking creation
 func CreateBookingHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -106,35 +204,38 @@ func CreateBookingHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var booking models.Booking
-		if err := c.ShouldBindJSON(&booking); err != nil {
+		var req struct {
+			CourtID         int64     `json:"court_id" binding:"required"`
+			StartTime       time.Time `json:"start_time" binding:"required"`
+			DurationMinutes int       `json:"duration_minutes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		booking.UserID = user.ID
-		booking.Status = models.BookingStatusPending
-		booking.BookingType = models.BookingTypeRegular
-
-		// Set end time to 1 hour after start time
-		booking.EndTime = booking.StartTime.Add(time.Hour)
-
-		// Validate time slot availability
-		available, err := models.IsCourtAvailable(db, booking.CourtID, booking.StartTime, booking.EndTime)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check court availability"})
-			return
+		// Default to a single slot when no duration is given
+		duration := config.Get().GetSlotDuration()
+		if req.DurationMinutes > 0 {
+			duration = time.Duration(req.DurationMinutes) * time.Minute
 		}
-		if !available {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Court is not available for the selected time slot"})
-			return
+
+		booking := models.Booking{
+			CourtID:     req.CourtID,
+			UserID:      user.ID,
+			StartTime:   req.StartTime,
+			EndTime:     req.StartTime.Add(duration),
+			Status:      models.BookingStatusPending,
+			BookingType: models.BookingTypeRegular,
 		}
 
-		err = models.CreateBooking(db, &booking)
+		// Availability is checked inside the same transaction as the insert
+		err := models.CreateBooking(db, &booking)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			respondBookingError(c, err, "Failed to create booking")
 			return
 		}
+		notify.BookingCreated(db, &booking)
 
 		c.JSON(http.StatusOK, booking)
 	}
@@ -152,7 +253,7 @@ func CancelBookingHandler(db *sql.DB) gin.HandlerFunc {
 		bookingID := c.Param("id")
 
 		// Verify the booking belongs to this user
-		booking, err := models.GetBookingByID(db, bookingID)
+		booking, err := models.GetBookingByID(db, currentClubID(c), bookingID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
@@ -162,13 +263,207 @@ func CancelBookingHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		err = models.CancelBooking(db, bookingID)
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		cancellation, err := models.CancelBooking(db, bookingID, models.CancelOptions{
+			CancelledBy: user.ID,
+			Reason:      req.Reason,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+			respondCancelError(c, err)
 			return
 		}
+		notify.BookingCancelled(db, booking, req.Reason, nil)
+		NotifyWaitlistOffers(db, cancellation.WaitlistOffers)
 
-		c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled successfully", "cancellation": cancellation})
+	}
+}
+
+// CreateBookingSeriesHandler handles creation of a recurring booking series
+func CreateBookingSeriesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			CourtID         int64     `json:"court_id" binding:"required"`
+			StartTime       time.Time `json:"start_time" binding:"required"`
+			DurationMinutes int       `json:"duration_minutes"`
+			Frequency       string    `json:"frequency" binding:"required"`
+			Until           time.Time `json:"until"`
+			Count           int       `json:"count"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		duration := config.Get().GetSlotDuration()
+		if req.DurationMinutes > 0 {
+			duration = time.Duration(req.DurationMinutes) * time.Minute
+		}
+
+		series := models.BookingSeries{
+			UserID:    user.ID,
+			CourtID:   req.CourtID,
+			StartTime: req.StartTime,
+			EndTime:   req.StartTime.Add(duration),
+			Frequency: req.Frequency,
+			Until:     req.Until,
+			Count:     req.Count,
+		}
+		if _, err := series.Occurrences(config.Get().GetTimeZone()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		bookings, conflicts, err := models.CreateBookingSeries(db, &series, models.BookingStatusPending)
+		if err != nil {
+			if len(conflicts) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflicts})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking series"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"series":    series,
+			"bookings":  bookings,
+			"conflicts": conflicts,
+		})
+	}
+}
+
+// GetBookingSeriesHandler returns a series with all of its occurrences
+func GetBookingSeriesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		series, err := models.GetBookingSeriesByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking series not found"})
+			return
+		}
+		if series.UserID != user.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		bookings, err := models.GetSeriesBookings(db, series.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series bookings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"series": series, "bookings": bookings})
+	}
+}
+
+// CancelBookingSeriesHandler cancels the remaining occurrences of a series.
+// Single occurrences are cancelled through CancelBookingHandler.
+func CancelBookingSeriesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		series, err := models.GetBookingSeriesByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking series not found"})
+			return
+		}
+		if series.UserID != user.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// Cancel from the given time onwards, or every future occurrence
+		var req struct {
+			From time.Time `json:"from"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		// Occurrences inside the notice window must be cancelled one by one
+		from := time.Now().Add(config.Get().GetCancellationNoticeRequired())
+		if req.From.After(from) {
+			from = req.From
+		}
+
+		cancelled, err := models.CancelSeriesFrom(db, series.ID, from, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking series"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Booking series cancelled successfully", "cancelled": cancelled})
+	}
+}
+
+// CheckInBookingHandler checks a player in for a booking. Players can check
+// in to their own bookings; admins and front-desk staff can check in anyone
+// at their facility.
+func CheckInBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if booking.UserID != user.ID && user.Role != models.RoleAdmin && user.Role != models.RoleStaff {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// Staff and admins tied to a venue only check in at that venue
+		if booking.UserID != user.ID && user.FacilityID != 0 && user.FacilityID != booking.FacilityID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		err = models.CheckInBooking(db, booking.ID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrCheckInNotOpen),
+				errors.Is(err, models.ErrCheckInClosed),
+				errors.Is(err, models.ErrAlreadyCheckedIn),
+				errors.Is(err, models.ErrNotCheckable):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Checked in successfully"})
 	}
 }
 
@@ -181,10 +476,14 @@ func EnrollTrainingHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		sessionID := c.Param("id")
+		session, err := models.GetTrainingSessionByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Training session not found"})
+			return
+		}
 
 		// Check if already enrolled
-		enrolled, err := models.IsUserEnrolled(db, user.ID, sessionID)
+		enrolled, err := models.IsUserEnrolled(db, user.ID, session.ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check enrollment status"})
 			return
@@ -194,11 +493,19 @@ func EnrollTrainingHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		err = models.EnrollInTrainingSession(db, user.ID, sessionID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in training session"})
+		if err := models.EnrollInTrainingSession(db, user.ID, session.ID); err != nil {
+			switch {
+			case errors.Is(err, models.ErrOtherClub):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Training session not found"})
+			case errors.Is(err, models.ErrTrainingSessionFull), errors.Is(err, models.ErrAlreadyEnrolled):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in training session"})
+			}
 			return
 		}
+		notify.TrainingEnrolled(db, user.ID, session)
+		webhook.TrainingEnrolled(db, user.ID, session)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Successfully enrolled in training session"})
 	}
@@ -235,3 +542,35 @@ func CancelTrainingEnrollmentHandler(db *sql.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Successfully cancelled enrollment"})
 	}
 }
+
+// respondBookingError writes a booking error as JSON, exposing policy
+// violations to the client and hiding everything else behind fallback
+func respondBookingError(c *gin.Context, err error, fallback string) {
+	var policyErr *models.PolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Message, "code": policyErr.Rule})
+		return
+	}
+	if errors.Is(err, models.ErrCourtUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Court is not available for the selected time slot"})
+		return
+	}
+	if errors.Is(err, models.ErrOtherClub) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Court or player not found at this club"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// respondCancelError writes a cancellation error as JSON
+func respondCancelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrBookingAlreadyCancelled),
+		errors.Is(err, models.ErrInvalidTransition),
+		errors.Is(err, models.ErrBookingStarted),
+		errors.Is(err, models.ErrLateCancelReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+	}
+}
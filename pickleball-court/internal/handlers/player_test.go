@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openTestDB opens a fresh file-backed database, so that concurrent
+// requests use separate connections as they do in production
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.Load()
+
+	db, err := models.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser creates a user in a club
+func createTestUser(t *testing.T, db *sql.DB, clubID int64, username, role string) *models.User {
+	t.Helper()
+	user := &models.User{
+		Username: username,
+		Password: "password",
+		Email:    username + "@example.com",
+		Role:     role,
+		ClubID:   clubID,
+	}
+	if err := models.CreateUser(db, user); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestCourt creates a court in a club's first facility
+func createTestCourt(t *testing.T, db *sql.DB, clubID int64, name string) *models.Court {
+	t.Helper()
+	court := &models.Court{Name: name, ClubID: clubID}
+	if err := models.CreateCourt(db, court); err != nil {
+		t.Fatalf("create court %s: %v", name, err)
+	}
+	return court
+}
+
+// tomorrowAt returns the given hour tomorrow in the configured timezone
+func tomorrowAt(hour int) time.Time {
+	now := time.Now().In(config.Get().GetTimeZone())
+	return time.Date(now.Year(), now.Month(), now.Day()+1, hour, 0, 0, 0, now.Location())
+}
+
+func TestCreateBookingConcurrentRequestsForSameSlot(t *testing.T) {
+	db := openTestDB(t)
+	court := createTestCourt(t, db, 1, "Court 1")
+
+	const requests = 20
+	players := make([]*models.User, requests)
+	for i := range players {
+		players[i] = createTestUser(t, db, 1, fmt.Sprintf("player%d", i), models.RolePlayer)
+	}
+
+	// Each request is made by a different player, so that only the overlap
+	// check can turn the losers away
+	router := gin.New()
+	router.POST("/bookings", func(c *gin.Context) {
+		var index int
+		fmt.Sscan(c.GetHeader("X-Player"), &index)
+		c.Set("user", players[index])
+	}, CreateBookingHandler(db))
+
+	body, _ := json.Marshal(gin.H{"court_id": court.ID, "start_time": tomorrowAt(10)})
+
+	codes := make([]int, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Player", fmt.Sprint(i))
+			w := httptest.NewRecorder()
+			<-start
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created, conflicts := 0, 0
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("request %d: got status %d, want 200 or 409", i, code)
+		}
+	}
+	if created != 1 || conflicts != requests-1 {
+		t.Errorf("got %d created and %d conflicts, want 1 and %d", created, conflicts, requests-1)
+	}
+
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM bookings WHERE court_id = ?`, court.ID).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("got %d booking rows, want 1", rows)
+	}
+}
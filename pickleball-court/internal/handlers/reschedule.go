@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// RescheduleBookingHandler moves one of the player's bookings to another
+// time or court. Leaving out court_id keeps the current court and leaving
+// out duration_minutes keeps the current length.
+func RescheduleBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := ownBooking(c, db)
+		if !ok {
+			return
+		}
+		user := middleware.GetCurrentUser(c)
+
+		var req struct {
+			CourtID         int64     `json:"court_id"`
+			StartTime       time.Time `json:"start_time" binding:"required"`
+			DurationMinutes int       `json:"duration_minutes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		duration := booking.EndTime.Sub(booking.StartTime)
+		if req.DurationMinutes > 0 {
+			duration = time.Duration(req.DurationMinutes) * time.Minute
+		}
+
+		moved, offers, err := models.RescheduleBooking(db, booking.ID, req.CourtID,
+			req.StartTime, req.StartTime.Add(duration), user.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotReschedulable) || errors.Is(err, models.ErrBookingStarted) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			respondBookingError(c, err, "Failed to reschedule booking")
+			return
+		}
+		notify.BookingRescheduled(db, moved, booking, "", nil)
+		NotifyWaitlistOffers(db, offers)
+
+		c.JSON(http.StatusOK, moved)
+	}
+}
+
+// TransferBookingHandler offers one of the player's bookings to another
+// player by username
+func TransferBookingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := ownBooking(c, db)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Username string `json:"username" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		recipient, err := models.GetUserByUsername(db, req.Username)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		transfer, err := models.RequestBookingTransfer(db, booking.ID, recipient.ID)
+		if err != nil {
+			respondTransferError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, transfer)
+	}
+}
+
+// ListTransfersHandler lists pending transfers sent to or by the player
+func ListTransfersHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		transfers, err := models.GetPendingTransfers(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transfers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, transfers)
+	}
+}
+
+// AcceptTransferHandler lets the recipient take over a booking
+func AcceptTransferHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := transferFor(c, db, true)
+		if !ok {
+			return
+		}
+
+		booking, err := models.AcceptBookingTransfer(db, transfer.ID, time.Now())
+		if err != nil {
+			respondTransferError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, booking)
+	}
+}
+
+// DeclineTransferHandler lets the recipient turn a transfer down
+func DeclineTransferHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := transferFor(c, db, true)
+		if !ok {
+			return
+		}
+
+		if err := models.DeclineBookingTransfer(db, transfer.ID, transfer.ToUserID); err != nil {
+			respondTransferError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transfer declined"})
+	}
+}
+
+// CancelTransferHandler lets the booker withdraw a transfer
+func CancelTransferHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := transferFor(c, db, false)
+		if !ok {
+			return
+		}
+
+		if err := models.CancelBookingTransfer(db, transfer.ID, transfer.FromUserID); err != nil {
+			respondTransferError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transfer cancelled"})
+	}
+}
+
+// ownBooking loads the booking in the URL and checks that it belongs to the
+// current player, writing an error response if not
+func ownBooking(c *gin.Context, db *sql.DB) (*models.Booking, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RolePlayer {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	booking, err := models.GetBookingByID(db, currentClubID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return nil, false
+	}
+	if booking.UserID != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return booking, true
+}
+
+// transferFor loads the transfer in the URL and checks that the current
+// player is its recipient, or its sender when recipient is false
+func transferFor(c *gin.Context, db *sql.DB, recipient bool) (*models.BookingTransfer, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RolePlayer {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	transfer, err := models.GetBookingTransferByID(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return nil, false
+	}
+
+	owner := transfer.FromUserID
+	if recipient {
+		owner = transfer.ToUserID
+	}
+	if owner != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return transfer, true
+}
+
+// respondTransferError writes a transfer error as JSON
+func respondTransferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrTransferPending),
+		errors.Is(err, models.ErrTransferNotPending),
+		errors.Is(err, models.ErrInvalidRecipient),
+		errors.Is(err, models.ErrNotReschedulable),
+		errors.Is(err, models.ErrBookingStarted):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondBookingError(c, err, "Failed to transfer booking")
+	}
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// GetCourtHoursHandler returns a court's weekly opening hours
+func GetCourtHoursHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		court, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
+		hours, err := models.GetCourtHours(db, court.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load opening hours"})
+			return
+		}
+
+		c.JSON(http.StatusOK, hours)
+	}
+}
+
+// UpdateCourtHoursHandler replaces a court's weekly opening hours. Weekdays
+// left out fall back to the configured hours.
+func UpdateCourtHoursHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		court, ok := courtParam(c, db)
+		if !ok {
+			return
+		}
+
+		var req []struct {
+			Weekday  time.Weekday `json:"weekday"`
+			OpensAt  string       `json:"opens_at"`
+			ClosesAt string       `json:"closes_at"`
+			Closed   bool         `json:"closed"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		week := make([]*models.CourtHours, 0, len(req))
+		for _, day := range req {
+			week = append(week, &models.CourtHours{
+				CourtID:  court.ID,
+				Weekday:  day.Weekday,
+				OpensAt:  day.OpensAt,
+				ClosesAt: day.ClosesAt,
+				Closed:   day.Closed,
+			})
+		}
+
+		if err := models.SetCourtHours(db, court.ID, week); err != nil {
+			respondScheduleError(c, err, "Failed to update opening hours")
+			return
+		}
+
+		hours, err := models.GetCourtHours(db, court.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load opening hours"})
+			return
+		}
+
+		c.JSON(http.StatusOK, hours)
+	}
+}
+
+// ListHoursOverridesHandler lists holiday and event overrides from today on
+func ListHoursOverridesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		today := time.Now().In(config.Get().GetTimeZone()).Format("2006-01-02")
+		overrides, err := models.GetHoursOverrides(db, currentClubID(c), today)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load overrides"})
+			return
+		}
+
+		// Facility admins see club-wide overrides and their own courts'
+		user := middleware.GetCurrentUser(c)
+		visible := make([]*models.CourtHoursOverride, 0, len(overrides))
+		for _, override := range overrides {
+			if override.FacilityID == 0 || user.ManagesFacility(override.FacilityID) {
+				visible = append(visible, override)
+			}
+		}
+
+		c.JSON(http.StatusOK, visible)
+	}
+}
+
+// CreateHoursOverrideHandler changes the opening hours on a single date,
+// for one court or, without a court_id, for every court
+func CreateHoursOverrideHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			CourtID  int64  `json:"court_id"`
+			Date     string `json:"date" binding:"required"`
+			OpensAt  string `json:"opens_at"`
+			ClosesAt string `json:"closes_at"`
+			Closed   bool   `json:"closed"`
+			Reason   string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireCourtScope(c, db, req.CourtID) {
+			return
+		}
+
+		override := &models.CourtHoursOverride{
+			CourtID:  req.CourtID,
+			Date:     req.Date,
+			OpensAt:  req.OpensAt,
+			ClosesAt: req.ClosesAt,
+			Closed:   req.Closed,
+			Reason:   req.Reason,
+			ClubID:   currentClubID(c),
+		}
+		if err := models.CreateHoursOverride(db, override); err != nil {
+			respondScheduleError(c, err, "Failed to create override")
+			return
+		}
+
+		c.JSON(http.StatusCreated, override)
+	}
+}
+
+// DeleteHoursOverrideHandler removes a date override
+func DeleteHoursOverrideHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		override, err := models.GetHoursOverrideByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Override not found"})
+			return
+		}
+		if !requireCourtScope(c, db, override.CourtID) {
+			return
+		}
+
+		if err := models.DeleteHoursOverride(db, override.ID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Override not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Override deleted"})
+	}
+}
+
+// ListBlackoutsHandler lists blackout windows that have not ended
+func ListBlackoutsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blackouts, err := models.GetUpcomingBlackouts(db, currentClubID(c), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load blackouts"})
+			return
+		}
+
+		// Facility admins see club-wide blackouts and their own courts'
+		user := middleware.GetCurrentUser(c)
+		visible := make([]*models.CourtBlackout, 0, len(blackouts))
+		for _, blackout := range blackouts {
+			if blackout.FacilityID == 0 || user.ManagesFacility(blackout.FacilityID) {
+				visible = append(visible, blackout)
+			}
+		}
+
+		c.JSON(http.StatusOK, visible)
+	}
+}
+
+// PreviewBlackoutHandler lists the bookings a blackout would conflict with,
+// without saving it
+func PreviewBlackoutHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blackout, ok := bindBlackout(c, db)
+		if !ok {
+			return
+		}
+
+		conflicts, err := models.GetBlackoutConflicts(db, blackout)
+		if err != nil {
+			respondScheduleError(c, err, "Failed to check blackout conflicts")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"conflicts": conflicts})
+	}
+}
+
+// CreateBlackoutHandler saves a blackout window and returns the existing
+// bookings it overlaps
+func CreateBlackoutHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blackout, ok := bindBlackout(c, db)
+		if !ok {
+			return
+		}
+
+		conflicts, err := models.CreateBlackout(db, blackout)
+		if err != nil {
+			respondScheduleError(c, err, "Failed to create blackout")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"blackout": blackout, "conflicts": conflicts})
+	}
+}
+
+// DeleteBlackoutHandler removes a blackout window
+func DeleteBlackoutHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blackout, err := models.GetBlackoutByID(db, currentClubID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Blackout not found"})
+			return
+		}
+		if !requireCourtScope(c, db, blackout.CourtID) {
+			return
+		}
+
+		if err := models.DeleteBlackout(db, blackout.ID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Blackout not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Blackout deleted"})
+	}
+}
+
+// courtParam loads the court in the URL, writing an error response if it
+// does not exist at this club or belongs to a facility the current admin
+// does not manage
+func courtParam(c *gin.Context, db *sql.DB) (*models.Court, bool) {
+	courtID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid court ID"})
+		return nil, false
+	}
+
+	court, err := models.GetCourtByID(db, currentClubID(c), courtID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Court not found"})
+		return nil, false
+	}
+	if !requireFacility(c, court.FacilityID) {
+		return nil, false
+	}
+	return court, true
+}
+
+// requireCourtScope writes an error response unless the current admin may
+// change the given court. A zero court ID means every court at the club,
+// which only club-wide admins may change.
+func requireCourtScope(c *gin.Context, db *sql.DB, courtID int64) bool {
+	if courtID == 0 {
+		_, ok := requireClubAdmin(c)
+		return ok
+	}
+
+	court, err := models.GetCourtByID(db, currentClubID(c), courtID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Court not found"})
+		return false
+	}
+	return requireFacility(c, court.FacilityID)
+}
+
+// bindBlackout reads a blackout from the request body, writing an error
+// response if it is malformed or outside the current admin's facility
+func bindBlackout(c *gin.Context, db *sql.DB) (*models.CourtBlackout, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RoleAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	var req struct {
+		CourtID   int64     `json:"court_id"`
+		StartTime time.Time `json:"start_time" binding:"required"`
+		EndTime   time.Time `json:"end_time" binding:"required"`
+		Reason    string    `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !requireCourtScope(c, db, req.CourtID) {
+		return nil, false
+	}
+
+	return &models.CourtBlackout{
+		CourtID:   req.CourtID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Reason:    req.Reason,
+		CreatedBy: user.ID,
+		ClubID:    currentClubID(c),
+	}, true
+}
+
+// respondScheduleError writes a court schedule error as JSON
+func respondScheduleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCourtHours),
+		errors.Is(err, models.ErrInvalidOverride),
+		errors.Is(err, models.ErrInvalidBlackout),
+		errors.Is(err, models.ErrInvalidMaintenance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
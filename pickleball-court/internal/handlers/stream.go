@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+	"pickleball-court/internal/models"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// streamPingInterval is how often an idle availability stream sends a
+// comment so proxies do not close the connection
+const streamPingInterval = 30 * time.Second
+
+// StreamAvailabilityHandler pushes changes to court availability in the
+// current club as Server-Sent Events, named by event type. Changes can be
+// narrowed to a single date or a from/to range and to one or more court_id
+// parameters; club-wide closures match every court. Dates are days in the
+// timezone of each court's facility. If the stream ends, the client should
+// reload availability after reconnecting.
+func StreamAvailabilityHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clubID := currentClubID(c)
+
+		// Dates are optional; without them every change is sent
+		var fromDate, toDate time.Time
+		if from := c.DefaultQuery("from", c.Query("date")); from != "" {
+			to := c.DefaultQuery("to", from)
+			var err error
+			fromDate, err = time.Parse("2006-01-02", from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+				return
+			}
+			toDate, err = time.Parse("2006-01-02", to)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+				return
+			}
+		}
+
+		courtIDs, ok := courtIDParams(c)
+		if !ok {
+			return
+		}
+
+		var locations map[int64]*time.Location
+		if !fromDate.IsZero() {
+			var err error
+			locations, err = courtLocations(db, clubID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load courts"})
+				return
+			}
+		}
+
+		// inWindow reports whether a slot falls on the requested days in the
+		// timezone of its court's facility. Club-wide closures, and courts
+		// added since the stream opened, match the days in any of the
+		// club's timezones.
+		inWindow := func(slot events.Slot) bool {
+			if fromDate.IsZero() {
+				return true
+			}
+			candidates := []*time.Location{locations[slot.CourtID]}
+			if candidates[0] == nil {
+				candidates = candidates[:0]
+				for _, loc := range locations {
+					candidates = append(candidates, loc)
+				}
+			}
+			for _, loc := range candidates {
+				start := time.Date(fromDate.Year(), fromDate.Month(), fromDate.Day(), 0, 0, 0, 0, loc)
+				end := time.Date(toDate.Year(), toDate.Month(), toDate.Day()+1, 0, 0, 0, 0, loc)
+				if slot.StartTime.Before(end) && slot.EndTime.After(start) {
+					return true
+				}
+			}
+			return false
+		}
+
+		matches := func(e events.Event) bool {
+			if e.ClubID != clubID {
+				return false
+			}
+			for _, slot := range e.Slots() {
+				if !inWindow(slot) {
+					continue
+				}
+				if slot.CourtID == 0 || len(courtIDs) == 0 {
+					return true
+				}
+				for _, id := range courtIDs {
+					if id == slot.CourtID {
+						return true
+					}
+				}
+			}
+			return false
+		}
+
+		sub := events.Subscribe()
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		ping := time.NewTicker(streamPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, open := <-sub.C:
+				if !open {
+					return
+				}
+				if matches(e) {
+					c.SSEvent(e.Type, e)
+					c.Writer.Flush()
+				}
+			case <-ping.C:
+				c.Writer.WriteString(": ping\n\n")
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// courtLocations returns the timezone of each court in a club, taken from
+// its facility's policy. A club without courts gets the configured
+// timezone under court ID 0, so that club-wide changes still match.
+func courtLocations(db *sql.DB, clubID int64) (map[int64]*time.Location, error) {
+	courts, err := models.GetAllCourts(db, clubID)
+	if err != nil {
+		return nil, err
+	}
+
+	byFacility := make(map[int64]*time.Location)
+	locations := make(map[int64]*time.Location, len(courts))
+	for _, court := range courts {
+		loc, ok := byFacility[court.FacilityID]
+		if !ok {
+			policy, err := models.GetCourtPolicy(db, court.ID, models.BookingTypeRegular)
+			if err != nil {
+				return nil, err
+			}
+			loc = policy.Location
+			byFacility[court.FacilityID] = loc
+		}
+		locations[court.ID] = loc
+	}
+	if len(locations) == 0 {
+		locations[0] = models.GetBookingPolicy(config.Get(), models.BookingTypeRegular).Location
+	}
+	return locations, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pickleball-court/internal/events"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamFiltersDatesInTheFacilityTimezone(t *testing.T) {
+	db := openTestDB(t)
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+	facility := &models.Facility{ClubID: 1, Name: "Auckland", TimeZone: auckland.String()}
+	if err := models.CreateFacility(db, facility); err != nil {
+		t.Fatal(err)
+	}
+	court := &models.Court{Name: "Court 1", ClubID: 1, FacilityID: facility.ID}
+	if err := models.CreateCourt(db, court); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/courts/availability/stream", StreamAvailabilityHandler(db))
+	server := httptest.NewServer(middleware.Tenant(db, router, ""))
+	t.Cleanup(server.Close)
+
+	now := time.Now().In(auckland)
+	day := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, auckland)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		server.URL+"/c/main/courts/availability/stream?date="+day.Format("2006-01-02"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+
+	// Read as UTC days, only the first slot falls on the requested date;
+	// in Auckland, where the court is, only the second does
+	publish := func(eventType string, start time.Time) {
+		events.Publish(events.Event{
+			Type:   eventType,
+			ClubID: 1,
+			Slot:   events.Slot{CourtID: court.ID, StartTime: start, EndTime: start.Add(time.Hour)},
+		})
+	}
+	publish(events.BookingCancelled, day.AddDate(0, 0, 1).Add(time.Hour))
+	publish(events.BookingCreated, day.Add(time.Hour))
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if name, ok := strings.CutPrefix(lines.Text(), "event:"); ok {
+			if name != events.BookingCreated {
+				t.Errorf("got event %s from outside the requested day, want %s", name, events.BookingCreated)
+			}
+			return
+		}
+	}
+	t.Fatalf("stream ended without an event: %v", lines.Err())
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/middleware"
+	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// JoinWaitlistHandler adds the player to the waitlist for a court and time
+// range. Leaving out court_id waits for any court.
+func JoinWaitlistHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			CourtID   int64     `json:"court_id"`
+			StartTime time.Time `json:"start_time" binding:"required"`
+			EndTime   time.Time `json:"end_time" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry := models.WaitlistEntry{
+			UserID:    user.ID,
+			CourtID:   req.CourtID,
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+		}
+		if err := models.JoinWaitlist(db, &entry); err != nil {
+			if errors.Is(err, models.ErrAlreadyWaitlisted) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// ListWaitlistHandler lists the player's active waitlist entries
+func ListWaitlistHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.GetCurrentUser(c)
+		if user == nil || user.Role != models.RolePlayer {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		entries, err := models.GetUserWaitlist(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load waitlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// ClaimWaitlistHandler books the slot held for the player
+func ClaimWaitlistHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, ok := ownWaitlistEntry(c, db)
+		if !ok {
+			return
+		}
+
+		booking, err := models.ClaimWaitlistHold(db, entry.ID, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrNoWaitlistHold) || errors.Is(err, models.ErrWaitlistHoldGone) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			respondBookingError(c, err, "Failed to claim slot")
+			return
+		}
+		notify.BookingCreated(db, booking)
+
+		c.JSON(http.StatusCreated, booking)
+	}
+}
+
+// LeaveWaitlistHandler removes the player from the waitlist
+func LeaveWaitlistHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, ok := ownWaitlistEntry(c, db)
+		if !ok {
+			return
+		}
+
+		offers, err := models.LeaveWaitlist(db, entry.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		NotifyWaitlistOffers(db, offers)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist"})
+	}
+}
+
+// ownWaitlistEntry loads the waitlist entry in the URL and checks that it
+// belongs to the current player, writing an error response if not
+func ownWaitlistEntry(c *gin.Context, db *sql.DB) (*models.WaitlistEntry, bool) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil || user.Role != models.RolePlayer {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	entry, err := models.GetWaitlistEntryByID(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return nil, false
+	}
+	if entry.UserID != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	return entry, true
+}
+
+// NotifyWaitlistOffers tells waitlisted players that a slot is being held for
+// them. Offers also show on the player dashboard until claimed or expired.
+func NotifyWaitlistOffers(db *sql.DB, offers []*models.WaitlistEntry) {
+	for _, offer := range offers {
+		notify.WaitlistOffer(db, offer)
+	}
+}
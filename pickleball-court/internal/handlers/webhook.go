@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pickleball-court/internal/models"
+	"strconv"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// webhookLogLimit is how many recent deliveries the log shows
+const webhookLogLimit = 100
+
+// webhookRequest is the body for registering or changing a webhook
+type webhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types" binding:"required"`
+	Active     *bool    `json:"active"`
+}
+
+// ListWebhooksHandler lists the club's webhooks and the events they can
+// subscribe to. Secrets are only shown when created or rotated.
+func ListWebhooksHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := requireClubAdmin(c); !ok {
+			return
+		}
+
+		webhooks, err := models.GetClubWebhooks(db, currentClubID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load webhooks"})
+			return
+		}
+		for _, webhook := range webhooks {
+			webhook.Secret = ""
+		}
+
+		c.JSON(http.StatusOK, gin.H{"webhooks": webhooks, "event_types": models.WebhookEventTypes})
+	}
+}
+
+// CreateWebhookHandler registers a webhook. The response includes the
+// secret its deliveries are signed with.
+func CreateWebhookHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireClubAdmin(c)
+		if !ok {
+			return
+		}
+
+		var req webhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		webhook := &models.Webhook{
+			ClubID:     currentClubID(c),
+			URL:        req.URL,
+			EventTypes: req.EventTypes,
+			Active:     req.Active == nil || *req.Active,
+			CreatedBy:  user.ID,
+		}
+		if err := models.CreateWebhook(db, webhook); err != nil {
+			respondWebhookError(c, err, "Failed to create webhook")
+			return
+		}
+
+		c.JSON(http.StatusCreated, webhook)
+	}
+}
+
+// UpdateWebhookHandler changes a webhook's URL and events, and pauses or
+// resumes it. Deliveries for a paused webhook wait until it is resumed.
+func UpdateWebhookHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, ok := webhookParam(c, db)
+		if !ok {
+			return
+		}
+
+		var req webhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		webhook.URL = req.URL
+		webhook.EventTypes = req.EventTypes
+		if req.Active != nil {
+			webhook.Active = *req.Active
+		}
+		if err := models.UpdateWebhook(db, webhook); err != nil {
+			respondWebhookError(c, err, "Failed to update webhook")
+			return
+		}
+
+		webhook.Secret = ""
+		c.JSON(http.StatusOK, webhook)
+	}
+}
+
+// RotateWebhookSecretHandler gives a webhook a new signing secret and
+// returns it
+func RotateWebhookSecretHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, ok := webhookParam(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.RotateWebhookSecret(db, webhook); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate secret"})
+			return
+		}
+
+		c.JSON(http.StatusOK, webhook)
+	}
+}
+
+// DeleteWebhookHandler removes a webhook and its delivery log
+func DeleteWebhookHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, ok := webhookParam(c, db)
+		if !ok {
+			return
+		}
+
+		if err := models.DeleteWebhook(db, webhook.ID); err != nil {
+			respondWebhookError(c, err, "Failed to delete webhook")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+	}
+}
+
+// ListWebhookDeliveriesHandler shows a webhook's most recent deliveries,
+// newest first, with the outcome of the last attempt at each
+func ListWebhookDeliveriesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, ok := webhookParam(c, db)
+		if !ok {
+			return
+		}
+
+		deliveries, err := models.GetWebhookDeliveries(db, webhook.ID, webhookLogLimit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load deliveries"})
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+// RedeliverWebhookHandler sends an earlier delivery's event again, as a new
+// delivery due straight away
+func RedeliverWebhookHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, ok := webhookParam(c, db)
+		if !ok {
+			return
+		}
+
+		original, err := models.GetWebhookDeliveryByID(db, c.Param("delivery_id"))
+		if err != nil || original.WebhookID != webhook.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+
+		delivery, err := models.RedeliverWebhook(db, original, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+			return
+		}
+
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// webhookParam loads the webhook in the URL, writing an error response
+// unless the current user is a club-wide admin and the webhook belongs to
+// their club
+func webhookParam(c *gin.Context, db *sql.DB) (*models.Webhook, bool) {
+	if _, ok := requireClubAdmin(c); !ok {
+		return nil, false
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return nil, false
+	}
+
+	webhook, err := models.GetWebhookByID(db, currentClubID(c), webhookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	return webhook, true
+}
+
+// respondWebhookError writes the response for an error from saving a
+// webhook
+func respondWebhookError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
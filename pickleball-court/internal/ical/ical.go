@@ -0,0 +1,126 @@
+// Package ical writes iCalendar (RFC 5545) feeds and files that calendar
+// apps such as Google Calendar and Apple Calendar can subscribe to or import.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event statuses
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// ContentType is the MIME type of an iCalendar file
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event is a single VEVENT. UID must stay the same for the same event across
+// feed refreshes so that calendar apps update it rather than duplicate it,
+// and Sequence must go up each time its time or status changes so that
+// they replace what they have.
+type Event struct {
+	UID          string
+	Sequence     int
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Location     string
+	Description  string
+	Status       string
+	LastModified time.Time // left out when zero
+}
+
+// Calendar is a named collection of events
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// maxLineOctets is the longest content line RFC 5545 allows before folding
+const maxLineOctets = 75
+
+// Write writes the calendar to w as a VCALENDAR
+func Write(w io.Writer, cal *Calendar) error {
+	out := bufio.NewWriter(w)
+	now := time.Now()
+
+	writeLine(out, "BEGIN:VCALENDAR")
+	writeLine(out, "VERSION:2.0")
+	writeLine(out, "PRODID:-//PickleCourt//Court Bookings//EN")
+	writeLine(out, "CALSCALE:GREGORIAN")
+	writeLine(out, "METHOD:PUBLISH")
+	if cal.Name != "" {
+		writeLine(out, "X-WR-CALNAME:"+escapeText(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		writeLine(out, "BEGIN:VEVENT")
+		writeLine(out, "UID:"+escapeText(event.UID))
+		// DTSTAMP is when this copy of the event was written
+		writeLine(out, "DTSTAMP:"+formatTime(now))
+		if !event.LastModified.IsZero() {
+			writeLine(out, "LAST-MODIFIED:"+formatTime(event.LastModified))
+		}
+		writeLine(out, "SEQUENCE:"+strconv.Itoa(event.Sequence))
+		writeLine(out, "DTSTART:"+formatTime(event.Start))
+		writeLine(out, "DTEND:"+formatTime(event.End))
+		writeLine(out, "SUMMARY:"+escapeText(event.Summary))
+		if event.Location != "" {
+			writeLine(out, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.Description != "" {
+			writeLine(out, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.Status != "" {
+			writeLine(out, "STATUS:"+event.Status)
+		}
+		writeLine(out, "END:VEVENT")
+	}
+
+	writeLine(out, "END:VCALENDAR")
+	return out.Flush()
+}
+
+// formatTime formats t as a UTC date-time, e.g. 20250102T150000Z
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+// writeLine writes a content line ending in CRLF, folding it onto
+// continuation lines that start with a space once it passes 75 octets.
+// Lines are only broken between whole UTF-8 characters.
+func writeLine(out *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		out.WriteString(line[:cut])
+		out.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts toward its length
+		limit = maxLineOctets - 1
+	}
+	out.WriteString(line)
+	out.WriteString("\r\n")
+}
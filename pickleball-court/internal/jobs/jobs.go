@@ -0,0 +1,168 @@
+// Package jobs runs the app's recurring background tasks: reminders, hold
+// expiry, no-show sweeps, email delivery and cleanup. Schedules are kept in
+// the jobs table so they survive restarts, and a lease in the database
+// makes sure that when several instances share it only one runs jobs.
+package jobs
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"pickleball-court/internal/models"
+	"time"
+)
+
+const (
+	// leaseName names the lease in the scheduler_leases table
+	leaseName = "scheduler"
+
+	// leaseTTL is how long the leader holds the lease between renewals.
+	// Another instance takes over once it lapses.
+	leaseTTL = time.Minute
+
+	// leaseRenewal is how often the lease is renewed while a job runs
+	leaseRenewal = leaseTTL / 3
+
+	// staleAfter is how long a job may stay marked running before it is
+	// assumed lost with the instance that was running it
+	staleAfter = 15 * time.Minute
+)
+
+// Task is the work a job does. It returns an error to mark the run failed.
+type Task func(db *sql.DB, now time.Time) error
+
+// Scheduler runs registered tasks when their jobs fall due
+type Scheduler struct {
+	db       *sql.DB
+	holder   string
+	tasks    map[string]Task
+	interval map[string]time.Duration
+}
+
+// New returns a scheduler with no tasks, identified in the lease by its
+// host name and process ID
+func New(db *sql.DB) *Scheduler {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Scheduler{
+		db:       db,
+		holder:   fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		tasks:    make(map[string]Task),
+		interval: make(map[string]time.Duration),
+	}
+}
+
+// Every registers a task to run once per interval under the given job name
+func (s *Scheduler) Every(name string, interval time.Duration, task Task) {
+	s.tasks[name] = task
+	s.interval[name] = interval
+}
+
+// Run saves the registered schedules and then checks for due jobs every
+// poll interval until the process exits
+func (s *Scheduler) Run(poll time.Duration) {
+	for name, interval := range s.interval {
+		if err := models.ScheduleJob(s.db, name, interval, time.Now()); err != nil {
+			log.Printf("Scheduler: failed to schedule %s: %v\n", name, err)
+		}
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.RunDue(time.Now()); err != nil {
+			log.Println("Scheduler failed:", err)
+		}
+	}
+}
+
+// RunDue runs the jobs due at now, one at a time, if this instance holds
+// or can take the lease. Jobs with no registered task, left by an older
+// version of the app, are skipped.
+func (s *Scheduler) RunDue(now time.Time) error {
+	leader, err := models.AcquireLease(s.db, leaseName, s.holder, now, leaseTTL)
+	if err != nil || !leader {
+		return err
+	}
+
+	due, err := models.GetDueJobs(s.db, now, now.Add(-staleAfter))
+	if err != nil {
+		return err
+	}
+	for _, job := range due {
+		task, ok := s.tasks[job.Name]
+		if !ok {
+			continue
+		}
+
+		// Renew the lease before each job, as earlier ones may have been slow
+		leader, err := models.AcquireLease(s.db, leaseName, s.holder, time.Now(), leaseTTL)
+		if err != nil || !leader {
+			return err
+		}
+
+		// The job may have been started elsewhere since it was loaded
+		start := time.Now()
+		err = models.StartJob(s.db, job, start, start.Add(-staleAfter))
+		if errors.Is(err, models.ErrJobRunning) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stop := s.keepLease()
+		runErr := run(s.db, task, start)
+		stop()
+		if runErr != nil {
+			log.Printf("Scheduler: job %s failed: %v\n", job.Name, runErr)
+		}
+		if err := models.FinishJob(s.db, job, runErr, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepLease renews the lease in the background until the returned function
+// is called, so that another instance does not take over while a job runs
+// longer than leaseTTL
+func (s *Scheduler) keepLease() func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(leaseRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				leader, err := models.AcquireLease(s.db, leaseName, s.holder, now, leaseTTL)
+				if err != nil || !leader {
+					log.Printf("Scheduler: failed to renew lease during a job (leader %v): %v\n", leader, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// run calls task, turning a panic into an error so that one broken job
+// cannot stop the others
+func run(db *sql.DB, task Task, now time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return task(db, now)
+}
@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/models"
+)
+
+// openTestDB opens a fresh database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	config.Load()
+
+	db, err := models.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOnlyTheLeaseHolderRunsJobs(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+
+	runs := map[string]int{}
+	schedulers := map[string]*Scheduler{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		s := New(db)
+		s.Every("count", time.Hour, func(db *sql.DB, now time.Time) error {
+			runs[name]++
+			return nil
+		})
+		schedulers[name] = s
+	}
+	if err := models.ScheduleJob(db, "count", time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := schedulers["first"].RunDue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the job due again; the second instance still may not run it
+	// while the first holds the lease
+	if _, err := db.Exec(`UPDATE jobs SET run_at = ?`, now.UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if err := schedulers["second"].RunDue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if runs["first"] != 1 || runs["second"] != 0 {
+		t.Errorf("got runs %v, want first 1 and second 0", runs)
+	}
+
+	// Once the lease lapses the second instance takes over
+	if err := schedulers["second"].RunDue(time.Now().Add(2 * leaseTTL)); err != nil {
+		t.Fatal(err)
+	}
+	if runs["second"] != 1 {
+		t.Errorf("got runs %v, want second to take over", runs)
+	}
+}
+
+func TestRunDueRecoversJobLostMidRun(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+
+	runs := 0
+	s := New(db)
+	s.Every("count", time.Hour, func(db *sql.DB, now time.Time) error {
+		runs++
+		return nil
+	})
+	if err := models.ScheduleJob(db, "count", time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance started the job and died without finishing it
+	lost := &models.Job{Name: "count"}
+	if err := db.QueryRow(`SELECT id FROM jobs WHERE name = ?`, lost.Name).Scan(&lost.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.StartJob(db, lost, now, now.Add(-staleAfter)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunDue(now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 0 {
+		t.Fatalf("ran a job still running elsewhere")
+	}
+
+	// The tasks run at the real time, so the job must have been started
+	// long enough ago to count as lost
+	if _, err := db.Exec(`UPDATE jobs SET started_at = ?`, time.Now().Add(-staleAfter-time.Minute).UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunDue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Fatalf("got %d runs, want the lost job run again", runs)
+	}
+
+	jobs, err := models.GetJobs(db, "")
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("got %d jobs, err %v", len(jobs), err)
+	}
+	if jobs[0].Status != models.JobStatusPending || !jobs[0].RunAt.After(now) {
+		t.Errorf("got status %s due %s, want pending and rescheduled", jobs[0].Status, jobs[0].RunAt)
+	}
+}
@@ -65,7 +65,8 @@ func RoleRequired(allowedRoles ...string) gin.HandlerFunc {
 	}
 }
 
-// LoadUser middleware loads the user from the session and adds it to the context
+// LoadUser middleware loads the user from the session and adds it to the
+// context. A session from another club is dropped.
 func LoadUser(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
@@ -75,7 +76,10 @@ func LoadUser(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		user, err := models.GetUserByID(db, userID.(int64))
+		user, err := models.GetUserByID(db, models.AnyClub, userID.(int64))
+		if club := GetCurrentClub(c); err == nil && club != nil && !user.InClub(club.ID) {
+			err = models.ErrOtherClub
+		}
 		if err != nil {
 			session.Clear()
 			session.Save()
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"pickleball-court/internal/models"
+	"strings"
+	"github.com/gin-gonic/gin"
+)
+
+// clubPathPrefix is the path prefix naming a club, as in /c/<slug>/courts
+const clubPathPrefix = "/c/"
+
+type (
+	clubContextKey   struct{}
+	prefixContextKey struct{}
+)
+
+// Tenant resolves the club each request is for and serves it with next.
+// A club is named either by a /c/<slug> path prefix, which is stripped
+// before routing, or by a <slug>.<baseDomain> subdomain. Requests naming
+// neither are served by the default club, and requests naming an unknown
+// club get a 404.
+func Tenant(db *sql.DB, next http.Handler, baseDomain string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug, prefix := clubSlug(r, baseDomain)
+
+		var club *models.Club
+		var err error
+		if slug == "" {
+			club, err = models.GetDefaultClub(db)
+		} else {
+			club, err = models.GetClubBySlug(db, slug)
+		}
+		if err != nil {
+			if err.Error() == "club not found" {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, "Failed to load club", http.StatusInternalServerError)
+			return
+		}
+
+		if prefix != "" {
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+			if r.URL.Path == "" {
+				r.URL.Path = "/"
+			}
+			r.URL.RawPath = ""
+			w = &prefixedWriter{ResponseWriter: w, prefix: prefix}
+		}
+
+		ctx := context.WithValue(r.Context(), clubContextKey{}, club)
+		ctx = context.WithValue(ctx, prefixContextKey{}, prefix)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clubSlug returns the club slug a request names and, when it was named by
+// path, the prefix to strip
+func clubSlug(r *http.Request, baseDomain string) (string, string) {
+	if strings.HasPrefix(r.URL.Path, clubPathPrefix) {
+		slug := strings.TrimPrefix(r.URL.Path, clubPathPrefix)
+		if i := strings.Index(slug, "/"); i >= 0 {
+			slug = slug[:i]
+		}
+		if slug != "" {
+			return slug, clubPathPrefix + slug
+		}
+	}
+
+	if baseDomain == "" {
+		return "", ""
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub := strings.TrimSuffix(strings.ToLower(host), "."+baseDomain)
+	if sub == host || sub == "www" || strings.Contains(sub, ".") {
+		return "", ""
+	}
+	return sub, ""
+}
+
+// prefixedWriter adds a club's path prefix to local redirects so that
+// clients stay inside the club they came from
+type prefixedWriter struct {
+	http.ResponseWriter
+	prefix string
+}
+
+func (w *prefixedWriter) WriteHeader(code int) {
+	location := w.Header().Get("Location")
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		w.Header().Set("Location", w.prefix+location)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush passes flushes through so streamed responses reach the client
+func (w *prefixedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// GetCurrentClub returns the club the request is for
+func GetCurrentClub(c *gin.Context) *models.Club {
+	club, _ := c.Request.Context().Value(clubContextKey{}).(*models.Club)
+	return club
+}
+
+// ClubURL returns the absolute URL of a path within the request's club,
+// keeping the /c/<slug> prefix the request came in on
+func ClubURL(c *gin.Context, path string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	prefix, _ := c.Request.Context().Value(prefixContextKey{}).(string)
+	return scheme + "://" + c.Request.Host + prefix + path
+}
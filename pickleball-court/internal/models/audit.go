@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// BookingAuditEntry records one reschedule or transfer step on a booking,
+// with the values before and after the change. Fields that the action did
+// not touch are left zero.
+type BookingAuditEntry struct {
+	ID           int64
+	BookingID    int64
+	Action       string
+	ChangedBy    int64
+	OldCourtID   int64
+	NewCourtID   int64
+	OldStartTime time.Time
+	NewStartTime time.Time
+	OldEndTime   time.Time
+	NewEndTime   time.Time
+	OldUserID    int64
+	NewUserID    int64
+	CreatedAt    time.Time
+
+	// Additional fields for joins
+	ChangedByName string
+}
+
+const (
+	AuditActionReschedule        = "reschedule"
+	AuditActionTransferRequested = "transfer_requested"
+	AuditActionTransferAccepted  = "transfer_accepted"
+	AuditActionTransferDeclined  = "transfer_declined"
+	AuditActionTransferCancelled = "transfer_cancelled"
+)
+
+// recordBookingAudit appends an entry to a booking's audit trail
+func recordBookingAudit(q Querier, entry *BookingAuditEntry) error {
+	result, err := q.Exec(`
+		INSERT INTO booking_audit (
+			booking_id, action, changed_by,
+			old_court_id, new_court_id, old_start_time, new_start_time,
+			old_end_time, new_end_time, old_user_id, new_user_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		entry.BookingID, entry.Action, entry.ChangedBy,
+		nullInt64(entry.OldCourtID), nullInt64(entry.NewCourtID),
+		nullTime(entry.OldStartTime), nullTime(entry.NewStartTime),
+		nullTime(entry.OldEndTime), nullTime(entry.NewEndTime),
+		nullInt64(entry.OldUserID), nullInt64(entry.NewUserID),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetBookingAudit retrieves the audit trail of a booking, oldest first
+func GetBookingAudit(db *sql.DB, bookingID int64) ([]*BookingAuditEntry, error) {
+	query := `
+		SELECT
+			a.id, a.booking_id, a.action, a.changed_by,
+			a.old_court_id, a.new_court_id, a.old_start_time, a.new_start_time,
+			a.old_end_time, a.new_end_time, a.old_user_id, a.new_user_id, a.created_at,
+			COALESCE(u.username, '') as changed_by_name
+		FROM booking_audit a
+		LEFT JOIN users u ON a.changed_by = u.id
+		WHERE a.booking_id = ?
+		ORDER BY a.created_at ASC, a.id ASC
+	`
+	rows, err := db.Query(query, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*BookingAuditEntry
+	for rows.Next() {
+		entry := &BookingAuditEntry{}
+		var oldCourtID, newCourtID, oldUserID, newUserID sql.NullInt64
+		var oldStart, newStart, oldEnd, newEnd sql.NullTime
+		err := rows.Scan(
+			&entry.ID, &entry.BookingID, &entry.Action, &entry.ChangedBy,
+			&oldCourtID, &newCourtID, &oldStart, &newStart,
+			&oldEnd, &newEnd, &oldUserID, &newUserID, &entry.CreatedAt,
+			&entry.ChangedByName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.OldCourtID = oldCourtID.Int64
+		entry.NewCourtID = newCourtID.Int64
+		entry.OldStartTime = oldStart.Time
+		entry.NewStartTime = newStart.Time
+		entry.OldEndTime = oldEnd.Time
+		entry.NewEndTime = newEnd.Time
+		entry.OldUserID = oldUserID.Int64
+		entry.NewUserID = newUserID.Int64
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
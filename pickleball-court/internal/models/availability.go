@@ -0,0 +1,411 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"pickleball-court/config"
+)
+
+// Interval is a half-open stretch of time [Start, End) on a court
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// BusyInterval is something occupying a court: a booking, a checkout hold,
+// a slot held for a waitlisted player or a blackout window
+type BusyInterval struct {
+	Interval
+	Kind      string `json:"kind"`
+	BookingID int64  `json:"booking_id,omitempty"`
+
+	// Players on the booking, booker included
+	Players int `json:"players,omitempty"`
+
+	// Set when the booking is an open play block
+	OpenPlayID       int64 `json:"open_play_id,omitempty"`
+	OpenPlayCapacity int   `json:"open_play_capacity,omitempty"`
+	OpenPlaySignedUp int   `json:"open_play_signed_up,omitempty"`
+}
+
+const (
+	BusyKindBooking      = "booking"
+	BusyKindSlotHold     = "slot_hold"
+	BusyKindWaitlistHold = "waitlist_hold"
+	BusyKindBlackout     = "blackout"
+
+	// MaxAvailabilityDays caps the range of a single availability request
+	MaxAvailabilityDays = 31
+)
+
+// AvailabilityRequest describes the courts and days to compute availability
+// for. From and To are calendar days, inclusive, laid out in each
+// facility's timezone. After and Before narrow each day to a time-of-day
+// window. Only courts of the filter's club are included. SlotLength is the
+// block size a player wants to book; slots start every configured slot
+// duration.
+type AvailabilityRequest struct {
+	From       time.Time
+	To         time.Time
+	After      string  // "HH:MM", empty for opening time
+	Before     string  // "HH:MM", empty for closing time
+	CourtIDs   []int64 // empty for every court
+	Filter     CourtFilter
+	SlotLength time.Duration
+}
+
+// AvailabilitySlot is a bookable block on a court
+type AvailabilitySlot struct {
+	Interval
+	Available bool `json:"available"`
+	Players   int  `json:"players"`
+	Full      bool `json:"full"`
+
+	// Joinable open play slots are busy but still take sign-ups
+	Joinable   bool  `json:"joinable"`
+	OpenPlayID int64 `json:"open_play_id,omitempty"`
+}
+
+// CourtAvailability is one court's busy and free time over a date range,
+// plus the slots that could be booked. Times are in the court's facility
+// timezone.
+type CourtAvailability struct {
+	Court    *Court
+	Location *time.Location
+	Busy     []Interval // merged
+	Free     []Interval // inside the court's opening hours
+	Slots    []AvailabilitySlot
+}
+
+var (
+	ErrInvalidAvailabilityRange = errors.New("availability range must be between 1 and 31 days")
+	ErrInvalidTimeOfDay         = errors.New("after and before must be HH:MM, before later than after")
+)
+
+// GetCourtAvailability computes availability for a range of days across
+// every facility. Each facility's days and hours are laid out in its own
+// timezone. Everything occupying the requested courts is loaded with a
+// single range query; free time and slots are then worked out in memory.
+func GetCourtAvailability(db *sql.DB, req AvailabilityRequest, now time.Time) ([]*CourtAvailability, error) {
+	days := int(time.Date(req.To.Year(), req.To.Month(), req.To.Day(), 0, 0, 0, 0, time.UTC).Sub(
+		time.Date(req.From.Year(), req.From.Month(), req.From.Day(), 0, 0, 0, 0, time.UTC)).Hours()/24) + 1
+	if days < 1 || days > MaxAvailabilityDays {
+		return nil, ErrInvalidAvailabilityRange
+	}
+
+	var err error
+	after, before := time.Duration(0), 24*time.Hour
+	if req.After != "" {
+		if after, err = parseClock(req.After); err != nil {
+			return nil, ErrInvalidTimeOfDay
+		}
+	}
+	if req.Before != "" {
+		if before, err = parseClock(req.Before); err != nil {
+			return nil, ErrInvalidTimeOfDay
+		}
+	}
+	if before <= after {
+		return nil, ErrInvalidTimeOfDay
+	}
+
+	step := config.Get().GetSlotDuration()
+	length := req.SlotLength
+	if length <= 0 {
+		length = step
+	}
+
+	courts, err := GetAllCourts(db, req.Filter.ClubID)
+	if err != nil {
+		return nil, err
+	}
+	courts = req.Filter.Apply(filterCourts(courts, req.CourtIDs))
+
+	// Each facility's policy gives its timezone and default hours
+	policies := make(map[int64]BookingPolicy)
+	locations := make(map[int64]*time.Location, len(courts))
+	var first, last time.Time
+	for _, court := range courts {
+		policy, ok := policies[court.FacilityID]
+		if !ok {
+			policy, err = GetCourtPolicy(db, court.ID, BookingTypeRegular)
+			if err != nil {
+				return nil, err
+			}
+			policies[court.FacilityID] = policy
+		}
+		locations[court.ID] = policy.Location
+
+		from, to := dayRange(req.From, days, policy.Location)
+		if first.IsZero() || from.Before(first) {
+			first = from
+		}
+		if to.After(last) {
+			last = to
+		}
+	}
+
+	busy, err := getBusyIntervals(db, req.Filter.ClubID, locations, first, last, now)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[int64]*courtSchedule, len(policies))
+	for facilityID, policy := range policies {
+		from, to := dayRange(req.From, days, policy.Location)
+		schedules[facilityID], err = loadCourtSchedule(db, policy, from, to.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*CourtAvailability
+	for _, court := range courts {
+		loc := locations[court.ID]
+		schedule := schedules[court.FacilityID]
+		from, to := dayRange(req.From, days, loc)
+
+		// Opening hours for each day cut down to the requested time of day,
+		// built in the facility's timezone so daylight-saving changes land
+		// on the right hour
+		var open []Interval
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			window, ok := schedule.openWindow(court.ID, day)
+			if !ok {
+				continue
+			}
+			if earliest := addClock(day, after); window.Start.Before(earliest) {
+				window.Start = earliest
+			}
+			if latest := addClock(day, before); window.End.After(latest) {
+				window.End = latest
+			}
+			if window.End.After(window.Start) {
+				open = append(open, window)
+			}
+		}
+
+		intervals := busy[court.ID]
+		merged := mergeIntervals(intervals)
+
+		availability := &CourtAvailability{
+			Court:    court,
+			Location: loc,
+			Busy:     merged,
+			Free:     subtractIntervals(open, merged),
+		}
+
+		// Blocks longer than the court allows are never offered
+		if length <= time.Duration(court.MaxBookingMinutes)*time.Minute {
+			availability.Slots = buildSlots(open, intervals, step, length, now)
+		}
+		result = append(result, availability)
+	}
+	return result, nil
+}
+
+// dayRange returns midnight at the start of the first calendar day and at
+// the end of the last one in loc
+func dayRange(first time.Time, days int, loc *time.Location) (time.Time, time.Time) {
+	from := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 0, days)
+}
+
+// getBusyIntervals loads everything occupying the given courts of a club
+// between from and to in one query, grouped by court and sorted by start
+// time. locations maps each wanted court to the timezone its times are
+// returned in.
+func getBusyIntervals(db *sql.DB, clubID int64, locations map[int64]*time.Location, from, to, now time.Time) (map[int64][]BusyInterval, error) {
+	busy := make(map[int64][]BusyInterval)
+	if len(locations) == 0 {
+		return busy, nil
+	}
+
+	query := `
+		SELECT b.court_id, b.start_time, b.end_time, 'booking', b.id,
+			1 + (SELECT COUNT(*) FROM booking_participants p
+				WHERE p.booking_id = b.id AND p.status = 'accepted'),
+			COALESCE(s.id, 0), COALESCE(s.capacity, 0),
+			(SELECT COUNT(*) FROM open_play_signups os
+				WHERE os.session_id = s.id AND os.status = 'signed_up')
+		FROM bookings b
+		LEFT JOIN open_play_sessions s ON s.booking_id = b.id
+		WHERE b.status NOT IN ('cancelled', 'rejected', 'no_show')
+		AND julianday(b.start_time) < julianday(?) AND julianday(b.end_time) > julianday(?)
+		UNION ALL
+		SELECT court_id, start_time, end_time, 'slot_hold', 0, 0, 0, 0, 0
+		FROM slot_holds
+		WHERE julianday(expires_at) > julianday(?)
+		AND julianday(start_time) < julianday(?) AND julianday(end_time) > julianday(?)
+		UNION ALL
+		SELECT offered_court_id, start_time, end_time, 'waitlist_hold', 0, 0, 0, 0, 0
+		FROM waitlist_entries
+		WHERE status = 'offered' AND julianday(hold_expires_at) > julianday(?)
+		AND julianday(start_time) < julianday(?) AND julianday(end_time) > julianday(?)
+		UNION ALL
+		SELECT COALESCE(court_id, 0), start_time, end_time, 'blackout', 0, 0, 0, 0, 0
+		FROM court_blackouts
+		WHERE (court_id IS NOT NULL OR club_id = ?)
+		AND julianday(start_time) < julianday(?) AND julianday(end_time) > julianday(?)
+	`
+	rows, err := db.Query(query,
+		to.UTC(), from.UTC(),
+		now.UTC(), to.UTC(), from.UTC(),
+		now.UTC(), to.UTC(), from.UTC(),
+		clubID, to.UTC(), from.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var courtID int64
+		var interval BusyInterval
+		err := rows.Scan(
+			&courtID, &interval.Start, &interval.End, &interval.Kind, &interval.BookingID,
+			&interval.Players, &interval.OpenPlayID, &interval.OpenPlayCapacity, &interval.OpenPlaySignedUp,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// Blackouts without a court close every court in the club
+		if courtID == 0 {
+			for id, loc := range locations {
+				busy[id] = append(busy[id], interval.in(loc))
+			}
+		} else if loc, ok := locations[courtID]; ok {
+			busy[courtID] = append(busy[courtID], interval.in(loc))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for courtID := range busy {
+		intervals := busy[courtID]
+		sort.Slice(intervals, func(i, j int) bool {
+			return intervals[i].Start.Before(intervals[j].Start)
+		})
+	}
+	return busy, nil
+}
+
+// buildSlots lays slots of the given length over each day's operating
+// hours, starting every step on the slot grid, so that a court opening
+// between grid lines offers its first slot at the next one. busy must be
+// sorted by start time.
+func buildSlots(open []Interval, busy []BusyInterval, step, length time.Duration, now time.Time) []AvailabilitySlot {
+	var slots []AvailabilitySlot
+	first := 0
+	for _, window := range open {
+		for start := nextSlotStart(window.Start, step); !start.Add(length).After(window.End); start = start.Add(step) {
+			end := start.Add(length)
+			if start.Before(now) {
+				continue
+			}
+
+			// Slots only move forward, so anything ending before this one
+			// starts can be skipped for good
+			for first < len(busy) && !busy[first].End.After(start) {
+				first++
+			}
+
+			slot := AvailabilitySlot{Interval: Interval{Start: start, End: end}, Available: true}
+			for i := first; i < len(busy) && busy[i].Start.Before(end); i++ {
+				if !busy[i].End.After(start) {
+					continue
+				}
+				slot.Available = false
+
+				// Booked slots report how many players are on the court.
+				// Open play slots can still be joined until the session fills.
+				interval := busy[i]
+				if interval.OpenPlayID != 0 {
+					slot.OpenPlayID = interval.OpenPlayID
+					slot.Players = interval.OpenPlaySignedUp
+					slot.Full = interval.OpenPlaySignedUp >= interval.OpenPlayCapacity
+					slot.Joinable = !slot.Full
+				} else if interval.Kind == BusyKindBooking && slot.OpenPlayID == 0 {
+					slot.Players = interval.Players
+					slot.Full = interval.Players >= MaxPlayersPerBooking
+				}
+			}
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// in returns the interval with its times in loc
+func (b BusyInterval) in(loc *time.Location) BusyInterval {
+	b.Start = b.Start.In(loc)
+	b.End = b.End.In(loc)
+	return b
+}
+
+// mergeIntervals merges overlapping and touching intervals. The input must
+// be sorted by start time.
+func mergeIntervals(busy []BusyInterval) []Interval {
+	var merged []Interval
+	for _, interval := range busy {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval.Interval)
+	}
+	return merged
+}
+
+// subtractIntervals returns the parts of open not covered by busy. Both
+// inputs must be sorted and non-overlapping.
+func subtractIntervals(open, busy []Interval) []Interval {
+	var free []Interval
+	first := 0
+	for _, window := range open {
+		start := window.Start
+		for first < len(busy) && !busy[first].End.After(window.Start) {
+			first++
+		}
+		for i := first; i < len(busy) && busy[i].Start.Before(window.End); i++ {
+			if busy[i].Start.After(start) {
+				free = append(free, Interval{Start: start, End: busy[i].Start})
+			}
+			if busy[i].End.After(start) {
+				start = busy[i].End
+			}
+		}
+		if window.End.After(start) {
+			free = append(free, Interval{Start: start, End: window.End})
+		}
+	}
+	return free
+}
+
+// filterCourts keeps the courts whose IDs are listed, or all of them when
+// the list is empty
+func filterCourts(courts []*Court, ids []int64) []*Court {
+	if len(ids) == 0 {
+		return courts
+	}
+
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var filtered []*Court
+	for _, court := range courts {
+		if wanted[court.ID] {
+			filtered = append(filtered, court)
+		}
+	}
+	return filtered
+}
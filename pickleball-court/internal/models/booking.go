@@ -3,7 +3,9 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"pickleball-court/internal/events"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,11 +17,21 @@ type Booking struct {
 	EndTime    time.Time
 	Status     string
 	BookingType string
+	SeriesID   int64 // 0 when the booking is not part of a series
+	CheckedInAt time.Time // zero until the player checks in
+	CheckedInBy int64
 	CreatedAt  time.Time
+	UpdatedAt  time.Time // last change to its court, time, status or booker; CreatedAt if none
+	Sequence   int       // how many times those have changed
 	
 	// Additional fields for joins
 	CourtName  string
 	UserName   string
+	FacilityID int64
+	ClubID     int64
+
+	// Roster besides the booker, loaded by AttachParticipants
+	Participants []*BookingParticipant
 }
 
 type TrainingSession struct {
@@ -36,42 +48,131 @@ type TrainingSession struct {
 	// Additional fields for joins
 	CoachName       string
 	CourtName       string
+	ClubID          int64
+	Enrolled        int // players enrolled, set by SearchTrainingSessions
 }
 
 const (
 	BookingStatusPending   = "pending"
 	BookingStatusConfirmed = "confirmed"
+	BookingStatusRejected  = "rejected"
+	BookingStatusCompleted = "completed"
+	BookingStatusNoShow    = "no_show"
 	BookingStatusCancelled = "cancelled"
 	
 	BookingTypeRegular  = "regular"
 	BookingTypeTraining = "training"
+	BookingTypeOpenPlay = "open_play"
 )
 
-// CreateBooking creates a new booking in the database
+// bookingSelect selects bookings joined with court and user names, in the
+// column order expected by scanBooking
+const bookingSelect = `
+		SELECT 
+			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
+			b.status, b.booking_type, b.series_id,
+			b.checked_in_at, b.checked_in_by, b.created_at,
+			b.updated_at, b.sequence,
+			c.name as court_name, u.username as user_name,
+			COALESCE(c.facility_id, 0) as facility_id, COALESCE(f.club_id, 0) as club_id
+		FROM bookings b
+		JOIN courts c ON b.court_id = c.id
+		JOIN users u ON b.user_id = u.id
+		LEFT JOIN facilities f ON c.facility_id = f.id
+`
+
+// ErrCourtUnavailable is returned when a booking overlaps an existing one
+var ErrCourtUnavailable = errors.New("court is not available for the selected time slot")
+
+// CreateBooking creates a new booking in the database after checking it
+// against the booking policy for its type. The check and the insert run in a
+// single transaction so concurrent requests cannot both claim a slot.
 func CreateBooking(db *sql.DB, booking *Booking) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := createBooking(tx, booking); err != nil {
+		tx.Rollback()
+		return err
+	}
+	event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publish(event)
+	return nil
+}
+
+// createBooking checks and inserts a booking using the given transaction,
+// under the rules of the court's facility
+func createBooking(tx *sql.Tx, booking *Booking) error {
+	policy, err := GetCourtPolicy(tx, booking.CourtID, booking.BookingType)
+	if err != nil {
+		return err
+	}
+	return createBookingWithPolicy(tx, booking, policy)
+}
+
+// createBookingWithPolicy checks a booking against policy and inserts it
+func createBookingWithPolicy(tx *sql.Tx, booking *Booking, policy BookingPolicy) error {
+	if err := checkSameClub(tx, booking.UserID, booking.CourtID); err != nil {
+		return err
+	}
+	if err := policy.Check(tx, booking, time.Now()); err != nil {
+		return err
+	}
+
+	// Store times in UTC so that text comparisons in SQL stay consistent
+	booking.StartTime = booking.StartTime.UTC()
+	booking.EndTime = booking.EndTime.UTC()
+
 	// Check if the court is available
-	available, err := IsCourtAvailable(db, booking.CourtID, booking.StartTime, booking.EndTime)
+	available, err := isCourtAvailable(tx, booking.CourtID, booking.StartTime, booking.EndTime)
 	if err != nil {
 		return err
 	}
 	if !available {
-		return errors.New("court is not available for the selected time slot")
+		return ErrCourtUnavailable
+	}
+
+	// Trusted members and auto-confirm courts skip the approval step
+	reason := ""
+	if booking.Status == BookingStatusPending {
+		autoConfirm, err := shouldAutoConfirm(tx, booking.UserID, booking.CourtID)
+		if err != nil {
+			return err
+		}
+		if autoConfirm {
+			booking.Status = BookingStatusConfirmed
+			reason = "auto-confirmed"
+		}
 	}
 
 	query := `
-		INSERT INTO bookings (court_id, user_id, start_time, end_time, status, booking_type, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO bookings (court_id, user_id, start_time, end_time, status, booking_type, series_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`
 
-	result, err := db.Exec(query, 
+	result, err := tx.Exec(query, 
 		booking.CourtID, 
 		booking.UserID, 
 		booking.StartTime, 
 		booking.EndTime, 
 		booking.Status,
 		booking.BookingType,
+		nullInt64(booking.SeriesID),
 	)
 	if err != nil {
+		if isOverlapError(err) {
+			return ErrCourtUnavailable
+		}
 		return err
 	}
 
@@ -81,11 +182,17 @@ func CreateBooking(db *sql.DB, booking *Booking) error {
 	}
 
 	booking.ID = id
-	return nil
+	return recordStatusChange(tx, booking.ID, "", booking.Status, booking.UserID, reason)
 }
 
-// GetBookingByID retrieves a booking by its ID with joined court and user information
-func GetBookingByID(db *sql.DB, id interface{}) (*Booking, error) {
+// isOverlapError reports whether err was raised by the booking overlap triggers
+func isOverlapError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "booking overlaps an existing booking")
+}
+
+// GetBookingByID retrieves a club's booking by its ID with joined court and
+// user information
+func GetBookingByID(db *sql.DB, clubID int64, id interface{}) (*Booking, error) {
 	var bookingID int64
 	switch v := id.(type) {
 	case int64:
@@ -100,23 +207,21 @@ func GetBookingByID(db *sql.DB, id interface{}) (*Booking, error) {
 		return nil, errors.New("invalid ID type")
 	}
 
+	return getClubBooking(db, clubID, bookingID)
+}
+
+// getBooking retrieves a booking of any club by its ID on any Querier
+func getBooking(q Querier, bookingID int64) (*Booking, error) {
+	return getClubBooking(q, AnyClub, bookingID)
+}
+
+// getClubBooking retrieves a club's booking by its ID on any Querier
+func getClubBooking(q Querier, clubID, bookingID int64) (*Booking, error) {
 	booking := &Booking{}
-	query := `
-		SELECT 
-			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
-			b.status, b.booking_type, b.created_at,
-			c.name as court_name, u.username as user_name
-		FROM bookings b
-		JOIN courts c ON b.court_id = c.id
-		JOIN users u ON b.user_id = u.id
-		WHERE b.id = ?
+	query := bookingSelect + `
+		WHERE b.id = ? AND ` + inClub("f.club_id") + `
 	`
-	err := db.QueryRow(query, bookingID).Scan(
-		&booking.ID, &booking.CourtID, &booking.UserID, 
-		&booking.StartTime, &booking.EndTime, &booking.Status, 
-		&booking.BookingType, &booking.CreatedAt,
-		&booking.CourtName, &booking.UserName,
-	)
+	err := scanBooking(q.QueryRow(query, bookingID, clubID, clubID), booking)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("booking not found")
@@ -126,47 +231,28 @@ func GetBookingByID(db *sql.DB, id interface{}) (*Booking, error) {
 	return booking, nil
 }
 
-// GetRecentBookings retrieves the most recent bookings
-func GetRecentBookings(db *sql.DB, limit int) ([]*Booking, error) {
-	query := `
-		SELECT 
-			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
-			b.status, b.booking_type, b.created_at,
-			c.name as court_name, u.username as user_name
-		FROM bookings b
-		JOIN courts c ON b.court_id = c.id
-		JOIN users u ON b.user_id = u.id
+// GetRecentBookings retrieves a club's most recent bookings
+func GetRecentBookings(db *sql.DB, clubID int64, limit int) ([]*Booking, error) {
+	query := bookingSelect + `
+		WHERE f.club_id = ?
 		ORDER BY b.created_at DESC
 		LIMIT ?
 	`
-	return executeBookingQuery(db, query, limit)
+	return executeBookingQuery(db, query, clubID, limit)
 }
 
-// GetAllBookings retrieves all bookings
-func GetAllBookings(db *sql.DB) ([]*Booking, error) {
-	query := `
-		SELECT 
-			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
-			b.status, b.booking_type, b.created_at,
-			c.name as court_name, u.username as user_name
-		FROM bookings b
-		JOIN courts c ON b.court_id = c.id
-		JOIN users u ON b.user_id = u.id
+// GetAllBookings retrieves all bookings of a club
+func GetAllBookings(db *sql.DB, clubID int64) ([]*Booking, error) {
+	query := bookingSelect + `
+		WHERE f.club_id = ?
 		ORDER BY b.start_time DESC
 	`
-	return executeBookingQuery(db, query)
+	return executeBookingQuery(db, query, clubID)
 }
 
 // GetUserBookings retrieves all bookings for a specific user
 func GetUserBookings(db *sql.DB, userID int64) ([]*Booking, error) {
-	query := `
-		SELECT 
-			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
-			b.status, b.booking_type, b.created_at,
-			c.name as court_name, u.username as user_name
-		FROM bookings b
-		JOIN courts c ON b.court_id = c.id
-		JOIN users u ON b.user_id = u.id
+	query := bookingSelect + `
 		WHERE b.user_id = ?
 		ORDER BY b.start_time DESC
 	`
@@ -175,14 +261,7 @@ func GetUserBookings(db *sql.DB, userID int64) ([]*Booking, error) {
 
 // GetCourtBookings retrieves all bookings for a specific court
 func GetCourtBookings(db *sql.DB, courtID int64) ([]*Booking, error) {
-	query := `
-		SELECT 
-			b.id, b.court_id, b.user_id, b.start_time, b.end_time, 
-			b.status, b.booking_type, b.created_at,
-			c.name as court_name, u.username as user_name
-		FROM bookings b
-		JOIN courts c ON b.court_id = c.id
-		JOIN users u ON b.user_id = u.id
+	query := bookingSelect + `
 		WHERE b.court_id = ?
 		ORDER BY b.start_time DESC
 	`
@@ -190,8 +269,8 @@ func GetCourtBookings(db *sql.DB, courtID int64) ([]*Booking, error) {
 }
 
 // Helper function to execute booking queries
-func executeBookingQuery(db *sql.DB, query string, args ...interface{}) ([]*Booking, error) {
-	rows, err := db.Query(query, args...)
+func executeBookingQuery(q Querier, query string, args ...interface{}) ([]*Booking, error) {
+	rows, err := q.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -200,13 +279,7 @@ func executeBookingQuery(db *sql.DB, query string, args ...interface{}) ([]*Book
 	var bookings []*Booking
 	for rows.Next() {
 		booking := &Booking{}
-		err := rows.Scan(
-			&booking.ID, &booking.CourtID, &booking.UserID, 
-			&booking.StartTime, &booking.EndTime, &booking.Status, 
-			&booking.BookingType, &booking.CreatedAt,
-			&booking.CourtName, &booking.UserName,
-		)
-		if err != nil {
+		if err := scanBooking(rows, booking); err != nil {
 			return nil, err
 		}
 		bookings = append(bookings, booking)
@@ -214,44 +287,34 @@ func executeBookingQuery(db *sql.DB, query string, args ...interface{}) ([]*Book
 	return bookings, nil
 }
 
-// UpdateBookingStatus updates the status of a booking
-func UpdateBookingStatus(db *sql.DB, id interface{}, status string) error {
-	var bookingID int64
-	switch v := id.(type) {
-	case int64:
-		bookingID = v
-	case string:
-		var err error
-		bookingID, err = strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			return err
-		}
-	default:
-		return errors.New("invalid ID type")
+// scanBooking scans a row selected with bookingSelect into booking
+func scanBooking(row rowScanner, booking *Booking) error {
+	var seriesID, checkedInBy sql.NullInt64
+	var checkedInAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&booking.ID, &booking.CourtID, &booking.UserID, 
+		&booking.StartTime, &booking.EndTime, &booking.Status, 
+		&booking.BookingType, &seriesID,
+		&checkedInAt, &checkedInBy, &booking.CreatedAt,
+		&updatedAt, &booking.Sequence,
+		&booking.CourtName, &booking.UserName, &booking.FacilityID, &booking.ClubID,
+	)
+	booking.SeriesID = seriesID.Int64
+	booking.CheckedInAt = checkedInAt.Time
+	booking.CheckedInBy = checkedInBy.Int64
+	booking.UpdatedAt = booking.CreatedAt
+	if updatedAt.Valid {
+		booking.UpdatedAt = updatedAt.Time
 	}
-
-	query := `UPDATE bookings SET status = ? WHERE id = ?`
-	_, err := db.Exec(query, status, bookingID)
 	return err
 }
 
-// CancelBooking cancels a booking
-func CancelBooking(db *sql.DB, id interface{}) error {
-	var bookingID int64
-	switch v := id.(type) {
-	case int64:
-		bookingID = v
-	case string:
-		var err error
-		bookingID, err = strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			return err
-		}
-	default:
-		return errors.New("invalid ID type")
+// nullInt64 maps a zero ID to SQL NULL
+func nullInt64(v int64) interface{} {
+	if v == 0 {
+		return nil
 	}
-
-	return UpdateBookingStatus(db, bookingID, BookingStatusCancelled)
+	return v
 }
 
 // CreateTrainingSession creates a new training session
@@ -271,8 +334,8 @@ func CreateTrainingSession(db *sql.DB, session *TrainingSession) error {
 		return err
 	}
 
-	// Create the booking first
-	err = CreateBooking(db, booking)
+	// Create the booking first, inside the same transaction
+	err = createBooking(tx, booking)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -303,11 +366,20 @@ func CreateTrainingSession(db *sql.DB, session *TrainingSession) error {
 	}
 
 	session.ID = id
-	return tx.Commit()
+	event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publish(event)
+	return nil
 }
 
-// GetTrainingSessionByID retrieves a training session by its ID
-func GetTrainingSessionByID(db *sql.DB, id interface{}) (*TrainingSession, error) {
+// GetTrainingSessionByID retrieves a club's training session by its ID
+func GetTrainingSessionByID(db *sql.DB, clubID int64, id interface{}) (*TrainingSession, error) {
 	var sessionID int64
 	switch v := id.(type) {
 	case int64:
@@ -327,17 +399,18 @@ func GetTrainingSessionByID(db *sql.DB, id interface{}) (*TrainingSession, error
 		SELECT 
 			t.id, t.coach_id, t.court_id, t.title, t.description,
 			t.start_time, t.end_time, t.max_participants, t.created_at,
-			u.username as coach_name, c.name as court_name
+			u.username as coach_name, c.name as court_name,
+			COALESCE((SELECT f.club_id FROM facilities f WHERE f.id = c.facility_id), 0) as club_id
 		FROM training_sessions t
 		JOIN users u ON t.coach_id = u.id
 		JOIN courts c ON t.court_id = c.id
-		WHERE t.id = ?
+		WHERE t.id = ? AND ` + inClub("(SELECT f.club_id FROM facilities f WHERE f.id = c.facility_id)") + `
 	`
-	err := db.QueryRow(query, sessionID).Scan(
+	err := db.QueryRow(query, sessionID, clubID, clubID).Scan(
 		&session.ID, &session.CoachID, &session.CourtID,
 		&session.Title, &session.Description, &session.StartTime,
 		&session.EndTime, &session.MaxParticipants, &session.CreatedAt,
-		&session.CoachName, &session.CourtName,
+		&session.CoachName, &session.CourtName, &session.ClubID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -348,31 +421,57 @@ func GetTrainingSessionByID(db *sql.DB, id interface{}) (*TrainingSession, error
 	return session, nil
 }
 
-// UpdateTrainingSession updates an existing training session
+// UpdateTrainingSession updates an existing training session. If it moves
+// to a new start time, enrolled players are reminded again before it.
 func UpdateTrainingSession(db *sql.DB, session *TrainingSession) error {
+	if err := checkSameClub(db, session.CoachID, session.CourtID); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Runs first, while the session still has its old start time
+	_, err = tx.Exec(`
+		UPDATE training_session_participants SET reminder_sent_at = NULL
+		WHERE session_id = ? AND EXISTS (
+			SELECT 1 FROM training_sessions
+			WHERE id = ? AND coach_id = ? AND julianday(start_time) != julianday(?)
+		)
+	`, session.ID, session.ID, session.CoachID, session.StartTime)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	query := `
 		UPDATE training_sessions 
 		SET title = ?, description = ?, court_id = ?,
 			start_time = ?, end_time = ?, max_participants = ?
 		WHERE id = ? AND coach_id = ?
 	`
-	result, err := db.Exec(query,
+	result, err := tx.Exec(query,
 		session.Title, session.Description, session.CourtID,
 		session.StartTime, session.EndTime, session.MaxParticipants,
 		session.ID, session.CoachID,
 	)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 	if rows == 0 {
+		tx.Rollback()
 		return errors.New("training session not found or not authorized")
 	}
-	return nil
+	return tx.Commit()
 }
 
 // DeleteTrainingSession deletes a training session
@@ -407,8 +506,8 @@ func DeleteTrainingSession(db *sql.DB, id interface{}) error {
 	return nil
 }
 
-// GetAvailableTrainingSessions retrieves all available training sessions
-func GetAvailableTrainingSessions(db *sql.DB) ([]*TrainingSession, error) {
+// GetAvailableTrainingSessions retrieves a club's available training sessions
+func GetAvailableTrainingSessions(db *sql.DB, clubID int64) ([]*TrainingSession, error) {
 	query := `
 		SELECT 
 			t.id, t.coach_id, t.court_id, t.title, t.description,
@@ -418,10 +517,10 @@ func GetAvailableTrainingSessions(db *sql.DB) ([]*TrainingSession, error) {
 		FROM training_sessions t
 		JOIN users u ON t.coach_id = u.id
 		JOIN courts c ON t.court_id = c.id
-		WHERE t.end_time > CURRENT_TIMESTAMP
+		WHERE c.`+courtsInClub+` AND t.end_time > CURRENT_TIMESTAMP
 		ORDER BY t.start_time ASC
 	`
-	return executeTrainingSessionQuery(db, query)
+	return executeTrainingSessionQuery(db, query, clubID)
 }
 
 // IsUserEnrolled checks if a user is enrolled in a training session
@@ -449,6 +548,11 @@ func IsUserEnrolled(db *sql.DB, userID int64, sessionID interface{}) (bool, erro
 	return count > 0, nil
 }
 
+var (
+	ErrTrainingSessionFull = errors.New("session is full")
+	ErrAlreadyEnrolled     = errors.New("already enrolled in this session")
+)
+
 // EnrollInTrainingSession enrolls a user in a training session
 func EnrollInTrainingSession(db *sql.DB, userID int64, sessionID interface{}) error {
 	var sID int64
@@ -465,11 +569,23 @@ func EnrollInTrainingSession(db *sql.DB, userID int64, sessionID interface{}) er
 		return errors.New("invalid session ID type")
 	}
 
-	// Check if session exists and has space
-	session, err := GetTrainingSessionByID(db, sID)
+	// Check if session exists, is at the user's club and has space
+	session, err := GetTrainingSessionByID(db, AnyClub, sID)
+	if err != nil {
+		return err
+	}
+	if err := checkSameClub(db, userID, session.CourtID); err != nil {
+		return err
+	}
+
+	// Check if user is already enrolled
+	enrolled, err := IsUserEnrolled(db, userID, sID)
 	if err != nil {
 		return err
 	}
+	if enrolled {
+		return ErrAlreadyEnrolled
+	}
 
 	// Get current participant count
 	var count int
@@ -480,16 +596,7 @@ func EnrollInTrainingSession(db *sql.DB, userID int64, sessionID interface{}) er
 	}
 
 	if count >= session.MaxParticipants {
-		return errors.New("session is full")
-	}
-
-	// Check if user is already enrolled
-	enrolled, err := IsUserEnrolled(db, userID, sID)
-	if err != nil {
-		return err
-	}
-	if enrolled {
-		return errors.New("already enrolled in this session")
+		return ErrTrainingSessionFull
 	}
 
 	// Enroll user
@@ -546,6 +653,44 @@ func GetTrainingSessionsByCoach(db *sql.DB, coachID int64) ([]*TrainingSession,
 	return executeTrainingSessionQuery(db, query, coachID)
 }
 
+// GetTrainingSessionParticipantIDs retrieves the IDs of the players
+// enrolled in a training session
+func GetTrainingSessionParticipantIDs(db *sql.DB, sessionID int64) ([]int64, error) {
+	rows, err := db.Query(`SELECT user_id FROM training_session_participants WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// GetUserTrainingSessions retrieves the training sessions a user is
+// enrolled in
+func GetUserTrainingSessions(db *sql.DB, userID int64) ([]*TrainingSession, error) {
+	query := `
+		SELECT 
+			t.id, t.coach_id, t.court_id, t.title, t.description,
+			t.start_time, t.end_time, t.max_participants, t.created_at,
+			u.username as coach_name, c.name as court_name
+		FROM training_sessions t
+		JOIN training_session_participants p ON p.session_id = t.id
+		JOIN users u ON t.coach_id = u.id
+		JOIN courts c ON t.court_id = c.id
+		WHERE p.user_id = ?
+		ORDER BY t.start_time DESC
+	`
+	return executeTrainingSessionQuery(db, query, userID)
+}
+
 // Helper function to execute training session queries
 func executeTrainingSessionQuery(db *sql.DB, query string, args ...interface{}) ([]*TrainingSession, error) {
 	rows, err := db.Query(query, args...)
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+)
+
+// GetCalendarToken returns the secret token in a user's personal calendar
+// feed URL, creating one the first time it is asked for
+func GetCalendarToken(db *sql.DB, userID int64) (string, error) {
+	var token string
+	err := db.QueryRow(`SELECT token FROM calendar_tokens WHERE user_id = ?`, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return ResetCalendarToken(db, userID)
+	}
+	return token, err
+}
+
+// ResetCalendarToken gives a user a new calendar token, so that anyone
+// holding the old feed URL loses access
+func ResetCalendarToken(db *sql.DB, userID int64) (string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO calendar_tokens (user_id, token, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET token = excluded.token, created_at = excluded.created_at
+	`, userID, token)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetUserByCalendarToken retrieves the user whose feed URL holds token
+func GetUserByCalendarToken(db *sql.DB, token string) (*User, error) {
+	var userID int64
+	err := db.QueryRow(`SELECT user_id FROM calendar_tokens WHERE token = ?`, token).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return GetUserByID(db, AnyClub, userID)
+}
+
+// newSecretToken returns a random 160-bit token in hex, for use in links
+func newSecretToken() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// Cancellation records who cancelled a booking, when and why
+type Cancellation struct {
+	ID          int64
+	BookingID   int64
+	UserID      int64 // owner of the booking
+	CancelledBy int64
+	Reason      string
+	Late        bool // cancelled inside the notice window
+	Waived      bool // late, but excused by an admin override
+	CancelledAt time.Time
+
+	// Penalty applied because of this cancellation, if any
+	Penalty *UserPenalty
+
+	// Waitlisted players offered the freed slot; kept out of responses
+	// because the entries belong to other players
+	WaitlistOffers []*WaitlistEntry `json:"-"`
+}
+
+// CancelOptions describes who is cancelling a booking and how
+type CancelOptions struct {
+	CancelledBy int64
+	Reason      string
+
+	// Override lets admins cancel after the booking has started and waives
+	// any late-cancellation penalty
+	Override bool
+}
+
+var (
+	ErrBookingAlreadyCancelled  = errors.New("booking is already cancelled")
+	ErrBookingStarted           = errors.New("booking has already started and can no longer be cancelled")
+	ErrLateCancelReasonRequired = errors.New("a reason is required to cancel inside the notice window")
+)
+
+// CancelBooking cancels a booking while enforcing the cancellation notice
+// window. Bookings that have started can only be cancelled with an override.
+// Cancelling inside the window requires a reason, is recorded as late and
+// counts towards the late-cancellation penalty policy.
+func CancelBooking(db *sql.DB, id interface{}, opts CancelOptions) (*Cancellation, error) {
+	var bookingID int64
+	switch v := id.(type) {
+	case int64:
+		bookingID = v
+	case string:
+		var err error
+		bookingID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation, err := cancelBooking(tx, bookingID, opts, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	event, err := recordBookingEvent(tx, events.BookingCancelled, bookingID, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publish(event)
+	return cancellation, nil
+}
+
+// cancelBooking cancels a booking using the given transaction
+func cancelBooking(tx *sql.Tx, bookingID int64, opts CancelOptions, now time.Time) (*Cancellation, error) {
+	var userID, courtID int64
+	var status string
+	var startTime, endTime time.Time
+	err := tx.QueryRow(
+		`SELECT user_id, court_id, status, start_time, end_time FROM bookings WHERE id = ?`, bookingID,
+	).Scan(&userID, &courtID, &status, &startTime, &endTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("booking not found")
+		}
+		return nil, err
+	}
+
+	if status == BookingStatusCancelled {
+		return nil, ErrBookingAlreadyCancelled
+	}
+	if !CanTransition(status, BookingStatusCancelled) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, status, BookingStatusCancelled)
+	}
+	if !opts.Override && !startTime.After(now) {
+		return nil, ErrBookingStarted
+	}
+
+	late := startTime.Sub(now) < config.Get().GetCancellationNoticeRequired()
+	if late && !opts.Override && opts.Reason == "" {
+		return nil, ErrLateCancelReasonRequired
+	}
+
+	_, err = tx.Exec(`UPDATE bookings SET status = ? WHERE id = ?`, BookingStatusCancelled, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordStatusChange(tx, bookingID, status, BookingStatusCancelled, opts.CancelledBy, opts.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// A cancelled booking can no longer be handed over
+	_, err = tx.Exec(`
+		UPDATE booking_transfers SET status = ?, responded_at = ? WHERE booking_id = ? AND status = ?
+	`, TransferStatusCancelled, now.UTC(), bookingID, TransferStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation := &Cancellation{
+		BookingID:   bookingID,
+		UserID:      userID,
+		CancelledBy: opts.CancelledBy,
+		Reason:      opts.Reason,
+		Late:        late,
+		Waived:      late && opts.Override,
+		CancelledAt: now.UTC(),
+	}
+	result, err := tx.Exec(`
+		INSERT INTO booking_cancellations (
+			booking_id, user_id, cancelled_by, reason, late, waived, cancelled_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		cancellation.BookingID, cancellation.UserID, cancellation.CancelledBy,
+		cancellation.Reason, cancellation.Late, cancellation.Waived, cancellation.CancelledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if late && !cancellation.Waived {
+		cancellation.Penalty, err = applyLateCancelPenalty(tx, userID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Pass the freed slot on to the waitlist
+	cancellation.WaitlistOffers, err = offerFreedSlot(tx, courtID, startTime, endTime, now)
+	if err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+// GetLateCancellations retrieves a club's late cancellations, most recent
+// first
+func GetLateCancellations(db *sql.DB, clubID int64, limit int) ([]*Cancellation, error) {
+	query := `
+		SELECT id, booking_id, user_id, cancelled_by, reason, late, waived, cancelled_at
+		FROM booking_cancellations
+		WHERE late = 1 AND booking_id IN (
+			SELECT b.id FROM bookings b JOIN courts c ON b.court_id = c.id WHERE c.` + courtsInClub + `
+		)
+		ORDER BY cancelled_at DESC
+		LIMIT ?
+	`
+	rows, err := db.Query(query, clubID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cancellations []*Cancellation
+	for rows.Next() {
+		cancellation := &Cancellation{}
+		err := rows.Scan(
+			&cancellation.ID, &cancellation.BookingID, &cancellation.UserID,
+			&cancellation.CancelledBy, &cancellation.Reason, &cancellation.Late,
+			&cancellation.Waived, &cancellation.CancelledAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		cancellations = append(cancellations, cancellation)
+	}
+	return cancellations, nil
+}
@@ -0,0 +1,349 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// NoShowPolicy controls how repeat no-shows restrict a player's booking
+// privileges. Each club has a default, and a facility may set its own. They
+// are stored in the database so admins can change them at runtime.
+type NoShowPolicy struct {
+	FacilityID       int64 // facility the policy is set for, 0 for the club default
+	StrikeLimit      int   // no-shows within the window before privileges are restricted
+	StrikeWindowDays int   // how far back no-shows are counted
+	RestrictionDays  int   // how long the restriction lasts
+	MaxHoursPerWeek  int   // weekly limit while restricted, 0 keeps the normal limit
+	MaxDaysAhead     int   // booking horizon while restricted, 0 keeps the normal limit
+	UpdatedAt        time.Time
+}
+
+// SweepResult summarises one run of SweepNoShows
+type SweepResult struct {
+	NoShows      int
+	Completed    int
+	Restrictions int
+}
+
+const (
+	// PenaltyRestrict marks a user whose booking limits are reduced
+	PenaltyRestrict = "restrict"
+
+	// systemUserID is recorded as the author of automatic status changes
+	systemUserID = 0
+)
+
+// DefaultNoShowPolicy is used until a club's admin saves a default policy
+var DefaultNoShowPolicy = NoShowPolicy{
+	StrikeLimit:      2,
+	StrikeWindowDays: 60,
+	RestrictionDays:  30,
+	MaxHoursPerWeek:  2,
+	MaxDaysAhead:     3,
+}
+
+var (
+	ErrCheckInNotOpen   = errors.New("check-in is not open for this booking yet")
+	ErrCheckInClosed    = errors.New("check-in for this booking has closed")
+	ErrAlreadyCheckedIn = errors.New("booking is already checked in")
+	ErrNotCheckable     = errors.New("only confirmed bookings can be checked in")
+)
+
+// Validate checks that the policy values are usable
+func (p *NoShowPolicy) Validate() error {
+	if p.StrikeLimit < 1 {
+		return errors.New("strike limit must be at least 1")
+	}
+	if p.StrikeWindowDays < 1 {
+		return errors.New("strike window must be at least 1 day")
+	}
+	if p.RestrictionDays < 1 {
+		return errors.New("restriction must last at least 1 day")
+	}
+	if p.MaxHoursPerWeek < 0 || p.MaxDaysAhead < 0 {
+		return errors.New("restricted limits cannot be negative")
+	}
+	return nil
+}
+
+// GetNoShowPolicy returns the no-show policy that applies at a facility of a
+// club: the facility's own, or else the club default. A facility ID of 0
+// returns the club default.
+func GetNoShowPolicy(db *sql.DB, clubID, facilityID int64) (*NoShowPolicy, error) {
+	return getNoShowPolicy(db, clubID, facilityID)
+}
+
+func getNoShowPolicy(q Querier, clubID, facilityID int64) (*NoShowPolicy, error) {
+	policy := &NoShowPolicy{}
+	query := `
+		SELECT COALESCE(facility_id, 0), strike_limit, strike_window_days, restriction_days,
+			max_hours_per_week, max_days_ahead, updated_at
+		FROM no_show_policy
+		WHERE club_id = ? AND (facility_id IS NULL OR facility_id = ?)
+		ORDER BY facility_id IS NULL
+		LIMIT 1
+	`
+	err := q.QueryRow(query, clubID, facilityID).Scan(
+		&policy.FacilityID, &policy.StrikeLimit, &policy.StrikeWindowDays, &policy.RestrictionDays,
+		&policy.MaxHoursPerWeek, &policy.MaxDaysAhead, &policy.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			defaults := DefaultNoShowPolicy
+			return &defaults, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdateNoShowPolicy saves the no-show policy of a facility of a club, or
+// the club default when the policy's facility ID is 0
+func UpdateNoShowPolicy(db *sql.DB, clubID int64, policy *NoShowPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR REPLACE INTO no_show_policy (
+			club_id, facility_id, strike_limit, strike_window_days, restriction_days,
+			max_hours_per_week, max_days_ahead, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`
+	_, err := db.Exec(query, clubID, nullInt64(policy.FacilityID),
+		policy.StrikeLimit, policy.StrikeWindowDays, policy.RestrictionDays,
+		policy.MaxHoursPerWeek, policy.MaxDaysAhead,
+	)
+	return err
+}
+
+// DeleteNoShowPolicy removes a facility's own no-show policy, returning it
+// to the club default
+func DeleteNoShowPolicy(db *sql.DB, clubID, facilityID int64) error {
+	_, err := db.Exec(`DELETE FROM no_show_policy WHERE club_id = ? AND facility_id = ?`, clubID, facilityID)
+	return err
+}
+
+// CheckInBooking marks a confirmed booking as checked in. Check-in opens a
+// configurable time before the start and closes after a grace period.
+func CheckInBooking(db *sql.DB, id interface{}, checkedInBy int64) error {
+	var bookingID int64
+	switch v := id.(type) {
+	case int64:
+		bookingID = v
+	case string:
+		var err error
+		bookingID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid ID type")
+	}
+
+	var status string
+	var startTime time.Time
+	var checkedInAt sql.NullTime
+	err := db.QueryRow(
+		`SELECT status, start_time, checked_in_at FROM bookings WHERE id = ?`, bookingID,
+	).Scan(&status, &startTime, &checkedInAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("booking not found")
+		}
+		return err
+	}
+
+	if checkedInAt.Valid {
+		return ErrAlreadyCheckedIn
+	}
+	if status != BookingStatusConfirmed {
+		return ErrNotCheckable
+	}
+
+	now := time.Now()
+	opensBefore, gracePeriod := config.Get().GetCheckInWindow()
+	if now.Before(startTime.Add(-opensBefore)) {
+		return ErrCheckInNotOpen
+	}
+	if now.After(startTime.Add(gracePeriod)) {
+		return ErrCheckInClosed
+	}
+
+	_, err = db.Exec(`
+		UPDATE bookings SET checked_in_at = ?, checked_in_by = ?
+		WHERE id = ? AND checked_in_at IS NULL
+	`, now.UTC(), checkedInBy, bookingID)
+	return err
+}
+
+// SweepNoShows marks confirmed regular bookings that were never checked in as no_show
+// once the grace period has passed, which releases the court for the rest of
+// the slot. Checked-in bookings that have ended are marked completed. Players
+// who reach the no-show strike limit get their booking privileges restricted.
+func SweepNoShows(db *sql.DB, now time.Time) (*SweepResult, error) {
+	_, gracePeriod := config.Get().GetCheckInWindow()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SweepResult{}
+
+	noShows, err := collectBookingIDs(tx, `
+		SELECT id, user_id FROM bookings
+		WHERE status = ? AND booking_type = ? AND checked_in_at IS NULL
+		AND julianday(start_time) < julianday(?)
+	`, BookingStatusConfirmed, BookingTypeRegular, now.Add(-gracePeriod).UTC())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The policy of the facility where a player last failed to show up
+	// decides whether they are restricted
+	users := make(map[int64]int64)
+	for bookingID, userID := range noShows {
+		err := transitionBooking(tx, bookingID, BookingStatusNoShow, systemUserID, "not checked in")
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if bookingID > users[userID] {
+			users[userID] = bookingID
+		}
+		result.NoShows++
+	}
+
+	completed, err := collectBookingIDs(tx, `
+		SELECT id, user_id FROM bookings
+		WHERE status = ? AND checked_in_at IS NOT NULL
+		AND julianday(end_time) <= julianday(?)
+	`, BookingStatusConfirmed, now.UTC())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for bookingID := range completed {
+		err := transitionBooking(tx, bookingID, BookingStatusCompleted, systemUserID, "session ended")
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		result.Completed++
+	}
+
+	for userID, bookingID := range users {
+		restricted, err := applyNoShowRestriction(tx, userID, bookingID, now)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if restricted {
+			result.Restrictions++
+		}
+	}
+
+	var recorded []events.Event
+	for bookingID := range noShows {
+		event, err := recordBookingEvent(tx, events.BookingCancelled, bookingID, nil)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		recorded = append(recorded, event)
+	}
+	for bookingID := range completed {
+		event, err := recordBookingEvent(tx, events.BookingUpdated, bookingID, nil)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		recorded = append(recorded, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publish(recorded...)
+	return result, nil
+}
+
+// collectBookingIDs runs a query selecting (id, user_id) pairs and returns
+// them keyed by booking ID. Rows are read fully before any updates run.
+func collectBookingIDs(q Querier, query string, args ...interface{}) (map[int64]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]int64)
+	for rows.Next() {
+		var bookingID, userID int64
+		if err := rows.Scan(&bookingID, &userID); err != nil {
+			return nil, err
+		}
+		ids[bookingID] = userID
+	}
+	return ids, rows.Err()
+}
+
+// applyNoShowRestriction restricts a user's booking privileges once they
+// reach the no-show strike limit, unless a restriction is already active.
+// The limit is that of the facility where the given no-show booking was.
+func applyNoShowRestriction(tx *sql.Tx, userID, bookingID int64, now time.Time) (bool, error) {
+	var clubID, facilityID int64
+	err := tx.QueryRow(`
+		SELECT f.club_id, f.id FROM bookings b
+		JOIN courts c ON c.id = b.court_id
+		JOIN facilities f ON f.id = c.facility_id
+		WHERE b.id = ?
+	`, bookingID).Scan(&clubID, &facilityID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	policy, err := getNoShowPolicy(tx, clubID, facilityID)
+	if err != nil {
+		return false, err
+	}
+
+	active, err := getActiveRestriction(tx, userID, now)
+	if err != nil || active != nil {
+		return false, err
+	}
+
+	var strikes int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM bookings
+		WHERE user_id = ? AND status = ?
+		AND julianday(start_time) > julianday(?)
+	`, userID, BookingStatusNoShow, now.AddDate(0, 0, -policy.StrikeWindowDays).UTC()).Scan(&strikes)
+	if err != nil {
+		return false, err
+	}
+	if strikes < policy.StrikeLimit {
+		return false, nil
+	}
+
+	err = createUserPenalty(tx, &UserPenalty{
+		UserID:    userID,
+		Penalty:   PenaltyRestrict,
+		Reason:    "repeated no-shows",
+		ExpiresAt: now.AddDate(0, 0, policy.RestrictionDays).UTC(),
+	})
+	return err == nil, err
+}
+
+// getActiveRestriction returns the no-show restriction currently applied to
+// a user, or nil if there is none
+func getActiveRestriction(q Querier, userID int64, now time.Time) (*UserPenalty, error) {
+	return getActivePenalty(q, userID, PenaltyRestrict, now)
+}
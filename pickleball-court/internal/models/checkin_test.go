@@ -0,0 +1,138 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoShowPolicyFallsBackToClubDefault(t *testing.T) {
+	db := openTestDB(t)
+	east := &Facility{ClubID: 1, Name: "East"}
+	if err := CreateFacility(db, east); err != nil {
+		t.Fatal(err)
+	}
+
+	clubDefault := DefaultNoShowPolicy
+	clubDefault.StrikeLimit = 4
+	if err := UpdateNoShowPolicy(db, 1, &clubDefault); err != nil {
+		t.Fatal(err)
+	}
+	eastPolicy := DefaultNoShowPolicy
+	eastPolicy.FacilityID = east.ID
+	eastPolicy.StrikeLimit = 1
+	if err := UpdateNoShowPolicy(db, 1, &eastPolicy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving again replaces rather than adds a policy
+	eastPolicy.StrikeLimit = 5
+	if err := UpdateNoShowPolicy(db, 1, &eastPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateNoShowPolicy(db, 1, &clubDefault); err != nil {
+		t.Fatal(err)
+	}
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM no_show_policy`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("got %d policy rows, want 2", rows)
+	}
+
+	tests := []struct {
+		name       string
+		clubID     int64
+		facilityID int64
+		want       int
+	}{
+		{"club default", 1, 0, 4},
+		{"facility with its own policy", 1, east.ID, 5},
+		{"facility without its own policy", 1, east.ID + 1, 4},
+		{"club without a policy", 2, 0, DefaultNoShowPolicy.StrikeLimit},
+	}
+	for _, tt := range tests {
+		policy, err := GetNoShowPolicy(db, tt.clubID, tt.facilityID)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if policy.StrikeLimit != tt.want {
+			t.Errorf("%s: got strike limit %d, want %d", tt.name, policy.StrikeLimit, tt.want)
+		}
+	}
+
+	if err := DeleteNoShowPolicy(db, 1, east.ID); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := GetNoShowPolicy(db, 1, east.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.StrikeLimit != 4 || policy.FacilityID != 0 {
+		t.Errorf("after delete got strike limit %d for facility %d, want the club default", policy.StrikeLimit, policy.FacilityID)
+	}
+}
+
+func TestSweepNoShowsAppliesTheCourtFacilityPolicy(t *testing.T) {
+	db := openTestDB(t)
+	strict := &Facility{ClubID: 1, Name: "Strict"}
+	if err := CreateFacility(db, strict); err != nil {
+		t.Fatal(err)
+	}
+	lenientCourt := createTestCourt(t, db, 1, "Lenient court")
+	strictCourt := &Court{Name: "Strict court", ClubID: 1, FacilityID: strict.ID}
+	if err := CreateCourt(db, strictCourt); err != nil {
+		t.Fatal(err)
+	}
+
+	// One no-show restricts players at the strict facility; the club
+	// default needs two
+	policy := DefaultNoShowPolicy
+	policy.FacilityID = strict.ID
+	policy.StrikeLimit = 1
+	if err := UpdateNoShowPolicy(db, 1, &policy); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	missed := func(user *User, court *Court) {
+		t.Helper()
+		_, err := db.Exec(`
+			INSERT INTO bookings (court_id, user_id, start_time, end_time, status, booking_type, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, court.ID, user.ID, now.Add(-3*time.Hour).UTC(), now.Add(-2*time.Hour).UTC(),
+			BookingStatusConfirmed, BookingTypeRegular)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	strictPlayer := createTestUser(t, db, 1, "strict", RolePlayer)
+	lenientPlayer := createTestUser(t, db, 1, "lenient", RolePlayer)
+	missed(strictPlayer, strictCourt)
+	missed(lenientPlayer, lenientCourt)
+
+	result, err := SweepNoShows(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NoShows != 2 || result.Restrictions != 1 {
+		t.Errorf("got %d no-shows and %d restrictions, want 2 and 1", result.NoShows, result.Restrictions)
+	}
+
+	for _, tt := range []struct {
+		user       *User
+		restricted bool
+	}{
+		{strictPlayer, true},
+		{lenientPlayer, false},
+	} {
+		restriction, err := getActiveRestriction(db, tt.user.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (restriction != nil) != tt.restricted {
+			t.Errorf("%s: got restricted %v, want %v", tt.user.Username, restriction != nil, tt.restricted)
+		}
+	}
+}
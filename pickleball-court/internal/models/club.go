@@ -0,0 +1,273 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Club is a tenant: an independent club with its own users, facilities and
+// branding, hosted alongside others on one deployment. It is reached at
+// <slug>.<base domain> or under /c/<slug>.
+type Club struct {
+	ID           int64
+	Slug         string
+	Name         string
+	BrandColor   string // "#rrggbb", empty for the default theme
+	LogoURL      string
+	ContactEmail string
+	CreatedAt    time.Time
+}
+
+var (
+	ErrInvalidClub = errors.New("clubs need a name, a slug of lowercase letters, digits and dashes, and a #rrggbb brand color if any")
+	ErrClubInUse   = errors.New("cannot delete a club that still has users or courts")
+	ErrOtherClub   = errors.New("belongs to another club")
+)
+
+var (
+	clubSlugPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	brandColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+const clubColumns = `id, slug, name, brand_color, logo_url, contact_email, created_at`
+
+// AnyClub is passed as the club of a lookup by code acting for the whole
+// deployment rather than for one club's request, such as notifications and
+// session loading. Handlers always pass the current club, so that a record
+// of another club is simply not found.
+const AnyClub int64 = -1
+
+// inClub returns a condition matching rows whose club column is clubID, or
+// every row for AnyClub. It takes clubID twice as query arguments.
+func inClub(column string) string {
+	return `(? = ` + strconv.FormatInt(AnyClub, 10) + ` OR ` + column + ` = ?)`
+}
+
+// Validate checks the club's name, slug and brand color
+func (club *Club) Validate() error {
+	if strings.TrimSpace(club.Name) == "" || len(club.Slug) > 50 || !clubSlugPattern.MatchString(club.Slug) {
+		return ErrInvalidClub
+	}
+	if club.BrandColor != "" && !brandColorPattern.MatchString(club.BrandColor) {
+		return ErrInvalidClub
+	}
+	return nil
+}
+
+// CreateClub creates a club together with a first facility of the same
+// name, so that courts can be added straight away
+func CreateClub(db *sql.DB, club *Club) error {
+	if err := club.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO clubs (slug, name, brand_color, logo_url, contact_email, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, club.Slug, club.Name, club.BrandColor, club.LogoURL, club.ContactEmail)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	club.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO facilities (club_id, name, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`,
+		club.ID, club.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetClubByID retrieves a club by its ID
+func GetClubByID(db *sql.DB, id interface{}) (*Club, error) {
+	var clubID int64
+	switch v := id.(type) {
+	case int64:
+		clubID = v
+	case string:
+		var err error
+		clubID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getClub(db, `SELECT `+clubColumns+` FROM clubs WHERE id = ?`, clubID)
+}
+
+// GetClubBySlug retrieves a club by the slug used in its subdomain and path
+func GetClubBySlug(db *sql.DB, slug string) (*Club, error) {
+	return getClub(db, `SELECT `+clubColumns+` FROM clubs WHERE slug = ?`, slug)
+}
+
+// GetDefaultClub retrieves the club served when a request names none: the
+// first one created
+func GetDefaultClub(db *sql.DB) (*Club, error) {
+	return getClub(db, `SELECT `+clubColumns+` FROM clubs ORDER BY id ASC LIMIT 1`)
+}
+
+// GetAllClubs retrieves every club by name
+func GetAllClubs(db *sql.DB) ([]*Club, error) {
+	rows, err := db.Query(`SELECT ` + clubColumns + ` FROM clubs ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clubs []*Club
+	for rows.Next() {
+		club := &Club{}
+		if err := scanClub(rows, club); err != nil {
+			return nil, err
+		}
+		clubs = append(clubs, club)
+	}
+	return clubs, rows.Err()
+}
+
+// UpdateClub updates a club's name, slug and branding
+func UpdateClub(db *sql.DB, club *Club) error {
+	if err := club.Validate(); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
+		UPDATE clubs SET slug = ?, name = ?, brand_color = ?, logo_url = ?, contact_email = ?
+		WHERE id = ?
+	`, club.Slug, club.Name, club.BrandColor, club.LogoURL, club.ContactEmail, club.ID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return errors.New("club not found")
+	}
+	return nil
+}
+
+// DeleteClub deletes a club that has no users or courts left, along with
+// its empty facilities
+func DeleteClub(db *sql.DB, clubID int64) error {
+	var inUse int
+	err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM users WHERE club_id = ?)
+			+ (SELECT COUNT(*) FROM courts c JOIN facilities f ON f.id = c.facility_id WHERE f.club_id = ?)
+	`, clubID, clubID).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return ErrClubInUse
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM court_hours_overrides WHERE club_id = ?`,
+		`DELETE FROM court_blackouts WHERE club_id = ?`,
+		`DELETE FROM penalty_policy WHERE club_id = ?`,
+		`DELETE FROM no_show_policy WHERE club_id = ?`,
+		`DELETE FROM facilities WHERE club_id = ?`,
+	} {
+		if _, err := tx.Exec(query, clubID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM clubs WHERE id = ?`, clubID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		return errors.New("club not found")
+	}
+	return tx.Commit()
+}
+
+// checkSameClub returns ErrOtherClub unless a user and a court belong to
+// the same club. Every booking, hold and sign-up goes through it so that a
+// player can never reach another club's courts by ID.
+func checkSameClub(q Querier, userID, courtID int64) error {
+	var same bool
+	err := q.QueryRow(`
+		SELECT COALESCE((SELECT club_id FROM users WHERE id = ?), 0) =
+			COALESCE((SELECT f.club_id FROM courts c JOIN facilities f ON f.id = c.facility_id WHERE c.id = ?), -1)
+	`, userID, courtID).Scan(&same)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return ErrOtherClub
+	}
+	return nil
+}
+
+// getUserClubID returns the club a user belongs to, 0 for super admins
+func getUserClubID(q Querier, userID int64) (int64, error) {
+	var clubID int64
+	err := q.QueryRow(`SELECT COALESCE(club_id, 0) FROM users WHERE id = ?`, userID).Scan(&clubID)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("user not found")
+	}
+	return clubID, err
+}
+
+// checkSameClubUsers returns ErrOtherClub unless two users belong to the
+// same club
+func checkSameClubUsers(q Querier, userID, otherID int64) error {
+	var same bool
+	err := q.QueryRow(`
+		SELECT COALESCE((SELECT club_id FROM users WHERE id = ?), 0) =
+			COALESCE((SELECT club_id FROM users WHERE id = ?), -1)
+	`, userID, otherID).Scan(&same)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return ErrOtherClub
+	}
+	return nil
+}
+
+// getClub runs a query selecting one club by clubColumns
+func getClub(db *sql.DB, query string, args ...interface{}) (*Club, error) {
+	club := &Club{}
+	if err := scanClub(db.QueryRow(query, args...), club); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("club not found")
+		}
+		return nil, err
+	}
+	return club, nil
+}
+
+// scanClub scans a row selected with clubColumns into club
+func scanClub(row rowScanner, club *Club) error {
+	return row.Scan(
+		&club.ID, &club.Slug, &club.Name, &club.BrandColor,
+		&club.LogoURL, &club.ContactEmail, &club.CreatedAt,
+	)
+}
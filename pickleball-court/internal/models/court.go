@@ -8,27 +8,110 @@ import (
 )
 
 type Court struct {
-	ID          int64
-	Name        string
-	Description string
-	Status      string
-	CreatedAt   time.Time
+	ID                int64
+	FacilityID        int64 // the facility's default when created without one
+	Name              string
+	Description       string
+	Status            string // derived from maintenance and blackout windows
+	MaxBookingMinutes int
+	AutoConfirm       bool // bookings on this court skip admin approval
+	CreatedAt         time.Time
+
+	// Attributes players can filter on
+	Indoor     bool
+	Surface    string // one of the CourtSurface constants, empty if unknown
+	Lighting   bool   // lit for night play
+	Lines      string // CourtLinesPermanent or CourtLinesTemporary, empty if unknown
+	Accessible bool   // wheelchair accessible
+
+	// Additional fields for joins
+	ClubID       int64 // the club owning the court's facility
+	FacilityName string
+}
+
+// CourtFilter narrows a club's court listing by attribute. Nil and empty
+// fields match every court.
+type CourtFilter struct {
+	ClubID     int64
+	FacilityID int64
+	Indoor     *bool
+	Surface    string
+	Lighting   *bool
+	Lines      string
+	Accessible *bool
 }
 
 const (
-	CourtStatusAvailable = "available"
-	CourtStatusBooked    = "booked"
+	CourtStatusAvailable   = "available"
 	CourtStatusMaintenance = "maintenance"
+	CourtStatusClosed      = "closed"
+
+	// DefaultMaxBookingMinutes is the longest single booking a court accepts
+	// unless configured otherwise
+	DefaultMaxBookingMinutes = 120
+
+	CourtSurfaceConcrete = "concrete"
+	CourtSurfaceAsphalt  = "asphalt"
+	CourtSurfaceAcrylic  = "acrylic" // cushioned hard court
+	CourtSurfaceWood     = "wood"
+	CourtSurfaceModular  = "modular" // interlocking sport tiles
+
+	CourtLinesPermanent = "permanent"
+	CourtLinesTemporary = "temporary" // taped or painted over another sport's lines
 )
 
-// CreateCourt creates a new court in the database
+var ErrInvalidCourtAttributes = errors.New("unknown court surface or line type")
+
+// courtColumns derives a court's status from the maintenance and blackout
+// windows covering it right now. The stored status column is no longer read.
+const courtColumns = `id, name, description,
+	CASE
+		WHEN EXISTS (
+			SELECT 1 FROM court_blackouts bo
+			WHERE bo.court_id = courts.id AND bo.kind = 'maintenance'
+			AND julianday(bo.start_time) <= julianday('now') AND julianday(bo.end_time) > julianday('now')
+		) THEN 'maintenance'
+		WHEN EXISTS (
+			SELECT 1 FROM court_blackouts bo
+			WHERE (bo.court_id = courts.id OR (bo.court_id IS NULL
+				AND bo.club_id = (SELECT f.club_id FROM facilities f WHERE f.id = courts.facility_id)))
+			AND julianday(bo.start_time) <= julianday('now') AND julianday(bo.end_time) > julianday('now')
+		) THEN 'closed'
+		ELSE 'available'
+	END AS status,
+	max_booking_minutes, auto_confirm, created_at,
+	indoor, surface, lighting, lines, accessible,
+	COALESCE(facility_id, 0), COALESCE((SELECT f.name FROM facilities f WHERE f.id = courts.facility_id), ''),
+	COALESCE((SELECT f.club_id FROM facilities f WHERE f.id = courts.facility_id), 0)`
+
+// courtsInClub restricts a query on courts to one club's facilities
+const courtsInClub = `facility_id IN (SELECT id FROM facilities WHERE club_id = ?)`
+
+// CreateCourt creates a new court in the club's facility, or in the club's
+// first facility when none is given
 func CreateCourt(db *sql.DB, court *Court) error {
+	if court.MaxBookingMinutes <= 0 {
+		court.MaxBookingMinutes = DefaultMaxBookingMinutes
+	}
+	if err := validateCourtAttributes(court); err != nil {
+		return err
+	}
+	if err := checkFacilityClub(db, court.FacilityID, court.ClubID); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO courts (name, description, status, created_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO courts (
+			name, description, status, max_booking_minutes, auto_confirm,
+			indoor, surface, lighting, lines, accessible, facility_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, (SELECT MIN(id) FROM facilities WHERE club_id = ?)), CURRENT_TIMESTAMP)
 	`
 
-	result, err := db.Exec(query, court.Name, court.Description, court.Status)
+	result, err := db.Exec(query,
+		court.Name, court.Description, CourtStatusAvailable, court.MaxBookingMinutes, court.AutoConfirm,
+		court.Indoor, court.Surface, court.Lighting, court.Lines, court.Accessible,
+		nullInt64(court.FacilityID), court.ClubID,
+	)
 	if err != nil {
 		return err
 	}
@@ -38,15 +121,16 @@ func CreateCourt(db *sql.DB, court *Court) error {
 		return err
 	}
 
-	court.ID = id
-	return nil
+	// Reload for the derived status
+	return scanCourt(db.QueryRow(`SELECT `+courtColumns+` FROM courts WHERE id = ?`, id), court)
 }
 
-// GetCourtByID retrieves a court by its ID
-func GetCourtByID(db *sql.DB, id int64) (*Court, error) {
+// GetCourtByID retrieves a club's court by its ID
+func GetCourtByID(db *sql.DB, clubID, id int64) (*Court, error) {
 	court := &Court{}
-	query := `SELECT id, name, description, status, created_at FROM courts WHERE id = ?`
-	err := db.QueryRow(query, id).Scan(&court.ID, &court.Name, &court.Description, &court.Status, &court.CreatedAt)
+	query := `SELECT ` + courtColumns + ` FROM courts WHERE id = ? AND ` +
+		inClub(`(SELECT club_id FROM facilities WHERE id = courts.facility_id)`)
+	err := scanCourt(db.QueryRow(query, id, clubID, clubID), court)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("court not found")
@@ -56,31 +140,31 @@ func GetCourtByID(db *sql.DB, id int64) (*Court, error) {
 	return court, nil
 }
 
-// GetAllCourts retrieves all courts from the database
-func GetAllCourts(db *sql.DB) ([]*Court, error) {
-	query := `SELECT id, name, description, status, created_at FROM courts`
-	rows, err := db.Query(query)
+// GetAllCourts retrieves all courts of a club
+func GetAllCourts(db *sql.DB, clubID int64) ([]*Court, error) {
+	query := `SELECT ` + courtColumns + ` FROM courts WHERE ` + courtsInClub
+	return executeCourtQuery(db, query, clubID)
+}
+
+// GetCourts retrieves the courts of the filter's club matching the filter
+func GetCourts(db *sql.DB, filter CourtFilter) ([]*Court, error) {
+	courts, err := GetAllCourts(db, filter.ClubID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return filter.Apply(courts), nil
+}
 
-	var courts []*Court
-	for rows.Next() {
-		court := &Court{}
-		err := rows.Scan(&court.ID, &court.Name, &court.Description, &court.Status, &court.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		courts = append(courts, court)
-	}
-	return courts, nil
+// GetAvailableCourts retrieves a club's courts not under maintenance or
+// blacked out right now
+func GetAvailableCourts(db *sql.DB, clubID int64) ([]*Court, error) {
+	query := `SELECT * FROM (SELECT ` + courtColumns + ` FROM courts WHERE ` + courtsInClub + `) WHERE status = ?`
+	return executeCourtQuery(db, query, clubID, CourtStatusAvailable)
 }
 
-// GetAvailableCourts retrieves all available courts
-func GetAvailableCourts(db *sql.DB) ([]*Court, error) {
-	query := `SELECT id, name, description, status, created_at FROM courts WHERE status = ?`
-	rows, err := db.Query(query, CourtStatusAvailable)
+// Helper function to execute court queries
+func executeCourtQuery(db *sql.DB, query string, args ...interface{}) ([]*Court, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,8 +173,7 @@ func GetAvailableCourts(db *sql.DB) ([]*Court, error) {
 	var courts []*Court
 	for rows.Next() {
 		court := &Court{}
-		err := rows.Scan(&court.ID, &court.Name, &court.Description, &court.Status, &court.CreatedAt)
-		if err != nil {
+		if err := scanCourt(rows, court); err != nil {
 			return nil, err
 		}
 		courts = append(courts, court)
@@ -98,22 +181,106 @@ func GetAvailableCourts(db *sql.DB) ([]*Court, error) {
 	return courts, nil
 }
 
-// UpdateCourt updates court information
+// scanCourt scans a row selected with courtColumns into court
+func scanCourt(row rowScanner, court *Court) error {
+	return row.Scan(
+		&court.ID, &court.Name, &court.Description, &court.Status,
+		&court.MaxBookingMinutes, &court.AutoConfirm, &court.CreatedAt,
+		&court.Indoor, &court.Surface, &court.Lighting, &court.Lines, &court.Accessible,
+		&court.FacilityID, &court.FacilityName, &court.ClubID,
+	)
+}
+
+// validateCourtAttributes checks a court's surface and line type against
+// the known values
+func validateCourtAttributes(court *Court) error {
+	switch court.Surface {
+	case "", CourtSurfaceConcrete, CourtSurfaceAsphalt, CourtSurfaceAcrylic, CourtSurfaceWood, CourtSurfaceModular:
+	default:
+		return ErrInvalidCourtAttributes
+	}
+	switch court.Lines {
+	case "", CourtLinesPermanent, CourtLinesTemporary:
+	default:
+		return ErrInvalidCourtAttributes
+	}
+	return nil
+}
+
+// Matches reports whether a court has every attribute the filter asks for
+func (f CourtFilter) Matches(court *Court) bool {
+	if court.ClubID != f.ClubID {
+		return false
+	}
+	if f.FacilityID != 0 && court.FacilityID != f.FacilityID {
+		return false
+	}
+	if f.Indoor != nil && court.Indoor != *f.Indoor {
+		return false
+	}
+	if f.Surface != "" && court.Surface != f.Surface {
+		return false
+	}
+	if f.Lighting != nil && court.Lighting != *f.Lighting {
+		return false
+	}
+	if f.Lines != "" && court.Lines != f.Lines {
+		return false
+	}
+	if f.Accessible != nil && court.Accessible != *f.Accessible {
+		return false
+	}
+	return true
+}
+
+// Apply returns the courts that match the filter
+func (f CourtFilter) Apply(courts []*Court) []*Court {
+	var matched []*Court
+	for _, court := range courts {
+		if f.Matches(court) {
+			matched = append(matched, court)
+		}
+	}
+	return matched
+}
+
+// UpdateCourt updates court information. A zero FacilityID keeps the
+// current facility, and the court can only move between facilities of its
+// club. The status cannot be set directly; schedule maintenance instead.
 func UpdateCourt(db *sql.DB, court *Court) error {
+	if court.MaxBookingMinutes <= 0 {
+		court.MaxBookingMinutes = DefaultMaxBookingMinutes
+	}
+	if err := validateCourtAttributes(court); err != nil {
+		return err
+	}
+
+	existing, err := GetCourtByID(db, AnyClub, court.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkFacilityClub(db, court.FacilityID, existing.ClubID); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE courts 
-		SET name = ?, description = ?, status = ?
+		SET name = ?, description = ?, max_booking_minutes = ?, auto_confirm = ?,
+			indoor = ?, surface = ?, lighting = ?, lines = ?, accessible = ?,
+			facility_id = COALESCE(?, facility_id)
 		WHERE id = ?
 	`
-	_, err := db.Exec(query, court.Name, court.Description, court.Status, court.ID)
-	return err
-}
+	_, err = db.Exec(query,
+		court.Name, court.Description, court.MaxBookingMinutes, court.AutoConfirm,
+		court.Indoor, court.Surface, court.Lighting, court.Lines, court.Accessible,
+		nullInt64(court.FacilityID), court.ID,
+	)
+	if err != nil {
+		return err
+	}
 
-// UpdateCourtStatus updates only the court's status
-func UpdateCourtStatus(db *sql.DB, courtID int64, status string) error {
-	query := `UPDATE courts SET status = ? WHERE id = ?`
-	_, err := db.Exec(query, status, courtID)
-	return err
+	// Reload for the derived status
+	return scanCourt(db.QueryRow(`SELECT `+courtColumns+` FROM courts WHERE id = ?`, court.ID), court)
 }
 
 // DeleteCourt deletes a court from the database
@@ -153,25 +320,60 @@ func DeleteCourt(db *sql.DB, id interface{}) error {
 	return err
 }
 
-// IsCourtAvailable checks if a court is available for booking in a given time slot
+// IsCourtAvailable checks if a court is available for booking in a given time
+// slot. Unexpired slot holds and waitlist holds count as occupied.
 func IsCourtAvailable(db *sql.DB, courtID int64, startTime, endTime time.Time) (bool, error) {
+	return isCourtAvailable(db, courtID, startTime, endTime)
+}
+
+// getCourtMaxBookingDuration returns the longest booking a court accepts
+func getCourtMaxBookingDuration(q Querier, courtID int64) (time.Duration, error) {
+	var minutes int
+	err := q.QueryRow(`SELECT max_booking_minutes FROM courts WHERE id = ?`, courtID).Scan(&minutes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("court not found")
+		}
+		return 0, err
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// isCourtAvailable runs the availability check on any Querier
+func isCourtAvailable(q Querier, courtID int64, startTime, endTime time.Time) (bool, error) {
+	return isCourtAvailableExcept(q, courtID, startTime, endTime, 0)
+}
+
+// isCourtAvailableExcept checks availability ignoring one booking, so that a
+// booking can be moved to a range overlapping its current one
+func isCourtAvailableExcept(q Querier, courtID int64, startTime, endTime time.Time, excludeBookingID int64) (bool, error) {
 	query := `
-		SELECT COUNT(*) FROM bookings 
-		WHERE court_id = ? 
-		AND status != 'cancelled'
-		AND (
-			(start_time <= ? AND end_time > ?) OR
-			(start_time < ? AND end_time >= ?) OR
-			(start_time >= ? AND end_time <= ?)
-		)
+		SELECT
+			(SELECT COUNT(*) FROM bookings 
+			WHERE court_id = ? AND id != ?
+			AND status NOT IN ('cancelled', 'rejected', 'no_show')
+			AND julianday(start_time) < julianday(?)
+			AND julianday(end_time) > julianday(?))
+		+
+			(SELECT COUNT(*) FROM slot_holds
+			WHERE court_id = ?
+			AND julianday(expires_at) > julianday(?)
+			AND julianday(start_time) < julianday(?)
+			AND julianday(end_time) > julianday(?))
+		+
+			(SELECT COUNT(*) FROM waitlist_entries
+			WHERE offered_court_id = ? AND status = 'offered'
+			AND julianday(hold_expires_at) > julianday(?)
+			AND julianday(start_time) < julianday(?)
+			AND julianday(end_time) > julianday(?))
 	`
+	now := time.Now().UTC()
 	var count int
-	err := db.QueryRow(
+	err := q.QueryRow(
 		query,
-		courtID,
-		startTime, startTime,
-		endTime, endTime,
-		startTime, endTime,
+		courtID, excludeBookingID, endTime.UTC(), startTime.UTC(),
+		courtID, now, endTime.UTC(), startTime.UTC(),
+		courtID, now, endTime.UTC(), startTime.UTC(),
 	).Scan(&count)
 	if err != nil {
 		return false, err
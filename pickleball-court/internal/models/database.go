@@ -2,16 +2,97 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+
+	"pickleball-court/config"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Querier is implemented by both *sql.DB and *sql.Tx so that model helpers
+// can run inside or outside a transaction
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Transactions are opened with BEGIN IMMEDIATE so that concurrent writers
+// queue on the database lock instead of racing between check and insert
+const connectionOptions = "?_txlock=immediate&_busy_timeout=5000"
+
+// InitDB opens the database at the configured path
 func InitDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./pickleball.db")
+	return OpenDB(config.Get().Database.Path)
+}
+
+// OpenDB opens the SQLite database at path, creating it and bringing its
+// schema up to date
+func OpenDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+connectionOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create clubs table. Each club is a tenant with its own users,
+	// facilities and branding, reached by subdomain or /c/<slug>.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS clubs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT UNIQUE NOT NULL,
+			name TEXT NOT NULL,
+			brand_color TEXT NOT NULL DEFAULT '',
+			logo_url TEXT NOT NULL DEFAULT '',
+			contact_email TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create users table
+	// Databases from before clubs become the first club
+	_, err = db.Exec(`
+		INSERT INTO clubs (slug, name, created_at)
+		SELECT 'main', 'Main Club', CURRENT_TIMESTAMP WHERE NOT EXISTS (SELECT 1 FROM clubs)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create facilities table. NULL hours and limits fall back to the
+	// configured values.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS facilities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			club_id INTEGER REFERENCES clubs(id),
+			name TEXT UNIQUE NOT NULL,
+			address TEXT NOT NULL DEFAULT '',
+			timezone TEXT NOT NULL DEFAULT '',
+			opening_hour INTEGER,
+			closing_hour INTEGER,
+			max_days_ahead INTEGER,
+			min_hours_advance INTEGER,
+			max_hours_per_week INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "facilities", "club_id", "INTEGER REFERENCES clubs(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create users table. facility_id scopes admins and staff to one
+	// facility; NULL means club-wide. club_id is NULL only for super admins.
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -19,6 +100,10 @@ func InitDB() (*sql.DB, error) {
 			password TEXT NOT NULL,
 			email TEXT UNIQUE NOT NULL,
 			role TEXT NOT NULL,
+			trusted INTEGER NOT NULL DEFAULT 0,
+			skill_level REAL NOT NULL DEFAULT 0,
+			club_id INTEGER REFERENCES clubs(id),
+			facility_id INTEGER REFERENCES facilities(id),
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -26,6 +111,31 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
+	err = addColumnIfMissing(db, "users", "trusted", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "users", "skill_level", "REAL NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "users", "facility_id", "INTEGER REFERENCES facilities(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "users", "club_id", "INTEGER REFERENCES clubs(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE users SET club_id = (SELECT MIN(id) FROM clubs) WHERE club_id IS NULL AND role != ?`, RoleSuperAdmin)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create courts table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS courts (
@@ -33,6 +143,14 @@ func InitDB() (*sql.DB, error) {
 			name TEXT NOT NULL,
 			description TEXT,
 			status TEXT NOT NULL,
+			max_booking_minutes INTEGER NOT NULL DEFAULT 120,
+			auto_confirm INTEGER NOT NULL DEFAULT 0,
+			indoor INTEGER NOT NULL DEFAULT 0,
+			surface TEXT NOT NULL DEFAULT '',
+			lighting INTEGER NOT NULL DEFAULT 0,
+			lines TEXT NOT NULL DEFAULT '',
+			accessible INTEGER NOT NULL DEFAULT 0,
+			facility_id INTEGER REFERENCES facilities(id),
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -40,6 +158,67 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
+	err = addColumnIfMissing(db, "courts", "max_booking_minutes", "INTEGER NOT NULL DEFAULT 120")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "auto_confirm", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	// Court attributes players can filter on
+	err = addColumnIfMissing(db, "courts", "indoor", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "surface", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "lighting", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "lines", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "accessible", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "courts", "facility_id", "INTEGER REFERENCES facilities(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	// Every court belongs to a facility. Databases from before facilities
+	// get a single one owning the existing courts.
+	_, err = db.Exec(`
+		INSERT INTO facilities (name, created_at)
+		SELECT 'Main', CURRENT_TIMESTAMP WHERE NOT EXISTS (SELECT 1 FROM facilities)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE facilities SET club_id = (SELECT MIN(id) FROM clubs) WHERE club_id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE courts SET facility_id = (SELECT MIN(id) FROM facilities) WHERE facility_id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create bookings table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS bookings (
@@ -50,8 +229,394 @@ func InitDB() (*sql.DB, error) {
 			end_time DATETIME NOT NULL,
 			status TEXT NOT NULL,
 			booking_type TEXT NOT NULL,
+			series_id INTEGER,
+			checked_in_at DATETIME,
+			checked_in_by INTEGER,
+			reminder_sent_at DATETIME,
+			sequence INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME,
+			FOREIGN KEY (court_id) REFERENCES courts(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (series_id) REFERENCES booking_series(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "series_id", "INTEGER REFERENCES booking_series(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "checked_in_at", "DATETIME")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "checked_in_by", "INTEGER REFERENCES users(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "reminder_sent_at", "DATETIME")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "sequence", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "bookings", "updated_at", "DATETIME")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_series table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_series (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			court_id INTEGER NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			frequency TEXT NOT NULL,
+			until_date DATETIME,
+			occurrences INTEGER,
+			status TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (court_id) REFERENCES courts(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject overlapping bookings on the same court at the database level
+	_, err = db.Exec(`
+		DROP TRIGGER IF EXISTS bookings_no_overlap_insert;
+		CREATE TRIGGER bookings_no_overlap_insert
+		BEFORE INSERT ON bookings
+		WHEN NEW.status NOT IN ('cancelled', 'rejected', 'no_show')
+		BEGIN
+			SELECT RAISE(ABORT, 'booking overlaps an existing booking')
+			WHERE EXISTS (
+				SELECT 1 FROM bookings
+				WHERE court_id = NEW.court_id
+				AND status NOT IN ('cancelled', 'rejected', 'no_show')
+				AND julianday(start_time) < julianday(NEW.end_time)
+				AND julianday(end_time) > julianday(NEW.start_time)
+			);
+		END;
+
+		DROP TRIGGER IF EXISTS bookings_no_overlap_update;
+		CREATE TRIGGER bookings_no_overlap_update
+		BEFORE UPDATE OF court_id, start_time, end_time, status ON bookings
+		WHEN NEW.status NOT IN ('cancelled', 'rejected', 'no_show')
+		BEGIN
+			SELECT RAISE(ABORT, 'booking overlaps an existing booking')
+			WHERE EXISTS (
+				SELECT 1 FROM bookings
+				WHERE court_id = NEW.court_id
+				AND id != NEW.id
+				AND status NOT IN ('cancelled', 'rejected', 'no_show')
+				AND julianday(start_time) < julianday(NEW.end_time)
+				AND julianday(end_time) > julianday(NEW.start_time)
+			);
+		END;
+
+		-- Calendar feeds send the sequence so that apps pick up each change
+		DROP TRIGGER IF EXISTS bookings_track_changes;
+		CREATE TRIGGER bookings_track_changes
+		AFTER UPDATE OF court_id, start_time, end_time, status, user_id ON bookings
+		BEGIN
+			UPDATE bookings SET sequence = OLD.sequence + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.id;
+		END;
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_status_history table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_status_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL,
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			changed_by INTEGER NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			changed_at DATETIME NOT NULL,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id),
+			FOREIGN KEY (changed_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_cancellations table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_cancellations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			cancelled_by INTEGER NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			late INTEGER NOT NULL DEFAULT 0,
+			waived INTEGER NOT NULL DEFAULT 0,
+			cancelled_at DATETIME NOT NULL,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (cancelled_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Penalty and no-show policies were once a single row shared by every
+	// club. Set the old tables aside so each club can start from a copy.
+	legacyPenaltyPolicy, err := setAsideUnkeyedPolicy(db, "penalty_policy")
+	if err != nil {
+		return nil, err
+	}
+	legacyNoShowPolicy, err := setAsideUnkeyedPolicy(db, "no_show_policy")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create penalty_policy table, holding each club's policy
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS penalty_policy (
+			club_id INTEGER PRIMARY KEY REFERENCES clubs(id),
+			strike_limit INTEGER NOT NULL,
+			strike_window_days INTEGER NOT NULL,
+			penalty TEXT NOT NULL,
+			suspension_days INTEGER NOT NULL DEFAULT 0,
+			forfeit_credits INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create no_show_policy table, holding each club's default policy and
+	// the policies of facilities that set their own
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS no_show_policy (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			club_id INTEGER NOT NULL REFERENCES clubs(id),
+			facility_id INTEGER REFERENCES facilities(id),
+			strike_limit INTEGER NOT NULL,
+			strike_window_days INTEGER NOT NULL,
+			restriction_days INTEGER NOT NULL,
+			max_hours_per_week INTEGER NOT NULL DEFAULT 0,
+			max_days_ahead INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "no_show_policy", "facility_id", "INTEGER REFERENCES facilities(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	// One policy per facility, and one club default with no facility
+	_, err = db.Exec(`DROP INDEX IF EXISTS idx_no_show_policy_club`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_no_show_policy_scope
+		ON no_show_policy(club_id, COALESCE(facility_id, 0))
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	if legacyPenaltyPolicy {
+		err = copyPolicyToClubs(db, "penalty_policy",
+			"strike_limit, strike_window_days, penalty, suspension_days, forfeit_credits, updated_at")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if legacyNoShowPolicy {
+		err = copyPolicyToClubs(db, "no_show_policy",
+			"strike_limit, strike_window_days, restriction_days, max_hours_per_week, max_days_ahead, updated_at")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create user_penalties table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_penalties (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			penalty TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			credits INTEGER NOT NULL DEFAULT 0,
+			expires_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_participants table. user_id is NULL for guests without
+	// an account.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_participants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL,
+			user_id INTEGER,
+			guest_name TEXT NOT NULL DEFAULT '',
+			guest_email TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			invited_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			responded_at DATETIME,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (invited_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_audit table. Each row records a reschedule or transfer
+	// step with the values before and after the change.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			changed_by INTEGER NOT NULL,
+			old_court_id INTEGER,
+			new_court_id INTEGER,
+			old_start_time DATETIME,
+			new_start_time DATETIME,
+			old_end_time DATETIME,
+			new_end_time DATETIME,
+			old_user_id INTEGER,
+			new_user_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create booking_transfers table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS booking_transfers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL,
+			from_user_id INTEGER NOT NULL,
+			to_user_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			responded_at DATETIME,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id),
+			FOREIGN KEY (from_user_id) REFERENCES users(id),
+			FOREIGN KEY (to_user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create slot_holds table. A hold reserves a slot for a short time while
+	// the player confirms the booking.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS slot_holds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			court_id INTEGER NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (court_id) REFERENCES courts(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create waitlist_entries table. court_id is NULL for players happy to
+	// take any court; offered_court_id is the court being held for them.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS waitlist_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			court_id INTEGER,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			status TEXT NOT NULL,
+			offered_court_id INTEGER,
+			offered_at DATETIME,
+			hold_expires_at DATETIME,
+			booking_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
 			FOREIGN KEY (court_id) REFERENCES courts(id),
+			FOREIGN KEY (offered_court_id) REFERENCES courts(id),
+			FOREIGN KEY (booking_id) REFERENCES bookings(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create open_play_sessions table. Each session reserves its court
+	// through a booking of type open_play.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS open_play_sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booking_id INTEGER NOT NULL UNIQUE,
+			created_by INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			capacity INTEGER NOT NULL,
+			min_skill REAL NOT NULL DEFAULT 0,
+			max_skill REAL NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (booking_id) REFERENCES bookings(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create open_play_signups table. queued_at orders the rotation queue.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS open_play_signups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			on_court INTEGER NOT NULL DEFAULT 0,
+			queued_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (session_id) REFERENCES open_play_sessions(id),
 			FOREIGN KEY (user_id) REFERENCES users(id)
 		)
 	`)
@@ -59,6 +624,89 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
+	// Create court_hours table with each court's weekly opening hours.
+	// Weekdays without a row use the configured opening hours.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS court_hours (
+			court_id INTEGER NOT NULL,
+			weekday INTEGER NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+			opens_at TEXT NOT NULL,
+			closes_at TEXT NOT NULL,
+			closed INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (court_id, weekday),
+			FOREIGN KEY (court_id) REFERENCES courts(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create court_hours_overrides table for holidays and special events.
+	// court_id is NULL for overrides that apply to every court in the club.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS court_hours_overrides (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			club_id INTEGER REFERENCES clubs(id),
+			court_id INTEGER,
+			date TEXT NOT NULL,
+			opens_at TEXT NOT NULL DEFAULT '',
+			closes_at TEXT NOT NULL DEFAULT '',
+			closed INTEGER NOT NULL DEFAULT 0,
+			reason TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (court_id) REFERENCES courts(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "court_hours_overrides", "club_id", "INTEGER REFERENCES clubs(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE court_hours_overrides SET club_id = (SELECT MIN(id) FROM clubs) WHERE club_id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create court_blackouts table. court_id is NULL for blackouts that
+	// close every court in the club. kind is 'closure' or 'maintenance'.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS court_blackouts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			club_id INTEGER REFERENCES clubs(id),
+			court_id INTEGER,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			reason TEXT NOT NULL,
+			kind TEXT NOT NULL DEFAULT 'closure',
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (court_id) REFERENCES courts(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "court_blackouts", "kind", "TEXT NOT NULL DEFAULT 'closure'")
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "court_blackouts", "club_id", "INTEGER REFERENCES clubs(id)")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE court_blackouts SET club_id = (SELECT MIN(id) FROM clubs) WHERE club_id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create training_sessions table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS training_sessions (
@@ -79,5 +727,253 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
+	// Create training_session_participants table for enrollments
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS training_session_participants (
+			session_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			reminder_sent_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, user_id),
+			FOREIGN KEY (session_id) REFERENCES training_sessions(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addColumnIfMissing(db, "training_session_participants", "reminder_sent_at", "DATETIME")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create calendar_tokens table. The token is the secret in a user's
+	// personal iCal feed URL.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_tokens (
+			user_id INTEGER PRIMARY KEY,
+			token TEXT UNIQUE NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create email_queue table, the outbox of emails waiting to be sent.
+	// status is 'pending', 'sent' or 'failed'.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_queue (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipient TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			body TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			sent_at DATETIME
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create password_resets table. Only a hash of each emailed token is
+	// kept.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS password_resets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create notifications table, each user's in-app inbox
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			title TEXT NOT NULL,
+			body TEXT NOT NULL,
+			read_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create notification_preferences table. A missing row means the user
+	// gets that kind of notification both in the inbox and by email.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			inbox BOOLEAN NOT NULL DEFAULT 1,
+			email BOOLEAN NOT NULL DEFAULT 1,
+			PRIMARY KEY (user_id, kind),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create jobs table for the background scheduler. Each row is a
+	// recurring task; run_at is when it is next due, so schedules survive
+	// restarts. status is 'pending', 'running' or 'failed'.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			interval_seconds INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			run_at DATETIME NOT NULL,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			started_at DATETIME,
+			last_run_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create scheduler_leases table. The instance holding an unexpired
+	// lease is the only one that runs jobs.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scheduler_leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create webhooks table. event_types is a comma-separated list of the
+	// events the endpoint is sent.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			club_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			event_types TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT 1,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (club_id) REFERENCES clubs(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create webhook_deliveries table, the outbox and delivery log of
+	// webhook events. status is 'pending', 'delivered' or 'failed'.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			delivered_at DATETIME,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// setAsideUnkeyedPolicy renames a policy table from before policies were
+// kept per club to <table>_legacy, reporting whether there was one
+func setAsideUnkeyedPolicy(db *sql.DB, table string) (bool, error) {
+	columns, err := tableColumns(db, table)
+	if err != nil || len(columns) == 0 || columns["club_id"] {
+		return false, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s_legacy", table, table))
+	return err == nil, err
+}
+
+// copyPolicyToClubs gives every club a copy of the shared policy row set
+// aside by setAsideUnkeyedPolicy, then drops the old table
+func copyPolicyToClubs(db *sql.DB, table, columns string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s (club_id, %s)
+		SELECT clubs.id, %s FROM clubs, %s_legacy
+	`, table, columns, columns, table))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE %s_legacy", table))
+	return err
+}
+
+// addColumnIfMissing adds a column to a table created by an older version of
+// the schema. SQLite has no ADD COLUMN IF NOT EXISTS, so check table_info first.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil || columns[column] {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// tableColumns returns the names of a table's columns, none if the table
+// does not exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
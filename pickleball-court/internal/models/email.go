@@ -0,0 +1,206 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// QueuedEmail is an email in the outbox. Emails are queued by the request
+// that triggers them and delivered in the background, retrying failures
+// until MaxEmailAttempts is reached.
+type QueuedEmail struct {
+	ID            int64
+	Recipient     string
+	Subject       string
+	Body          string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        time.Time // zero until sent
+}
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed" // gave up after MaxEmailAttempts
+)
+
+// MaxEmailAttempts is how many times delivery of an email is tried
+const MaxEmailAttempts = 5
+
+const emailColumns = `id, recipient, subject, body, status, attempts, last_error,
+	next_attempt_at, created_at, sent_at`
+
+// QueueEmail adds an email to the outbox, due straight away
+func QueueEmail(q Querier, email *QueuedEmail) error {
+	email.Status = EmailStatusPending
+	email.NextAttemptAt = time.Now().UTC()
+
+	result, err := q.Exec(`
+		INSERT INTO email_queue (recipient, subject, body, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, email.Recipient, email.Subject, email.Body, email.Status, email.NextAttemptAt)
+	if err != nil {
+		return err
+	}
+
+	email.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetDueEmails retrieves up to limit pending emails whose next attempt is
+// due, oldest first
+func GetDueEmails(db *sql.DB, now time.Time, limit int) ([]*QueuedEmail, error) {
+	rows, err := db.Query(`
+		SELECT `+emailColumns+`
+		FROM email_queue
+		WHERE status = ? AND julianday(next_attempt_at) <= julianday(?)
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`, EmailStatusPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*QueuedEmail
+	for rows.Next() {
+		email := &QueuedEmail{}
+		var sentAt sql.NullTime
+		err := rows.Scan(
+			&email.ID, &email.Recipient, &email.Subject, &email.Body, &email.Status,
+			&email.Attempts, &email.LastError, &email.NextAttemptAt, &email.CreatedAt, &sentAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		email.SentAt = sentAt.Time
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// ClaimEmail reserves a due email for one sender by moving its next
+// attempt to until. It reports false if the email was sent, given up on or
+// claimed by another sender since it was loaded. A sender that stops
+// before marking the email leaves it to be tried again at until.
+func ClaimEmail(db *sql.DB, email *QueuedEmail, now, until time.Time) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE email_queue SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND julianday(next_attempt_at) <= julianday(?)
+	`, until.UTC(), email.ID, EmailStatusPending, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// MarkEmailSent records that an email was delivered
+func MarkEmailSent(db *sql.DB, emailID int64, now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE email_queue SET status = ?, attempts = attempts + 1, last_error = '', sent_at = ?
+		WHERE id = ?
+	`, EmailStatusSent, now.UTC(), emailID)
+	return err
+}
+
+// MarkEmailFailed records a failed delivery attempt. The email is tried
+// again at retryAt, or given up on once it has used MaxEmailAttempts.
+func MarkEmailFailed(db *sql.DB, email *QueuedEmail, sendErr error, retryAt time.Time) error {
+	email.Attempts++
+	email.LastError = sendErr.Error()
+	email.NextAttemptAt = retryAt.UTC()
+	if email.Attempts >= MaxEmailAttempts {
+		email.Status = EmailStatusFailed
+	}
+
+	_, err := db.Exec(`
+		UPDATE email_queue SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, email.Status, email.Attempts, email.LastError, email.NextAttemptAt, email.ID)
+	return err
+}
+
+// GetBookingsDueReminder retrieves active bookings starting between now and
+// now plus ahead that have not had a reminder yet
+func GetBookingsDueReminder(db *sql.DB, now time.Time, ahead time.Duration) ([]*Booking, error) {
+	return executeBookingQuery(db, bookingSelect+`
+		WHERE b.status IN (?, ?) AND b.reminder_sent_at IS NULL
+		AND julianday(b.start_time) > julianday(?) AND julianday(b.start_time) <= julianday(?)
+		ORDER BY b.start_time ASC
+	`, BookingStatusPending, BookingStatusConfirmed, now.UTC(), now.Add(ahead).UTC())
+}
+
+// MarkReminderSent records that a booking's reminder has been queued
+func MarkReminderSent(q Querier, bookingID int64, now time.Time) error {
+	_, err := q.Exec(`UPDATE bookings SET reminder_sent_at = ? WHERE id = ?`, now.UTC(), bookingID)
+	return err
+}
+
+// TrainingEnrollment is a player's place in a training session
+type TrainingEnrollment struct {
+	SessionID int64
+	UserID    int64
+}
+
+// GetTrainingEnrollmentsDueReminder retrieves enrollments in training
+// sessions starting between now and now plus ahead that have not had a
+// reminder yet
+func GetTrainingEnrollmentsDueReminder(db *sql.DB, now time.Time, ahead time.Duration) ([]*TrainingEnrollment, error) {
+	rows, err := db.Query(`
+		SELECT p.session_id, p.user_id
+		FROM training_session_participants p
+		JOIN training_sessions t ON p.session_id = t.id
+		WHERE p.reminder_sent_at IS NULL
+		AND julianday(t.start_time) > julianday(?) AND julianday(t.start_time) <= julianday(?)
+		ORDER BY t.start_time ASC
+	`, now.UTC(), now.Add(ahead).UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var enrollments []*TrainingEnrollment
+	for rows.Next() {
+		enrollment := &TrainingEnrollment{}
+		if err := rows.Scan(&enrollment.SessionID, &enrollment.UserID); err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, rows.Err()
+}
+
+// MarkTrainingReminderSent records that an enrollment's reminder has been
+// queued
+func MarkTrainingReminderSent(q Querier, sessionID, userID int64, now time.Time) error {
+	_, err := q.Exec(`
+		UPDATE training_session_participants SET reminder_sent_at = ?
+		WHERE session_id = ? AND user_id = ?
+	`, now.UTC(), sessionID, userID)
+	return err
+}
+
+// PurgeOldEmails deletes emails sent or given up on before cutoff, and
+// password reset tokens that expired before it, returning how many rows
+// went
+func PurgeOldEmails(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM email_queue
+		WHERE status != ? AND julianday(created_at) < julianday(?)
+	`, EmailStatusPending, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	emails, _ := result.RowsAffected()
+
+	result, err = db.Exec(`DELETE FROM password_resets WHERE julianday(expires_at) < julianday(?)`, cutoff.UTC())
+	if err != nil {
+		return emails, err
+	}
+	resets, _ := result.RowsAffected()
+	return emails + resets, nil
+}
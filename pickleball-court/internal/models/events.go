@@ -0,0 +1,70 @@
+package models
+
+import (
+	"pickleball-court/internal/events"
+	"time"
+)
+
+// recordBookingEvent returns the event announcing a change to a booking
+// and queues the webhook deliveries for it. Call it with the transaction
+// making the change, after the change, so that deliveries are queued if and
+// only if the change commits, and pass the event to publish once it has.
+func recordBookingEvent(q Querier, eventType string, bookingID int64, from *events.Slot) (events.Event, error) {
+	booking, err := getBooking(q, bookingID)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	var fromSlot *WebhookSlot
+	if from != nil {
+		fromSlot = &WebhookSlot{CourtID: from.CourtID, StartTime: from.StartTime, EndTime: from.EndTime}
+	}
+	if err := queueBookingWebhooks(q, booking, eventType, fromSlot, time.Now()); err != nil {
+		return events.Event{}, err
+	}
+
+	return events.Event{
+		Type:      eventType,
+		ClubID:    booking.ClubID,
+		BookingID: booking.ID,
+		Status:    booking.Status,
+		Slot:      events.Slot{CourtID: booking.CourtID, StartTime: booking.StartTime, EndTime: booking.EndTime},
+		From:      from,
+	}, nil
+}
+
+// publish announces changes to the slots of committed bookings to
+// availability subscribers
+func publish(recorded ...events.Event) {
+	for _, e := range recorded {
+		events.Publish(e)
+	}
+}
+
+// announceSlot publishes a change to a court's slot that is not a booking.
+// A courtID of 0 stands for every court in the club.
+func announceSlot(clubID int64, eventType string, courtID int64, start, end time.Time) {
+	events.Publish(events.Event{
+		Type:   eventType,
+		ClubID: clubID,
+		Slot:   events.Slot{CourtID: courtID, StartTime: start, EndTime: end},
+	})
+}
+
+// announceHold publishes a slot hold being placed or given up
+func announceHold(q Querier, eventType string, hold *SlotHold) {
+	var clubID int64
+	if err := q.QueryRow(courtClubQuery, hold.CourtID).Scan(&clubID); err != nil {
+		return
+	}
+	announceSlot(clubID, eventType, hold.CourtID, hold.StartTime, hold.EndTime)
+}
+
+// statusEvent returns the event for a booking moving to status
+func statusEvent(status string) string {
+	switch status {
+	case BookingStatusCancelled, BookingStatusRejected, BookingStatusNoShow:
+		return events.BookingCancelled
+	}
+	return events.BookingUpdated
+}
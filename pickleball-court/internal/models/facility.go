@@ -0,0 +1,305 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pickleball-court/config"
+)
+
+// Facility is a venue that owns courts. Each facility keeps its own
+// timezone, default opening hours and booking rules; anything left unset
+// falls back to the configured values.
+type Facility struct {
+	ID       int64
+	ClubID   int64
+	Name     string
+	Address  string
+	TimeZone string // IANA name such as "America/Denver", empty for the configured timezone
+
+	// Default opening hours for the facility's courts
+	OpeningHour *int
+	ClosingHour *int
+
+	// Limits on regular bookings. Zero disables a rule, as in the config.
+	MaxDaysAhead    *int
+	MinHoursAdvance *int
+	MaxHoursPerWeek *int
+
+	CreatedAt time.Time
+}
+
+var (
+	ErrInvalidFacility = errors.New("facilities need a name, a known timezone and closing after opening")
+	ErrFacilityInUse   = errors.New("cannot delete a facility that still has courts")
+)
+
+const facilityColumns = `
+	id, COALESCE(club_id, 0), name, address, timezone, opening_hour, closing_hour,
+	max_days_ahead, min_hours_advance, max_hours_per_week, created_at
+`
+
+// Location returns the facility's timezone
+func (f *Facility) Location() *time.Location {
+	if f.TimeZone != "" {
+		if loc, err := time.LoadLocation(f.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return config.Get().GetTimeZone()
+}
+
+// Validate checks the facility's name, timezone and opening hours
+func (f *Facility) Validate() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return ErrInvalidFacility
+	}
+	if f.TimeZone != "" {
+		if _, err := time.LoadLocation(f.TimeZone); err != nil {
+			return ErrInvalidFacility
+		}
+	}
+
+	cfg := config.Get()
+	opens, closes := cfg.Booking.OpeningHour, cfg.Booking.ClosingHour
+	if f.OpeningHour != nil {
+		opens = *f.OpeningHour
+	}
+	if f.ClosingHour != nil {
+		closes = *f.ClosingHour
+	}
+	if opens < 0 || closes > 24 || closes <= opens {
+		return ErrInvalidFacility
+	}
+
+	for _, limit := range []*int{f.MaxDaysAhead, f.MinHoursAdvance, f.MaxHoursPerWeek} {
+		if limit != nil && *limit < 0 {
+			return ErrInvalidFacility
+		}
+	}
+	return nil
+}
+
+// applyTo overlays the facility's timezone, hours and booking rules on a
+// policy. Booking limits only apply to regular bookings; training and open
+// play keep their own.
+func (f *Facility) applyTo(p BookingPolicy, bookingType string) BookingPolicy {
+	p.ClubID = f.ClubID
+	p.FacilityID = f.ID
+	p.Location = f.Location()
+	if f.OpeningHour != nil {
+		p.OpeningHour = *f.OpeningHour
+	}
+	if f.ClosingHour != nil {
+		p.ClosingHour = *f.ClosingHour
+	}
+
+	if bookingType != BookingTypeRegular && bookingType != "" {
+		return p
+	}
+	if f.MaxDaysAhead != nil {
+		p.MaxDaysAhead = *f.MaxDaysAhead
+	}
+	if f.MinHoursAdvance != nil {
+		p.MinHoursAdvance = *f.MinHoursAdvance
+	}
+	if f.MaxHoursPerWeek != nil {
+		p.MaxHoursPerWeek = *f.MaxHoursPerWeek
+	}
+	return p
+}
+
+// GetCourtPolicy returns the policy for a booking type on a court, with the
+// rules of the court's facility applied
+func GetCourtPolicy(q Querier, courtID int64, bookingType string) (BookingPolicy, error) {
+	policy := GetBookingPolicy(config.Get(), bookingType)
+
+	facility, err := scanFacility(q.QueryRow(`
+		SELECT `+facilityColumns+` FROM facilities
+		WHERE id = (SELECT facility_id FROM courts WHERE id = ?)
+	`, courtID))
+	if err == sql.ErrNoRows {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	return facility.applyTo(policy, bookingType), nil
+}
+
+// CreateFacility creates a new facility in a club
+func CreateFacility(db *sql.DB, facility *Facility) error {
+	if err := facility.Validate(); err != nil {
+		return err
+	}
+	if facility.ClubID == 0 {
+		return ErrInvalidFacility
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO facilities (
+			club_id, name, address, timezone, opening_hour, closing_hour,
+			max_days_ahead, min_hours_advance, max_hours_per_week, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`,
+		facility.ClubID, facility.Name, facility.Address, facility.TimeZone, facility.OpeningHour, facility.ClosingHour,
+		facility.MaxDaysAhead, facility.MinHoursAdvance, facility.MaxHoursPerWeek,
+	)
+	if err != nil {
+		return err
+	}
+
+	facility.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetFacilityByID retrieves a club's facility by its ID
+func GetFacilityByID(db *sql.DB, clubID int64, id interface{}) (*Facility, error) {
+	var facilityID int64
+	switch v := id.(type) {
+	case int64:
+		facilityID = v
+	case string:
+		var err error
+		facilityID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	facility, err := scanFacility(db.QueryRow(`SELECT `+facilityColumns+` FROM facilities WHERE id = ? AND `+inClub("club_id"),
+		facilityID, clubID, clubID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("facility not found")
+		}
+		return nil, err
+	}
+	return facility, nil
+}
+
+// GetAllFacilities retrieves every facility of a club by name
+func GetAllFacilities(db *sql.DB, clubID int64) ([]*Facility, error) {
+	rows, err := db.Query(`SELECT `+facilityColumns+` FROM facilities WHERE club_id = ? ORDER BY name ASC`, clubID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facilities []*Facility
+	for rows.Next() {
+		facility, err := scanFacility(rows)
+		if err != nil {
+			return nil, err
+		}
+		facilities = append(facilities, facility)
+	}
+	return facilities, rows.Err()
+}
+
+// UpdateFacility updates a facility's details and rules. A facility's club
+// never changes.
+func UpdateFacility(db *sql.DB, facility *Facility) error {
+	if err := facility.Validate(); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
+		UPDATE facilities
+		SET name = ?, address = ?, timezone = ?, opening_hour = ?, closing_hour = ?,
+			max_days_ahead = ?, min_hours_advance = ?, max_hours_per_week = ?
+		WHERE id = ?
+	`,
+		facility.Name, facility.Address, facility.TimeZone, facility.OpeningHour, facility.ClosingHour,
+		facility.MaxDaysAhead, facility.MinHoursAdvance, facility.MaxHoursPerWeek, facility.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return errors.New("facility not found")
+	}
+	return nil
+}
+
+// DeleteFacility deletes a facility without courts. Its admins become
+// club-wide users with no facility.
+func DeleteFacility(db *sql.DB, facilityID int64) error {
+	var courts int
+	err := db.QueryRow(`SELECT COUNT(*) FROM courts WHERE facility_id = ?`, facilityID).Scan(&courts)
+	if err != nil {
+		return err
+	}
+	if courts > 0 {
+		return ErrFacilityInUse
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Demote rather than promote: a facility admin must not become a
+	// club-wide admin because their venue closed
+	_, err = tx.Exec(`UPDATE users SET role = ?, facility_id = NULL WHERE facility_id = ? AND role = ?`,
+		RolePlayer, facilityID, RoleAdmin)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`UPDATE users SET facility_id = NULL WHERE facility_id = ?`, facilityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM no_show_policy WHERE facility_id = ?`, facilityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM facilities WHERE id = ?`, facilityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		return errors.New("facility not found")
+	}
+	return tx.Commit()
+}
+
+// scanFacility scans a row selected with facilityColumns
+func scanFacility(row rowScanner) (*Facility, error) {
+	facility := &Facility{}
+	var openingHour, closingHour, maxDaysAhead, minHoursAdvance, maxHoursPerWeek sql.NullInt64
+	err := row.Scan(
+		&facility.ID, &facility.ClubID, &facility.Name, &facility.Address, &facility.TimeZone,
+		&openingHour, &closingHour, &maxDaysAhead, &minHoursAdvance, &maxHoursPerWeek,
+		&facility.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	facility.OpeningHour = intPtr(openingHour)
+	facility.ClosingHour = intPtr(closingHour)
+	facility.MaxDaysAhead = intPtr(maxDaysAhead)
+	facility.MinHoursAdvance = intPtr(minHoursAdvance)
+	facility.MaxHoursPerWeek = intPtr(maxHoursPerWeek)
+	return facility, nil
+}
+
+// intPtr converts a nullable column to an optional int
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
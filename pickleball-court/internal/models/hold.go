@@ -0,0 +1,261 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// SlotHold reserves a slot for a short time while a player confirms the
+// booking. Unexpired holds make the slot unavailable to everyone else.
+type SlotHold struct {
+	ID        int64
+	UserID    int64
+	CourtID   int64
+	StartTime time.Time
+	EndTime   time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
+
+	// Additional fields for joins
+	CourtName string
+}
+
+// ErrSlotHoldExpired is returned when confirming a hold that has lapsed
+var ErrSlotHoldExpired = errors.New("the hold on this slot has expired")
+
+// CreateSlotHold holds a slot for the configured hold time after checking it
+// against the booking policy. A player holds one slot at a time, so any
+// earlier hold of theirs is released.
+func CreateSlotHold(db *sql.DB, hold *SlotHold) error {
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	released, err := executeSlotHoldQuery(tx, slotHoldSelect+` WHERE h.user_id = ?`, hold.UserID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM slot_holds WHERE user_id = ?`, hold.UserID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	booking := &Booking{
+		CourtID:     hold.CourtID,
+		UserID:      hold.UserID,
+		StartTime:   hold.StartTime,
+		EndTime:     hold.EndTime,
+		BookingType: BookingTypeRegular,
+	}
+	if err := checkSameClub(tx, hold.UserID, hold.CourtID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	policy, err := GetCourtPolicy(tx, hold.CourtID, BookingTypeRegular)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := policy.Check(tx, booking, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	hold.StartTime = hold.StartTime.UTC()
+	hold.EndTime = hold.EndTime.UTC()
+
+	available, err := isCourtAvailable(tx, hold.CourtID, hold.StartTime, hold.EndTime)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !available {
+		tx.Rollback()
+		return ErrCourtUnavailable
+	}
+
+	hold.ExpiresAt = now.Add(config.Get().GetSlotHoldDuration()).UTC()
+	result, err := tx.Exec(`
+		INSERT INTO slot_holds (user_id, court_id, start_time, end_time, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, hold.UserID, hold.CourtID, hold.StartTime, hold.EndTime, hold.ExpiresAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	hold.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, earlier := range released {
+		announceHold(db, events.SlotReleased, earlier)
+	}
+	announceHold(db, events.SlotHeld, hold)
+	return nil
+}
+
+// GetSlotHoldByID retrieves a slot hold by its ID
+func GetSlotHoldByID(db *sql.DB, id interface{}) (*SlotHold, error) {
+	var holdID int64
+	switch v := id.(type) {
+	case int64:
+		holdID = v
+	case string:
+		var err error
+		holdID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getSlotHold(db, holdID)
+}
+
+// slotHoldSelect selects every slot hold column plus the court name
+const slotHoldSelect = `
+	SELECT h.id, h.user_id, h.court_id, h.start_time, h.end_time, h.expires_at, h.created_at,
+		c.name as court_name
+	FROM slot_holds h
+	JOIN courts c ON h.court_id = c.id
+`
+
+func getSlotHold(q Querier, holdID int64) (*SlotHold, error) {
+	hold := &SlotHold{}
+	err := scanSlotHold(q.QueryRow(slotHoldSelect+` WHERE h.id = ?`, holdID), hold)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("slot hold not found")
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ConfirmSlotHold turns a hold into a booking. The hold is released in the
+// same transaction so the booking goes through the normal checks.
+func ConfirmSlotHold(db *sql.DB, holdID int64, now time.Time) (*Booking, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := getSlotHold(tx, holdID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if !hold.ExpiresAt.After(now) {
+		tx.Rollback()
+		return nil, ErrSlotHoldExpired
+	}
+
+	_, err = tx.Exec(`DELETE FROM slot_holds WHERE id = ?`, holdID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking := &Booking{
+		CourtID:     hold.CourtID,
+		UserID:      hold.UserID,
+		StartTime:   hold.StartTime,
+		EndTime:     hold.EndTime,
+		Status:      BookingStatusPending,
+		BookingType: BookingTypeRegular,
+	}
+	if err := createBooking(tx, booking); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publish(event)
+	return booking, nil
+}
+
+// ReleaseSlotHold gives up a hold before it expires
+func ReleaseSlotHold(db *sql.DB, holdID int64) error {
+	hold, err := getSlotHold(db, holdID)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM slot_holds WHERE id = ?`, holdID); err != nil {
+		return err
+	}
+	announceHold(db, events.SlotReleased, hold)
+	return nil
+}
+
+// ReapExpiredSlotHolds deletes holds that have expired. Expired holds no
+// longer block availability, so this mostly keeps the table small; the
+// release is still announced so live views catch up.
+func ReapExpiredSlotHolds(db *sql.DB, now time.Time) (int64, error) {
+	expired, err := executeSlotHoldQuery(db, slotHoldSelect+`
+		WHERE julianday(h.expires_at) <= julianday(?)
+	`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(`
+		DELETE FROM slot_holds WHERE julianday(expires_at) <= julianday(?)
+	`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	for _, hold := range expired {
+		announceHold(db, events.SlotReleased, hold)
+	}
+	return result.RowsAffected()
+}
+
+// executeSlotHoldQuery runs a slotHoldSelect query and scans every row
+func executeSlotHoldQuery(q Querier, query string, args ...interface{}) ([]*SlotHold, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []*SlotHold
+	for rows.Next() {
+		hold := &SlotHold{}
+		if err := scanSlotHold(rows, hold); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+func scanSlotHold(row rowScanner, hold *SlotHold) error {
+	return row.Scan(
+		&hold.ID, &hold.UserID, &hold.CourtID, &hold.StartTime, &hold.EndTime,
+		&hold.ExpiresAt, &hold.CreatedAt, &hold.CourtName,
+	)
+}
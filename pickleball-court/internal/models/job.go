@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Job is a recurring background task. The schedule is kept in the
+// database so that a restart neither reruns a task early nor skips it.
+type Job struct {
+	ID        int64
+	Name      string
+	Interval  time.Duration
+	Status    string
+	RunAt     time.Time // when the job is next due
+	Failures  int       // consecutive failed runs
+	LastError string
+	StartedAt time.Time // zero until first run
+	LastRunAt time.Time // zero until first run
+	CreatedAt time.Time
+}
+
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusFailed  = "failed" // the last run failed; it runs again when due
+)
+
+var ErrJobRunning = errors.New("job is already running")
+
+const jobColumns = `id, name, interval_seconds, status, run_at, failures, last_error,
+	started_at, last_run_at, created_at`
+
+// ScheduleJob registers a recurring job, due straight away the first time.
+// A job that already exists keeps its next run time but takes the new
+// interval.
+func ScheduleJob(db *sql.DB, name string, interval time.Duration, now time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO jobs (name, interval_seconds, status, run_at, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (name) DO UPDATE SET interval_seconds = excluded.interval_seconds
+	`, name, int64(interval/time.Second), JobStatusPending, now.UTC())
+	return err
+}
+
+// GetJobs retrieves jobs with the given status, or all jobs when status is
+// empty, soonest due first
+func GetJobs(db *sql.DB, status string) ([]*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY run_at ASC, id ASC`
+	return queryJobs(db, query, args...)
+}
+
+// GetDueJobs retrieves the jobs due at now. A job still marked running
+// since before staleBefore is included too, as the instance running it
+// must have stopped before finishing.
+func GetDueJobs(db *sql.DB, now, staleBefore time.Time) ([]*Job, error) {
+	return queryJobs(db, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE (status != ? AND julianday(run_at) <= julianday(?))
+		OR (status = ? AND julianday(started_at) <= julianday(?))
+		ORDER BY run_at ASC, id ASC
+	`, JobStatusRunning, now.UTC(), JobStatusRunning, staleBefore.UTC())
+}
+
+// GetJobByID retrieves a job by its ID
+func GetJobByID(db *sql.DB, id interface{}) (*Job, error) {
+	var jobID int64
+	switch v := id.(type) {
+	case int64:
+		jobID = v
+	case string:
+		var err error
+		jobID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	jobs, err := queryJobs(db, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("job not found")
+	}
+	return jobs[0], nil
+}
+
+// StartJob marks a job as running. It returns ErrJobRunning, leaving the
+// job alone, if another run started since the job was loaded and is not
+// stale, that is started before staleBefore.
+func StartJob(db *sql.DB, job *Job, now, staleBefore time.Time) error {
+	result, err := db.Exec(`
+		UPDATE jobs SET status = ?, started_at = ?
+		WHERE id = ? AND (status != ? OR julianday(started_at) <= julianday(?))
+	`, JobStatusRunning, now.UTC(), job.ID, JobStatusRunning, staleBefore.UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrJobRunning
+	}
+
+	job.Status = JobStatusRunning
+	job.StartedAt = now.UTC()
+	return nil
+}
+
+// FinishJob records the outcome of a run and schedules the next one an
+// interval after now
+func FinishJob(db *sql.DB, job *Job, runErr error, now time.Time) error {
+	job.LastRunAt = now.UTC()
+	job.RunAt = now.Add(job.Interval).UTC()
+	if runErr != nil {
+		job.Status = JobStatusFailed
+		job.Failures++
+		job.LastError = runErr.Error()
+	} else {
+		job.Status = JobStatusPending
+		job.Failures = 0
+		job.LastError = ""
+	}
+
+	_, err := db.Exec(`
+		UPDATE jobs SET status = ?, run_at = ?, failures = ?, last_error = ?, last_run_at = ?
+		WHERE id = ?
+	`, job.Status, job.RunAt, job.Failures, job.LastError, job.LastRunAt, job.ID)
+	return err
+}
+
+// RunJobNow makes a job due straight away, for retrying a failed job
+// without waiting out its interval
+func RunJobNow(db *sql.DB, jobID int64, now time.Time) error {
+	result, err := db.Exec(`UPDATE jobs SET run_at = ? WHERE id = ? AND status != ?`,
+		now.UTC(), jobID, JobStatusRunning)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrJobRunning
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the named lease for holder until now plus
+// ttl. It reports false while another holder's lease has not expired.
+func AcquireLease(db *sql.DB, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO scheduler_leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE scheduler_leases.holder = excluded.holder
+		OR julianday(scheduler_leases.expires_at) <= julianday(?)
+	`, name, holder, now.Add(ttl).UTC(), now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// queryJobs runs a query selecting jobColumns and scans the results
+func queryJobs(db *sql.DB, query string, args ...interface{}) ([]*Job, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job := &Job{}
+		var intervalSeconds int64
+		var startedAt, lastRunAt sql.NullTime
+		err := rows.Scan(
+			&job.ID, &job.Name, &intervalSeconds, &job.Status, &job.RunAt, &job.Failures,
+			&job.LastError, &startedAt, &lastRunAt, &job.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		job.Interval = time.Duration(intervalSeconds) * time.Second
+		job.StartedAt = startedAt.Time
+		job.LastRunAt = lastRunAt.Time
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquireLeaseTakeover(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	ttl := time.Minute
+
+	acquire := func(holder string, at time.Time) bool {
+		t.Helper()
+		leader, err := AcquireLease(db, "scheduler", holder, at, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leader
+	}
+
+	if !acquire("a", now) {
+		t.Fatal("a could not take a free lease")
+	}
+	if acquire("b", now.Add(30*time.Second)) {
+		t.Error("b took the lease while a held it")
+	}
+	if !acquire("a", now.Add(45*time.Second)) {
+		t.Error("a could not renew its lease")
+	}
+
+	// The renewal holds the lease a full ttl from when it was made
+	if acquire("b", now.Add(90*time.Second)) {
+		t.Error("b took the lease before the renewal lapsed")
+	}
+	if !acquire("b", now.Add(106*time.Second)) {
+		t.Fatal("b could not take the lease after it lapsed")
+	}
+	if acquire("a", now.Add(107*time.Second)) {
+		t.Error("a took the lease back from b")
+	}
+}
+
+func TestStaleRunningJobIsRecovered(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	staleAfter := 15 * time.Minute
+
+	if err := ScheduleJob(db, "send-reminders", time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+	due, err := GetDueJobs(db, now, now.Add(-staleAfter))
+	if err != nil || len(due) != 1 {
+		t.Fatalf("got %d due jobs, err %v; want 1", len(due), err)
+	}
+	job := due[0]
+
+	// An instance starts the job and stops without finishing it
+	if err := StartJob(db, job, now, now.Add(-staleAfter)); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the run is recent it is left alone
+	later := now.Add(10 * time.Minute)
+	due, err = GetDueJobs(db, later, later.Add(-staleAfter))
+	if err != nil || len(due) != 0 {
+		t.Fatalf("got %d due jobs while running, err %v; want 0", len(due), err)
+	}
+	if err := StartJob(db, job, later, later.Add(-staleAfter)); err != ErrJobRunning {
+		t.Errorf("started a running job: got %v, want %v", err, ErrJobRunning)
+	}
+
+	// Once stale it is due again and can be restarted
+	later = now.Add(20 * time.Minute)
+	due, err = GetDueJobs(db, later, later.Add(-staleAfter))
+	if err != nil || len(due) != 1 || due[0].ID != job.ID {
+		t.Fatalf("got %d due jobs once stale, err %v; want the lost job", len(due), err)
+	}
+	if err := StartJob(db, due[0], later, later.Add(-staleAfter)); err != nil {
+		t.Fatalf("restart stale job: %v", err)
+	}
+	if err := FinishJob(db, due[0], nil, later); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := GetJobs(db, JobStatusPending)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("got %d pending jobs, err %v; want 1", len(jobs), err)
+	}
+	if !jobs[0].RunAt.Equal(later.Add(time.Hour)) {
+		t.Errorf("next run at %s, want an hour after the restart", jobs[0].RunAt)
+	}
+}
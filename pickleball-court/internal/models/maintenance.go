@@ -0,0 +1,232 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"pickleball-court/internal/events"
+	"strings"
+	"time"
+)
+
+// MaintenanceChange is an upcoming booking displaced by a maintenance
+// window. It was either moved to another court at the same time or, when
+// no court was free, cancelled.
+type MaintenanceChange struct {
+	Booking      *Booking
+	OldCourtID   int64
+	Moved        bool
+	Cancellation *Cancellation // set when the booking was cancelled
+}
+
+// MaintenanceResult is a saved maintenance window and the bookings it
+// displaced
+type MaintenanceResult struct {
+	Window    *CourtBlackout
+	Moved     []*MaintenanceChange
+	Cancelled []*MaintenanceChange
+}
+
+var ErrInvalidMaintenance = errors.New("maintenance needs a court and a reason, and must end in the future after it starts")
+
+// ScheduleMaintenance takes a court out of use between the window's start
+// and end. Upcoming bookings on the court inside the window are moved to
+// another court free at the same time where possible and cancelled
+// otherwise, all in one transaction. Bookings already under way are left
+// alone.
+func ScheduleMaintenance(db *sql.DB, window *CourtBlackout, now time.Time) (*MaintenanceResult, error) {
+	if window.CourtID == 0 || strings.TrimSpace(window.Reason) == "" ||
+		!window.EndTime.After(window.StartTime) || !window.EndTime.After(now) {
+		return nil, ErrInvalidMaintenance
+	}
+
+	court, err := GetCourtByID(db, AnyClub, window.CourtID)
+	if err != nil {
+		return nil, err
+	}
+	courts, err := GetAllCourts(db, court.ClubID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := scheduleMaintenance(tx, window, courts, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var recorded []events.Event
+	for _, change := range result.Moved {
+		event, err := recordBookingEvent(tx, events.BookingMoved, change.Booking.ID, &events.Slot{
+			CourtID: change.OldCourtID, StartTime: change.Booking.StartTime, EndTime: change.Booking.EndTime,
+		})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		recorded = append(recorded, event)
+	}
+	for _, change := range result.Cancelled {
+		event, err := recordBookingEvent(tx, events.BookingCancelled, change.Booking.ID, nil)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		recorded = append(recorded, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	announceSlot(court.ClubID, events.CourtClosed, window.CourtID, window.StartTime, window.EndTime)
+	publish(recorded...)
+	return result, nil
+}
+
+// scheduleMaintenance saves the window and displaces the bookings it
+// covers using the given transaction
+func scheduleMaintenance(tx *sql.Tx, window *CourtBlackout, courts []*Court, now time.Time) (*MaintenanceResult, error) {
+	window.Kind = BlackoutKindMaintenance
+	if err := insertBlackout(tx, window); err != nil {
+		return nil, err
+	}
+
+	affected, err := executeBookingQuery(tx, bookingSelect+`
+		WHERE b.court_id = ?
+		AND b.status NOT IN ('cancelled', 'rejected', 'no_show')
+		AND julianday(b.start_time) < julianday(?) AND julianday(b.end_time) > julianday(?)
+		AND julianday(b.start_time) > julianday(?)
+		ORDER BY b.start_time ASC, b.id ASC
+	`, window.CourtID, window.EndTime, window.StartTime, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MaintenanceResult{Window: window}
+	for _, booking := range affected {
+		change := &MaintenanceChange{Booking: booking, OldCourtID: booking.CourtID}
+
+		courtID, err := findReplacementCourt(tx, booking, courts)
+		if err != nil {
+			return nil, err
+		}
+
+		if courtID != 0 {
+			change.Booking, err = moveForMaintenance(tx, booking, courtID, window.CreatedBy)
+			if err != nil {
+				return nil, err
+			}
+			change.Moved = true
+			result.Moved = append(result.Moved, change)
+			continue
+		}
+
+		change.Cancellation, err = cancelBooking(tx, booking.ID, CancelOptions{
+			CancelledBy: window.CreatedBy,
+			Reason:      "court maintenance: " + window.Reason,
+			Override:    true,
+		}, now)
+		if err != nil {
+			return nil, err
+		}
+		booking.Status = BookingStatusCancelled
+		result.Cancelled = append(result.Cancelled, change)
+	}
+	return result, nil
+}
+
+// findReplacementCourt returns the first other court at the same facility
+// that is free and open for the whole of a booking and long enough for it,
+// or 0 if there is none
+func findReplacementCourt(tx *sql.Tx, booking *Booking, courts []*Court) (int64, error) {
+	policy, err := bookingPolicyFor(tx, booking)
+	if err != nil {
+		return 0, err
+	}
+	length := booking.EndTime.Sub(booking.StartTime)
+
+	var facilityID int64
+	for _, court := range courts {
+		if court.ID == booking.CourtID {
+			facilityID = court.FacilityID
+		}
+	}
+
+	for _, court := range courts {
+		if court.ID == booking.CourtID || court.FacilityID != facilityID {
+			continue
+		}
+		if policy.LimitCourtDuration && length > time.Duration(court.MaxBookingMinutes)*time.Minute {
+			continue
+		}
+
+		available, err := isCourtAvailable(tx, court.ID, booking.StartTime, booking.EndTime)
+		if err != nil {
+			return 0, err
+		}
+		if !available {
+			continue
+		}
+
+		err = checkCourtSchedule(tx, policy, court.ID, booking.StartTime, booking.EndTime)
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return court.ID, nil
+	}
+	return 0, nil
+}
+
+// moveForMaintenance moves a booking to another court at the same time and
+// records the move in its audit trail
+func moveForMaintenance(tx *sql.Tx, booking *Booking, courtID, changedBy int64) (*Booking, error) {
+	_, err := tx.Exec(`UPDATE bookings SET court_id = ? WHERE id = ?`, courtID, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Training sessions keep their own copy of the court
+	if booking.BookingType == BookingTypeTraining {
+		_, err = tx.Exec(`
+			UPDATE training_sessions SET court_id = ?
+			WHERE coach_id = ? AND court_id = ?
+			AND julianday(start_time) = julianday(?) AND julianday(end_time) = julianday(?)
+		`, courtID, booking.UserID, booking.CourtID, booking.StartTime.UTC(), booking.EndTime.UTC())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordBookingAudit(tx, &BookingAuditEntry{
+		BookingID:  booking.ID,
+		Action:     AuditActionReschedule,
+		ChangedBy:  changedBy,
+		OldCourtID: booking.CourtID,
+		NewCourtID: courtID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload so the joined court name matches the new court
+	return getBooking(tx, booking.ID)
+}
+
+// GetMaintenanceWindows retrieves a court's maintenance windows that have
+// not ended yet
+func GetMaintenanceWindows(db *sql.DB, courtID int64, now time.Time) ([]*CourtBlackout, error) {
+	return executeBlackoutQuery(db, blackoutSelect+`
+		WHERE bo.kind = ? AND bo.court_id = ?
+		AND julianday(bo.end_time) > julianday(?)
+		ORDER BY bo.start_time ASC
+	`, BlackoutKindMaintenance, courtID, now.UTC())
+}
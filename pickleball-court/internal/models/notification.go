@@ -0,0 +1,159 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	ID        int64
+	UserID    int64
+	Kind      string
+	Title     string
+	Body      string
+	ReadAt    time.Time // zero while unread
+	CreatedAt time.Time
+}
+
+// NotificationPreference says where a user wants notifications of one
+// kind delivered. Users get both until they choose otherwise.
+type NotificationPreference struct {
+	Kind  string
+	Inbox bool
+	Email bool
+}
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// CreateNotification adds a notification to a user's inbox
+func CreateNotification(q Querier, notification *Notification) error {
+	notification.CreatedAt = time.Now().UTC()
+	result, err := q.Exec(`
+		INSERT INTO notifications (user_id, kind, title, body, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, notification.UserID, notification.Kind, notification.Title, notification.Body, notification.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	notification.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetUserNotifications retrieves a user's most recent notifications, only
+// the unread ones if unreadOnly is set
+func GetUserNotifications(db *sql.DB, userID int64, unreadOnly bool, limit int) ([]*Notification, error) {
+	query := `
+		SELECT id, user_id, kind, title, body, read_at, created_at
+		FROM notifications
+		WHERE user_id = ?
+	`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+
+	rows, err := db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*Notification
+	for rows.Next() {
+		notification := &Notification{}
+		var readAt sql.NullTime
+		err := rows.Scan(
+			&notification.ID, &notification.UserID, &notification.Kind, &notification.Title,
+			&notification.Body, &readAt, &notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notification.ReadAt = readAt.Time
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications counts the unread notifications in a user's inbox
+func CountUnreadNotifications(db *sql.DB, userID int64) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func MarkNotificationRead(db *sql.DB, userID, notificationID int64, now time.Time) error {
+	result, err := db.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, ?)
+		WHERE id = ? AND user_id = ?
+	`, now.UTC(), notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification in a user's
+// inbox as read, returning how many there were
+func MarkAllNotificationsRead(db *sql.DB, userID int64, now time.Time) (int64, error) {
+	result, err := db.Exec(`UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`, now.UTC(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetNotificationPreference returns where a user wants notifications of
+// the given kind delivered
+func GetNotificationPreference(q Querier, userID int64, kind string) (*NotificationPreference, error) {
+	preference := &NotificationPreference{Kind: kind, Inbox: true, Email: true}
+	err := q.QueryRow(`
+		SELECT inbox, email FROM notification_preferences WHERE user_id = ? AND kind = ?
+	`, userID, kind).Scan(&preference.Inbox, &preference.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return preference, nil
+}
+
+// GetNotificationPreferences returns a user's preferences for each of the
+// given kinds
+func GetNotificationPreferences(db *sql.DB, userID int64, kinds []string) ([]*NotificationPreference, error) {
+	preferences := make([]*NotificationPreference, 0, len(kinds))
+	for _, kind := range kinds {
+		preference, err := GetNotificationPreference(db, userID, kind)
+		if err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences saves a user's preferences for the kinds given,
+// leaving the others as they were
+func SetNotificationPreferences(db *sql.DB, userID int64, preferences []*NotificationPreference) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, preference := range preferences {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, kind, inbox, email) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, kind) DO UPDATE SET inbox = excluded.inbox, email = excluded.email
+		`, userID, preference.Kind, preference.Inbox, preference.Email)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
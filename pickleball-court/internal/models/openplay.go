@@ -0,0 +1,429 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"pickleball-court/internal/events"
+	"strconv"
+	"time"
+)
+
+// OpenPlaySession is a drop-in block run by an admin or coach. The court is
+// reserved by an open_play booking and players sign up individually, up to
+// the session's capacity and within its skill range.
+type OpenPlaySession struct {
+	ID        int64
+	BookingID int64
+	CreatedBy int64
+	Title     string
+	Capacity  int
+	MinSkill  float64 // zero for no lower bound
+	MaxSkill  float64 // zero for no upper bound
+	CreatedAt time.Time
+
+	// Fields from the session's booking
+	CourtID   int64
+	StartTime time.Time
+	EndTime   time.Time
+	Status    string
+
+	// Additional fields for joins
+	CourtName     string
+	CreatedByName string
+	SignedUp      int
+	ClubID        int64
+}
+
+// OpenPlaySignup is a player's place in an open play session. Players on
+// court are playing the current game; everyone else waits in queued_at order.
+type OpenPlaySignup struct {
+	ID        int64
+	SessionID int64
+	UserID    int64
+	Status    string
+	OnCourt   bool
+	QueuedAt  time.Time
+	CreatedAt time.Time
+
+	// Additional fields for joins
+	UserName   string
+	SkillLevel float64
+}
+
+const (
+	OpenPlaySignupActive = "signed_up"
+	OpenPlaySignupLeft   = "left"
+
+	// OpenPlayPlayersPerGame is how many players rotate onto the court
+	OpenPlayPlayersPerGame = MaxPlayersPerBooking
+)
+
+var (
+	ErrOpenPlayFull    = errors.New("open play session is full")
+	ErrOpenPlayClosed  = errors.New("open play session is no longer open")
+	ErrSkillOutOfRange = errors.New("skill level is outside the range for this session")
+	ErrAlreadySignedUp = errors.New("already signed up for this session")
+	ErrNotSignedUp     = errors.New("not signed up for this session")
+	ErrInvalidOpenPlay = errors.New("open play needs a title, a capacity and a valid skill range")
+)
+
+// column order expected by scanOpenPlaySession
+const openPlaySelect = `
+		SELECT
+			s.id, s.booking_id, s.created_by, s.title, s.capacity,
+			s.min_skill, s.max_skill, s.created_at,
+			b.court_id, b.start_time, b.end_time, b.status,
+			c.name as court_name, u.username as created_by_name,
+			(SELECT COUNT(*) FROM open_play_signups os
+			 WHERE os.session_id = s.id AND os.status = 'signed_up') as signed_up,
+			COALESCE((SELECT f.club_id FROM facilities f WHERE f.id = c.facility_id), 0) as club_id
+		FROM open_play_sessions s
+		JOIN bookings b ON s.booking_id = b.id
+		JOIN courts c ON b.court_id = c.id
+		JOIN users u ON s.created_by = u.id
+`
+
+// column order expected by scanOpenPlaySignup
+const openPlaySignupSelect = `
+		SELECT
+			os.id, os.session_id, os.user_id, os.status, os.on_court,
+			os.queued_at, os.created_at, u.username, u.skill_level
+		FROM open_play_signups os
+		JOIN users u ON os.user_id = u.id
+`
+
+// CreateOpenPlaySession reserves the court with a confirmed open_play
+// booking and creates the session in the same transaction
+func CreateOpenPlaySession(db *sql.DB, session *OpenPlaySession) error {
+	if session.Title == "" || session.Capacity < 1 ||
+		session.MinSkill < 0 || session.MaxSkill < 0 ||
+		(session.MaxSkill > 0 && session.MinSkill > session.MaxSkill) {
+		return ErrInvalidOpenPlay
+	}
+
+	booking := &Booking{
+		CourtID:     session.CourtID,
+		UserID:      session.CreatedBy,
+		StartTime:   session.StartTime,
+		EndTime:     session.EndTime,
+		Status:      BookingStatusConfirmed,
+		BookingType: BookingTypeOpenPlay,
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := createBooking(tx, booking); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO open_play_sessions (booking_id, created_by, title, capacity, min_skill, max_skill, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, booking.ID, session.CreatedBy, session.Title, session.Capacity, session.MinSkill, session.MaxSkill)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	created, err := getOpenPlaySession(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publish(event)
+	*session = *created
+	return nil
+}
+
+// GetOpenPlaySessionByID retrieves a club's open play session by its ID
+func GetOpenPlaySessionByID(db *sql.DB, clubID int64, id interface{}) (*OpenPlaySession, error) {
+	var sessionID int64
+	switch v := id.(type) {
+	case int64:
+		sessionID = v
+	case string:
+		var err error
+		sessionID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getClubOpenPlaySession(db, clubID, sessionID)
+}
+
+func getOpenPlaySession(q Querier, sessionID int64) (*OpenPlaySession, error) {
+	return getClubOpenPlaySession(q, AnyClub, sessionID)
+}
+
+func getClubOpenPlaySession(q Querier, clubID, sessionID int64) (*OpenPlaySession, error) {
+	session := &OpenPlaySession{}
+	query := openPlaySelect + ` WHERE s.id = ? AND ` +
+		inClub("(SELECT f.club_id FROM facilities f WHERE f.id = c.facility_id)")
+	err := scanOpenPlaySession(q.QueryRow(query, sessionID, clubID, clubID), session)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("open play session not found")
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetUpcomingOpenPlaySessions retrieves a club's sessions that have not
+// ended and whose booking is still active
+func GetUpcomingOpenPlaySessions(db *sql.DB, clubID int64, now time.Time) ([]*OpenPlaySession, error) {
+	return executeOpenPlayQuery(db, openPlaySelect+`
+		WHERE c.`+courtsInClub+`
+		AND b.status NOT IN ('cancelled', 'rejected', 'no_show')
+		AND julianday(b.end_time) > julianday(?)
+		ORDER BY b.start_time ASC
+	`, clubID, now.UTC())
+}
+
+// SignUpForOpenPlay adds a player to the back of a session's queue. The
+// capacity and skill checks run in the same transaction as the insert.
+// Unrated players may only join sessions without a minimum skill.
+func SignUpForOpenPlay(db *sql.DB, sessionID, userID int64, now time.Time) (*OpenPlaySignup, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	signup, err := signUpForOpenPlay(tx, sessionID, userID, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return signup, nil
+}
+
+func signUpForOpenPlay(tx *sql.Tx, sessionID, userID int64, now time.Time) (*OpenPlaySignup, error) {
+	session, err := getOpenPlaySession(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.isOpen(now) {
+		return nil, ErrOpenPlayClosed
+	}
+	if err := checkSameClub(tx, userID, session.CourtID); err != nil {
+		return nil, err
+	}
+
+	var skill float64
+	err = tx.QueryRow(`SELECT skill_level FROM users WHERE id = ?`, userID).Scan(&skill)
+	if err != nil {
+		return nil, err
+	}
+	if skill < session.MinSkill || (session.MaxSkill > 0 && skill > session.MaxSkill) {
+		return nil, ErrSkillOutOfRange
+	}
+
+	var signedUp bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM open_play_signups WHERE session_id = ? AND user_id = ? AND status = ?)
+	`, sessionID, userID, OpenPlaySignupActive).Scan(&signedUp)
+	if err != nil {
+		return nil, err
+	}
+	if signedUp {
+		return nil, ErrAlreadySignedUp
+	}
+	if session.SignedUp >= session.Capacity {
+		return nil, ErrOpenPlayFull
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO open_play_signups (session_id, user_id, status, on_court, queued_at, created_at)
+		VALUES (?, ?, ?, 0, ?, CURRENT_TIMESTAMP)
+	`, sessionID, userID, OpenPlaySignupActive, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	signup := &OpenPlaySignup{}
+	err = scanOpenPlaySignup(tx.QueryRow(openPlaySignupSelect+` WHERE os.id = ?`, id), signup)
+	if err != nil {
+		return nil, err
+	}
+	return signup, nil
+}
+
+// LeaveOpenPlay takes a player out of a session, freeing their place
+func LeaveOpenPlay(db *sql.DB, sessionID, userID int64) error {
+	result, err := db.Exec(`
+		UPDATE open_play_signups SET status = ?, on_court = 0
+		WHERE session_id = ? AND user_id = ? AND status = ?
+	`, OpenPlaySignupLeft, sessionID, userID, OpenPlaySignupActive)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotSignedUp
+	}
+	return nil
+}
+
+// GetOpenPlayQueue retrieves the players signed up for a session, those on
+// court first and then the waiting queue in order
+func GetOpenPlayQueue(db *sql.DB, sessionID int64) ([]*OpenPlaySignup, error) {
+	return executeOpenPlaySignupQuery(db, openPlaySignupSelect+`
+		WHERE os.session_id = ? AND os.status = ?
+		ORDER BY os.on_court DESC, os.queued_at ASC, os.id ASC
+	`, sessionID, OpenPlaySignupActive)
+}
+
+// GetUserOpenPlaySignups retrieves a player's current sign-ups
+func GetUserOpenPlaySignups(db *sql.DB, userID int64) ([]*OpenPlaySignup, error) {
+	return executeOpenPlaySignupQuery(db, openPlaySignupSelect+`
+		WHERE os.user_id = ? AND os.status = ?
+		ORDER BY os.queued_at ASC
+	`, userID, OpenPlaySignupActive)
+}
+
+// RotateOpenPlay ends the current game: the players on court go to the back
+// of the queue and the next players in line take the court. The new queue is
+// returned.
+func RotateOpenPlay(db *sql.DB, sessionID int64, now time.Time) ([]*OpenPlaySignup, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := getOpenPlaySession(tx, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if !session.isOpen(now) {
+		tx.Rollback()
+		return nil, ErrOpenPlayClosed
+	}
+
+	_, err = tx.Exec(`
+		UPDATE open_play_signups SET on_court = 0, queued_at = ?
+		WHERE session_id = ? AND status = ? AND on_court = 1
+	`, now.UTC(), sessionID, OpenPlaySignupActive)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE open_play_signups SET on_court = 1
+		WHERE id IN (
+			SELECT id FROM open_play_signups
+			WHERE session_id = ? AND status = ?
+			ORDER BY queued_at ASC, id ASC
+			LIMIT ?
+		)
+	`, sessionID, OpenPlaySignupActive, OpenPlayPlayersPerGame)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetOpenPlayQueue(db, sessionID)
+}
+
+// isOpen reports whether players can still join or rotate in a session
+func (s *OpenPlaySession) isOpen(now time.Time) bool {
+	if s.Status == BookingStatusCancelled || s.Status == BookingStatusRejected || s.Status == BookingStatusNoShow {
+		return false
+	}
+	return s.EndTime.After(now)
+}
+
+// executeOpenPlayQuery runs a query selected with openPlaySelect
+func executeOpenPlayQuery(db *sql.DB, query string, args ...interface{}) ([]*OpenPlaySession, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*OpenPlaySession
+	for rows.Next() {
+		session := &OpenPlaySession{}
+		if err := scanOpenPlaySession(rows, session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// executeOpenPlaySignupQuery runs a query selected with openPlaySignupSelect
+func executeOpenPlaySignupQuery(db *sql.DB, query string, args ...interface{}) ([]*OpenPlaySignup, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signups []*OpenPlaySignup
+	for rows.Next() {
+		signup := &OpenPlaySignup{}
+		if err := scanOpenPlaySignup(rows, signup); err != nil {
+			return nil, err
+		}
+		signups = append(signups, signup)
+	}
+	return signups, rows.Err()
+}
+
+// scanOpenPlaySession scans a row selected with openPlaySelect
+func scanOpenPlaySession(row rowScanner, session *OpenPlaySession) error {
+	return row.Scan(
+		&session.ID, &session.BookingID, &session.CreatedBy, &session.Title, &session.Capacity,
+		&session.MinSkill, &session.MaxSkill, &session.CreatedAt,
+		&session.CourtID, &session.StartTime, &session.EndTime, &session.Status,
+		&session.CourtName, &session.CreatedByName, &session.SignedUp, &session.ClubID,
+	)
+}
+
+// scanOpenPlaySignup scans a row selected with openPlaySignupSelect
+func scanOpenPlaySignup(row rowScanner, signup *OpenPlaySignup) error {
+	return row.Scan(
+		&signup.ID, &signup.SessionID, &signup.UserID, &signup.Status, &signup.OnCourt,
+		&signup.QueuedAt, &signup.CreatedAt, &signup.UserName, &signup.SkillLevel,
+	)
+}
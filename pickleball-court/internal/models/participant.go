@@ -0,0 +1,406 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BookingParticipant is a player on a booking besides the booker. Members
+// are invited by account and accept or decline; guests have no account and
+// are added by name and email.
+type BookingParticipant struct {
+	ID          int64
+	BookingID   int64
+	UserID      int64 // zero for guests
+	GuestName   string
+	GuestEmail  string
+	Status      string
+	InvitedBy   int64
+	CreatedAt   time.Time
+	RespondedAt time.Time // zero until the invitee responds
+
+	// Additional fields for joins
+	UserName string
+}
+
+const (
+	ParticipantStatusInvited  = "invited"
+	ParticipantStatusAccepted = "accepted"
+	ParticipantStatusDeclined = "declined"
+	ParticipantStatusRemoved  = "removed"
+
+	// MaxPlayersPerBooking is a full doubles court, booker included
+	MaxPlayersPerBooking = 4
+)
+
+var (
+	ErrBookingFull         = errors.New("booking already has the maximum number of players")
+	ErrAlreadyParticipant  = errors.New("player is already on this booking")
+	ErrInvitationNotOpen   = errors.New("invitation is no longer open")
+	ErrInvalidParticipant  = errors.New("only other players can be invited to a booking")
+	ErrGuestDetailsMissing = errors.New("guests need a name and an email address")
+)
+
+// column order expected by scanParticipant
+const participantSelect = `
+		SELECT
+			p.id, p.booking_id, p.user_id, p.guest_name, p.guest_email, p.status,
+			p.invited_by, p.created_at, p.responded_at,
+			COALESCE(u.username, '') as user_name
+		FROM booking_participants p
+		LEFT JOIN users u ON p.user_id = u.id
+`
+
+// InviteParticipant invites a member to a booking
+func InviteParticipant(db *sql.DB, bookingID, userID, invitedBy int64) (*BookingParticipant, error) {
+	participant := &BookingParticipant{
+		BookingID: bookingID,
+		UserID:    userID,
+		Status:    ParticipantStatusInvited,
+		InvitedBy: invitedBy,
+	}
+	return participant, addParticipant(db, participant)
+}
+
+// AddGuest adds a non-member guest to a booking. Guests have no account to
+// respond with, so they count as accepted straight away.
+func AddGuest(db *sql.DB, bookingID int64, name, email string, invitedBy int64) (*BookingParticipant, error) {
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if name == "" || email == "" {
+		return nil, ErrGuestDetailsMissing
+	}
+
+	participant := &BookingParticipant{
+		BookingID:  bookingID,
+		GuestName:  name,
+		GuestEmail: email,
+		Status:     ParticipantStatusAccepted,
+		InvitedBy:  invitedBy,
+	}
+	return participant, addParticipant(db, participant)
+}
+
+// addParticipant checks the roster and inserts a participant in one
+// transaction so that concurrent invites cannot overfill a booking
+func addParticipant(db *sql.DB, participant *BookingParticipant) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	booking, err := getBooking(tx, participant.BookingID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkChangeable(booking, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if participant.UserID != 0 {
+		var role string
+		err := tx.QueryRow(`SELECT role FROM users WHERE id = ?`, participant.UserID).Scan(&role)
+		if err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				return errors.New("user not found")
+			}
+			return err
+		}
+		if role != RolePlayer || participant.UserID == booking.UserID {
+			tx.Rollback()
+			return ErrInvalidParticipant
+		}
+		if err := checkSameClubUsers(tx, booking.UserID, participant.UserID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		var exists bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM booking_participants
+				WHERE booking_id = ? AND user_id = ? AND status IN (?, ?)
+			)
+		`, participant.BookingID, participant.UserID,
+			ParticipantStatusInvited, ParticipantStatusAccepted).Scan(&exists)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if exists {
+			tx.Rollback()
+			return ErrAlreadyParticipant
+		}
+	}
+
+	// Open invitations hold a place on the roster until answered
+	players, err := countRoster(tx, participant.BookingID, true)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if players >= MaxPlayersPerBooking {
+		tx.Rollback()
+		return ErrBookingFull
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO booking_participants (
+			booking_id, user_id, guest_name, guest_email, status, invited_by, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`,
+		participant.BookingID, nullInt64(participant.UserID), participant.GuestName,
+		participant.GuestEmail, participant.Status, participant.InvitedBy,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	participant.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AcceptInvitation adds the invitee to the booking. The booking's hours then
+// count toward the invitee's weekly limit, so the invitee must have room.
+func AcceptInvitation(db *sql.DB, participantID int64, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	participant, err := getParticipant(tx, participantID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if participant.Status != ParticipantStatusInvited {
+		tx.Rollback()
+		return ErrInvitationNotOpen
+	}
+
+	booking, err := getBooking(tx, participant.BookingID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkChangeable(booking, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	policy, err := bookingPolicyFor(tx, booking)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = policy.CheckParticipant(tx, participant.UserID, booking, now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setParticipantStatus(tx, participantID, ParticipantStatusAccepted, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeclineInvitation turns an invitation down and frees the place on the roster
+func DeclineInvitation(db *sql.DB, participantID int64, now time.Time) error {
+	return closeParticipant(db, participantID, ParticipantStatusDeclined, now)
+}
+
+// RemoveParticipant takes a member or guest off a booking
+func RemoveParticipant(db *sql.DB, participantID int64, now time.Time) error {
+	return closeParticipant(db, participantID, ParticipantStatusRemoved, now)
+}
+
+// closeParticipant moves an invited or accepted participant to a final
+// status. The status is checked and changed in one transaction so that a
+// concurrent accept or invite sees the roster either before or after.
+func closeParticipant(db *sql.DB, participantID int64, status string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	participant, err := getParticipant(tx, participantID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if participant.Status != ParticipantStatusInvited &&
+		!(status == ParticipantStatusRemoved && participant.Status == ParticipantStatusAccepted) {
+		tx.Rollback()
+		return ErrInvitationNotOpen
+	}
+
+	if err := setParticipantStatus(tx, participantID, status, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func setParticipantStatus(q Querier, participantID int64, status string, now time.Time) error {
+	_, err := q.Exec(`
+		UPDATE booking_participants SET status = ?, responded_at = ? WHERE id = ?
+	`, status, now.UTC(), participantID)
+	return err
+}
+
+// GetParticipantByID retrieves a participant by its ID
+func GetParticipantByID(db *sql.DB, id interface{}) (*BookingParticipant, error) {
+	var participantID int64
+	switch v := id.(type) {
+	case int64:
+		participantID = v
+	case string:
+		var err error
+		participantID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getParticipant(db, participantID)
+}
+
+func getParticipant(q Querier, participantID int64) (*BookingParticipant, error) {
+	participant := &BookingParticipant{}
+	err := scanParticipant(q.QueryRow(participantSelect+` WHERE p.id = ?`, participantID), participant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("participant not found")
+		}
+		return nil, err
+	}
+	return participant, nil
+}
+
+// GetBookingParticipants retrieves the roster of a booking, leaving out
+// declined and removed players
+func GetBookingParticipants(db *sql.DB, bookingID int64) ([]*BookingParticipant, error) {
+	query := participantSelect + `
+		WHERE p.booking_id = ? AND p.status IN (?, ?)
+		ORDER BY p.created_at ASC, p.id ASC
+	`
+	return executeParticipantQuery(db, query, bookingID, ParticipantStatusInvited, ParticipantStatusAccepted)
+}
+
+// AttachParticipants loads the roster of each booking into its Participants
+func AttachParticipants(db *sql.DB, bookings []*Booking) error {
+	for _, booking := range bookings {
+		participants, err := GetBookingParticipants(db, booking.ID)
+		if err != nil {
+			return err
+		}
+		booking.Participants = participants
+	}
+	return nil
+}
+
+// GetUserInvitations retrieves a player's open invitations with the booking
+// each one is for
+func GetUserInvitations(db *sql.DB, userID int64) ([]*BookingParticipant, []*Booking, error) {
+	query := participantSelect + `
+		JOIN bookings b ON p.booking_id = b.id
+		WHERE p.user_id = ? AND p.status = ?
+		AND b.status NOT IN ('cancelled', 'rejected', 'no_show')
+		ORDER BY b.start_time ASC
+	`
+	invitations, err := executeParticipantQuery(db, query, userID, ParticipantStatusInvited)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var bookings []*Booking
+	for _, invitation := range invitations {
+		booking, err := getBooking(db, invitation.BookingID)
+		if err != nil {
+			return nil, nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	return invitations, bookings, nil
+}
+
+// GetParticipatingBookings retrieves bookings a player has joined as a
+// participant rather than as the booker
+func GetParticipatingBookings(db *sql.DB, userID int64) ([]*Booking, error) {
+	query := bookingSelect + `
+		JOIN booking_participants p ON p.booking_id = b.id
+		WHERE p.user_id = ? AND p.status = ?
+		ORDER BY b.start_time DESC
+	`
+	return executeBookingQuery(db, query, userID, ParticipantStatusAccepted)
+}
+
+// countRoster counts the booker plus accepted participants, and open
+// invitations too when includeInvited is set
+func countRoster(q Querier, bookingID int64, includeInvited bool) (int, error) {
+	statuses := []interface{}{ParticipantStatusAccepted, ""}
+	if includeInvited {
+		statuses[1] = ParticipantStatusInvited
+	}
+
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM booking_participants
+		WHERE booking_id = ? AND status IN (?, ?)
+	`, bookingID, statuses[0], statuses[1]).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count + 1, nil
+}
+
+// scanParticipant scans a row selected with participantSelect
+func scanParticipant(row rowScanner, participant *BookingParticipant) error {
+	var userID sql.NullInt64
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&participant.ID, &participant.BookingID, &userID, &participant.GuestName,
+		&participant.GuestEmail, &participant.Status, &participant.InvitedBy,
+		&participant.CreatedAt, &respondedAt, &participant.UserName,
+	)
+	if err != nil {
+		return err
+	}
+	participant.UserID = userID.Int64
+	participant.RespondedAt = respondedAt.Time
+	return nil
+}
+
+// executeParticipantQuery runs a participantSelect query and scans every row
+func executeParticipantQuery(q Querier, query string, args ...interface{}) ([]*BookingParticipant, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []*BookingParticipant
+	for rows.Next() {
+		participant := &BookingParticipant{}
+		if err := scanParticipant(rows, participant); err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	return participants, rows.Err()
+}
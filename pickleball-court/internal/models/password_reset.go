@@ -0,0 +1,90 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+// PasswordResetTTL is how long an emailed password reset link works
+const PasswordResetTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("the password reset link is invalid or has expired")
+
+// CreatePasswordReset issues a reset token for the club member with the
+// given email address. It returns the user and the token to email them,
+// or a nil user if no member has that address; only a hash of the token is
+// stored.
+func CreatePasswordReset(db *sql.DB, clubID int64, email string, now time.Time) (*User, string, error) {
+	user := &User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ? AND club_id = ?`
+	if err := scanUser(db.QueryRow(query, email, clubID), user); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, user.ID, hashResetToken(token), now.Add(PasswordResetTTL).UTC())
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// ResetPassword sets a new password using an emailed reset token. Every
+// outstanding token for the user stops working once one is used.
+func ResetPassword(db *sql.DB, token, newPassword string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var userID int64
+	err = tx.QueryRow(`
+		SELECT user_id FROM password_resets
+		WHERE token_hash = ? AND used_at IS NULL AND julianday(expires_at) > julianday(?)
+	`, hashResetToken(token), now.UTC()).Scan(&userID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, string(hashedPassword), userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, now.UTC(), userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashResetToken returns the stored form of a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PenaltyPolicy controls what happens to players who cancel late repeatedly.
+// Each club has its own, stored in the database so its admins can change it
+// at runtime.
+type PenaltyPolicy struct {
+	StrikeLimit      int    // late cancellations within the window before a penalty applies
+	StrikeWindowDays int    // how far back late cancellations are counted
+	Penalty          string // one of the Penalty* constants
+	SuspensionDays   int    // booking suspension length for PenaltySuspend
+	ForfeitCredits   int    // credits forfeited for PenaltyForfeitCredit
+	UpdatedAt        time.Time
+}
+
+// UserPenalty is a penalty applied to a user
+type UserPenalty struct {
+	ID        int64
+	UserID    int64
+	Penalty   string
+	Reason    string
+	Credits   int
+	ExpiresAt time.Time // zero for penalties that do not expire
+	CreatedAt time.Time
+}
+
+const (
+	PenaltyNone          = "none"
+	PenaltySuspend       = "suspend"
+	PenaltyForfeitCredit = "forfeit_credit"
+)
+
+// DefaultPenaltyPolicy is used until a club's admin saves a policy
+var DefaultPenaltyPolicy = PenaltyPolicy{
+	StrikeLimit:      3,
+	StrikeWindowDays: 30,
+	Penalty:          PenaltySuspend,
+	SuspensionDays:   7,
+}
+
+// Validate checks that the policy values are usable
+func (p *PenaltyPolicy) Validate() error {
+	switch p.Penalty {
+	case PenaltyNone, PenaltySuspend, PenaltyForfeitCredit:
+	default:
+		return errors.New("penalty must be none, suspend or forfeit_credit")
+	}
+	if p.StrikeLimit < 1 {
+		return errors.New("strike limit must be at least 1")
+	}
+	if p.StrikeWindowDays < 1 {
+		return errors.New("strike window must be at least 1 day")
+	}
+	if p.Penalty == PenaltySuspend && p.SuspensionDays < 1 {
+		return errors.New("suspension must last at least 1 day")
+	}
+	if p.Penalty == PenaltyForfeitCredit && p.ForfeitCredits < 1 {
+		return errors.New("forfeited credits must be at least 1")
+	}
+	return nil
+}
+
+// GetPenaltyPolicy returns a club's late-cancellation penalty policy
+func GetPenaltyPolicy(db *sql.DB, clubID int64) (*PenaltyPolicy, error) {
+	return getPenaltyPolicy(db, clubID)
+}
+
+func getPenaltyPolicy(q Querier, clubID int64) (*PenaltyPolicy, error) {
+	policy := &PenaltyPolicy{}
+	query := `
+		SELECT strike_limit, strike_window_days, penalty, suspension_days, forfeit_credits, updated_at
+		FROM penalty_policy WHERE club_id = ?
+	`
+	err := q.QueryRow(query, clubID).Scan(
+		&policy.StrikeLimit, &policy.StrikeWindowDays, &policy.Penalty,
+		&policy.SuspensionDays, &policy.ForfeitCredits, &policy.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			defaults := DefaultPenaltyPolicy
+			return &defaults, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePenaltyPolicy saves a club's late-cancellation penalty policy
+func UpdatePenaltyPolicy(db *sql.DB, clubID int64, policy *PenaltyPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR REPLACE INTO penalty_policy (
+			club_id, strike_limit, strike_window_days, penalty, suspension_days, forfeit_credits, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`
+	_, err := db.Exec(query, clubID,
+		policy.StrikeLimit, policy.StrikeWindowDays, policy.Penalty,
+		policy.SuspensionDays, policy.ForfeitCredits,
+	)
+	return err
+}
+
+// GetActiveSuspension returns the suspension currently blocking a user from
+// booking, or nil if there is none
+func GetActiveSuspension(db *sql.DB, userID int64, now time.Time) (*UserPenalty, error) {
+	return getActiveSuspension(db, userID, now)
+}
+
+func getActiveSuspension(q Querier, userID int64, now time.Time) (*UserPenalty, error) {
+	return getActivePenalty(q, userID, PenaltySuspend, now)
+}
+
+// getActivePenalty returns the longest-running unexpired penalty of the given
+// kind for a user, or nil if there is none
+func getActivePenalty(q Querier, userID int64, kind string, now time.Time) (*UserPenalty, error) {
+	penalty := &UserPenalty{}
+	var expiresAt sql.NullTime
+	query := `
+		SELECT id, user_id, penalty, reason, credits, expires_at, created_at
+		FROM user_penalties
+		WHERE user_id = ? AND penalty = ?
+		AND julianday(expires_at) > julianday(?)
+		ORDER BY expires_at DESC
+		LIMIT 1
+	`
+	err := q.QueryRow(query, userID, kind, now.UTC()).Scan(
+		&penalty.ID, &penalty.UserID, &penalty.Penalty, &penalty.Reason,
+		&penalty.Credits, &expiresAt, &penalty.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	penalty.ExpiresAt = expiresAt.Time
+	return penalty, nil
+}
+
+// GetUserPenalties retrieves every penalty applied to a user
+func GetUserPenalties(db *sql.DB, userID int64) ([]*UserPenalty, error) {
+	query := `
+		SELECT id, user_id, penalty, reason, credits, expires_at, created_at
+		FROM user_penalties
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var penalties []*UserPenalty
+	for rows.Next() {
+		penalty := &UserPenalty{}
+		var expiresAt sql.NullTime
+		err := rows.Scan(
+			&penalty.ID, &penalty.UserID, &penalty.Penalty, &penalty.Reason,
+			&penalty.Credits, &expiresAt, &penalty.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		penalty.ExpiresAt = expiresAt.Time
+		penalties = append(penalties, penalty)
+	}
+	return penalties, nil
+}
+
+// applyLateCancelPenalty applies the penalty policy of the user's club once
+// they have reached the strike limit of late cancellations within the strike
+// window. It returns the penalty applied, or nil if the user is still under
+// the limit.
+func applyLateCancelPenalty(tx *sql.Tx, userID int64, now time.Time) (*UserPenalty, error) {
+	clubID, err := getUserClubID(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := getPenaltyPolicy(tx, clubID)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Penalty == PenaltyNone {
+		return nil, nil
+	}
+
+	var strikes int
+	query := `
+		SELECT COUNT(*) FROM booking_cancellations
+		WHERE user_id = ? AND late = 1 AND waived = 0
+		AND julianday(cancelled_at) > julianday(?)
+	`
+	err = tx.QueryRow(query, userID, now.AddDate(0, 0, -policy.StrikeWindowDays).UTC()).Scan(&strikes)
+	if err != nil {
+		return nil, err
+	}
+	if strikes < policy.StrikeLimit {
+		return nil, nil
+	}
+
+	penalty := &UserPenalty{
+		UserID:  userID,
+		Penalty: policy.Penalty,
+		Reason:  "repeated late cancellations",
+	}
+	switch policy.Penalty {
+	case PenaltySuspend:
+		penalty.ExpiresAt = now.AddDate(0, 0, policy.SuspensionDays).UTC()
+	case PenaltyForfeitCredit:
+		penalty.Credits = policy.ForfeitCredits
+	}
+
+	if err := createUserPenalty(tx, penalty); err != nil {
+		return nil, err
+	}
+	return penalty, nil
+}
+
+// createUserPenalty records a penalty against a user
+func createUserPenalty(q Querier, penalty *UserPenalty) error {
+	result, err := q.Exec(`
+		INSERT INTO user_penalties (user_id, penalty, reason, credits, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, penalty.UserID, penalty.Penalty, penalty.Reason, penalty.Credits, nullTime(penalty.ExpiresAt))
+	if err != nil {
+		return err
+	}
+
+	penalty.ID, err = result.LastInsertId()
+	return err
+}
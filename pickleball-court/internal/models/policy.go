@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pickleball-court/config"
+)
+
+// BookingPolicy holds the rules a booking must satisfy before it is stored
+type BookingPolicy struct {
+	MaxDaysAhead    int
+	MinHoursAdvance int
+	MaxHoursPerWeek int
+	OpeningHour     int
+	ClosingHour     int
+	SlotDuration    time.Duration
+	Location        *time.Location
+
+	// ClubID selects the club whose all-courts overrides and blackouts
+	// apply, set from the court's facility
+	ClubID int64
+
+	// FacilityID selects the no-show policy applied to restricted players
+	FacilityID int64
+
+	// LimitCourtDuration caps a booking at the court's MaxBookingMinutes
+	LimitCourtDuration bool
+}
+
+// PolicyError is returned when a booking violates one of the policy rules
+type PolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
+
+const (
+	PolicyRuleInvalidRange   = "invalid_range"
+	PolicyRuleMinAdvance     = "min_hours_advance"
+	PolicyRuleMaxDaysAhead   = "max_days_ahead"
+	PolicyRuleOperatingHours = "operating_hours"
+	PolicyRuleWeeklyHours    = "max_hours_per_week"
+	PolicyRuleSlotDuration   = "slot_duration"
+	PolicyRuleSlotAlignment  = "slot_alignment"
+	PolicyRuleMaxDuration    = "max_duration"
+	PolicyRuleSuspended      = "suspended"
+	PolicyRuleBlackout       = "blackout"
+)
+
+// GetBookingPolicy returns the policy that applies to the given booking type.
+// Training bookings made by coaches use their own advance and weekly limits.
+// Open play blocks share the training horizon but count toward nobody's
+// weekly hours.
+func GetBookingPolicy(cfg *config.Config, bookingType string) BookingPolicy {
+	policy := BookingPolicy{
+		MaxDaysAhead:    cfg.Booking.MaxDaysAhead,
+		MinHoursAdvance: cfg.Booking.MinHoursAdvance,
+		MaxHoursPerWeek: cfg.Booking.MaxHoursPerWeek,
+		OpeningHour:     cfg.Booking.OpeningHour,
+		ClosingHour:     cfg.Booking.ClosingHour,
+		SlotDuration:    cfg.GetSlotDuration(),
+		Location:        cfg.GetTimeZone(),
+
+		LimitCourtDuration: true,
+	}
+
+	if bookingType == BookingTypeTraining {
+		policy.MaxDaysAhead = cfg.Booking.TrainingMaxDaysAhead
+		policy.MinHoursAdvance = cfg.Booking.TrainingMinHoursAdvance
+		policy.MaxHoursPerWeek = cfg.Booking.TrainingMaxHoursPerWeek
+		policy.SlotDuration = 0
+		policy.LimitCourtDuration = false
+	}
+
+	if bookingType == BookingTypeOpenPlay {
+		policy.MaxDaysAhead = cfg.Booking.TrainingMaxDaysAhead
+		policy.MinHoursAdvance = cfg.Booking.TrainingMinHoursAdvance
+		policy.MaxHoursPerWeek = 0
+		policy.SlotDuration = 0
+		policy.LimitCourtDuration = false
+	}
+
+	if policy.Location == nil {
+		policy.Location = time.UTC
+	}
+	return policy
+}
+
+// Check validates a booking against every policy rule. A zero limit disables
+// the corresponding rule.
+func (p BookingPolicy) Check(q Querier, booking *Booking, now time.Time) error {
+	start := booking.StartTime.In(p.Location)
+	end := booking.EndTime.In(p.Location)
+
+	if !end.After(start) {
+		return &PolicyError{Rule: PolicyRuleInvalidRange, Message: "booking must end after it starts"}
+	}
+
+	p, err := p.forUser(q, booking.UserID, now)
+	if err != nil {
+		return err
+	}
+
+	duration := end.Sub(start)
+	if p.SlotDuration > 0 && duration%p.SlotDuration != 0 {
+		return &PolicyError{
+			Rule:    PolicyRuleSlotDuration,
+			Message: fmt.Sprintf("booking length must be a multiple of %d minutes", int(p.SlotDuration.Minutes())),
+		}
+	}
+
+	// Bookings start on the slot grid, so that they never leave gaps too
+	// short to book on either side
+	if p.SlotDuration > 0 && timeOfDay(start)%p.SlotDuration != 0 {
+		return &PolicyError{
+			Rule:    PolicyRuleSlotAlignment,
+			Message: fmt.Sprintf("bookings must start on a %d-minute slot boundary", int(p.SlotDuration.Minutes())),
+		}
+	}
+
+	if p.LimitCourtDuration {
+		maxDuration, err := getCourtMaxBookingDuration(q, booking.CourtID)
+		if err != nil {
+			return err
+		}
+		if maxDuration > 0 && duration > maxDuration {
+			return &PolicyError{
+				Rule:    PolicyRuleMaxDuration,
+				Message: fmt.Sprintf("this court can be booked for at most %d minutes at a time", int(maxDuration.Minutes())),
+			}
+		}
+	}
+
+	if p.MinHoursAdvance > 0 && start.Before(now.Add(time.Duration(p.MinHoursAdvance)*time.Hour)) {
+		return &PolicyError{
+			Rule:    PolicyRuleMinAdvance,
+			Message: fmt.Sprintf("bookings must be made at least %d hour(s) in advance", p.MinHoursAdvance),
+		}
+	}
+
+	if p.MaxDaysAhead > 0 && start.After(now.AddDate(0, 0, p.MaxDaysAhead)) {
+		return &PolicyError{
+			Rule:    PolicyRuleMaxDaysAhead,
+			Message: fmt.Sprintf("bookings can be made at most %d day(s) ahead", p.MaxDaysAhead),
+		}
+	}
+
+	// Opening hours come from the court's schedule, falling back to the
+	// configured hours
+	if err := checkCourtSchedule(q, p, booking.CourtID, start, end); err != nil {
+		return err
+	}
+
+	// An existing booking being moved or handed over is not counted twice
+	return p.checkWeeklyHours(q, booking.UserID, start, end, booking.ID)
+}
+
+// CheckParticipant validates that a player may join a booking as a
+// participant: they must not be suspended and the booking's hours must fit
+// within their weekly limit.
+func (p BookingPolicy) CheckParticipant(q Querier, userID int64, booking *Booking, now time.Time) error {
+	p, err := p.forUser(q, userID, now)
+	if err != nil {
+		return err
+	}
+	return p.checkWeeklyHours(q, userID, booking.StartTime.In(p.Location), booking.EndTime.In(p.Location), booking.ID)
+}
+
+// forUser returns the policy as it applies to a user. Suspended users get a
+// PolicyError; repeat no-shows tighten the weekly and advance limits for a
+// while.
+func (p BookingPolicy) forUser(q Querier, userID int64, now time.Time) (BookingPolicy, error) {
+	suspension, err := getActiveSuspension(q, userID, now)
+	if err != nil {
+		return p, err
+	}
+	if suspension != nil {
+		return p, &PolicyError{
+			Rule:    PolicyRuleSuspended,
+			Message: fmt.Sprintf("booking privileges are suspended until %s", suspension.ExpiresAt.In(p.Location).Format("Jan 02, 2006 15:04")),
+		}
+	}
+
+	restriction, err := getActiveRestriction(q, userID, now)
+	if err != nil {
+		return p, err
+	}
+	if restriction != nil {
+		noShowPolicy, err := getNoShowPolicy(q, p.ClubID, p.FacilityID)
+		if err != nil {
+			return p, err
+		}
+		p.MaxHoursPerWeek = tighterLimit(p.MaxHoursPerWeek, noShowPolicy.MaxHoursPerWeek)
+		p.MaxDaysAhead = tighterLimit(p.MaxDaysAhead, noShowPolicy.MaxDaysAhead)
+	}
+	return p, nil
+}
+
+// checkWeeklyHours checks that adding start to end keeps the user within
+// the weekly limit, leaving excludeBookingID out of the hours already held
+func (p BookingPolicy) checkWeeklyHours(q Querier, userID int64, start, end time.Time, excludeBookingID int64) error {
+	if p.MaxHoursPerWeek <= 0 {
+		return nil
+	}
+
+	weekStart := startOfWeek(start)
+	booked, err := getUserBookedHours(q, userID, weekStart, weekStart.AddDate(0, 0, 7), excludeBookingID)
+	if err != nil {
+		return err
+	}
+	if booked+end.Sub(start).Hours() > float64(p.MaxHoursPerWeek) {
+		return &PolicyError{
+			Rule:    PolicyRuleWeeklyHours,
+			Message: fmt.Sprintf("bookings are limited to %d hour(s) per week", p.MaxHoursPerWeek),
+		}
+	}
+	return nil
+}
+
+// GetUserBookedHours returns how many hours of active bookings a user
+// holds between from and to, as booker or as an accepted participant
+func GetUserBookedHours(db *sql.DB, userID int64, from, to time.Time) (float64, error) {
+	return getUserBookedHours(db, userID, from, to, 0)
+}
+
+// getUserBookedHours sums booked hours, leaving out excludeBookingID
+func getUserBookedHours(q Querier, userID int64, from, to time.Time, excludeBookingID int64) (float64, error) {
+	query := `
+		SELECT start_time, end_time FROM bookings
+		WHERE (user_id = ? OR id IN (
+			SELECT booking_id FROM booking_participants WHERE user_id = ? AND status = 'accepted'
+		))
+		AND id != ? AND status NOT IN ('cancelled', 'rejected', 'no_show')
+		AND julianday(start_time) < julianday(?)
+		AND julianday(end_time) > julianday(?)
+	`
+	rows, err := q.Query(query, userID, userID, excludeBookingID, to.UTC(), from.UTC())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var hours float64
+	for rows.Next() {
+		var start, end time.Time
+		if err := rows.Scan(&start, &end); err != nil {
+			return 0, err
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			hours += end.Sub(start).Hours()
+		}
+	}
+	return hours, rows.Err()
+}
+
+// startOfWeek returns midnight on the Monday of t's week in t's location
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	day := t.AddDate(0, 0, -offset)
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// timeOfDay returns how far into its day t falls on the wall clock
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// nextSlotStart returns the first start on the slot grid at or after t.
+// The grid counts whole slots from midnight on the wall clock, the same
+// rule Check applies to booking starts.
+func nextSlotStart(t time.Time, slot time.Duration) time.Time {
+	offset := timeOfDay(t) % slot
+	if offset == 0 {
+		return t
+	}
+	clock := timeOfDay(t) - offset + slot
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, int(clock), t.Location())
+}
+
+// tighterLimit returns the stricter of two limits where zero means unlimited
+func tighterLimit(current, restricted int) int {
+	if restricted > 0 && (current == 0 || restricted < current) {
+		return restricted
+	}
+	return current
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pickleball-court/config"
+)
+
+// openTestDB opens a fresh database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	config.Load()
+
+	db, err := OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser creates a user in a club
+func createTestUser(t *testing.T, db *sql.DB, clubID int64, username, role string) *User {
+	t.Helper()
+	user := &User{
+		Username: username,
+		Password: "password",
+		Email:    username + "@example.com",
+		Role:     role,
+		ClubID:   clubID,
+	}
+	if err := CreateUser(db, user); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestCourt creates a court in a club's first facility
+func createTestCourt(t *testing.T, db *sql.DB, clubID int64, name string) *Court {
+	t.Helper()
+	court := &Court{Name: name, ClubID: clubID}
+	if err := CreateCourt(db, court); err != nil {
+		t.Fatalf("create court %s: %v", name, err)
+	}
+	return court
+}
+
+// tomorrowAt returns the given time tomorrow in the configured timezone
+func tomorrowAt(hour, minute int) time.Time {
+	now := time.Now().In(config.Get().GetTimeZone())
+	return time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+}
+
+func TestPolicyRejectsStartsOffTheSlotGrid(t *testing.T) {
+	db := openTestDB(t)
+	court := createTestCourt(t, db, 1, "Court 1")
+	player := createTestUser(t, db, 1, "player", RolePlayer)
+
+	policy, err := GetCourtPolicy(db, court.ID, BookingTypeRegular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot := policy.SlotDuration
+
+	tests := []struct {
+		name  string
+		start time.Time
+		rule  string
+	}{
+		{"on the grid", tomorrowAt(10, 0), ""},
+		{"off the grid", tomorrowAt(10, 7), PolicyRuleSlotAlignment},
+		{"half a slot off", tomorrowAt(10, 0).Add(slot / 2), PolicyRuleSlotAlignment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := &Booking{
+				CourtID:   court.ID,
+				UserID:    player.ID,
+				StartTime: tt.start,
+				EndTime:   tt.start.Add(slot),
+			}
+			err := policy.Check(db, booking, time.Now())
+
+			var policyErr *PolicyError
+			switch {
+			case tt.rule == "" && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case tt.rule != "" && !errors.As(err, &policyErr):
+				t.Errorf("got error %v, want policy rule %s", err, tt.rule)
+			case tt.rule != "" && policyErr.Rule != tt.rule:
+				t.Errorf("got rule %s, want %s", policyErr.Rule, tt.rule)
+			}
+		})
+	}
+}
+
+func TestAvailableSlotsCanBeBookedWhenACourtOpensOffTheGrid(t *testing.T) {
+	db := openTestDB(t)
+	court := createTestCourt(t, db, 1, "Court 1")
+	player := createTestUser(t, db, 1, "player", RolePlayer)
+
+	var week []*CourtHours
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		week = append(week, &CourtHours{Weekday: day, OpensAt: "06:30", ClosesAt: "22:00"})
+	}
+	if err := SetCourtHours(db, court.ID, week); err != nil {
+		t.Fatal(err)
+	}
+
+	day := tomorrowAt(0, 0)
+	availability, err := GetCourtAvailability(db, AvailabilityRequest{
+		From:     day,
+		To:       day,
+		CourtIDs: []int64{court.ID},
+		Filter:   CourtFilter{ClubID: 1},
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(availability) != 1 || len(availability[0].Slots) == 0 {
+		t.Fatalf("got no slots for the court")
+	}
+
+	slots := availability[0].Slots
+	if want := tomorrowAt(7, 0); !slots[0].Start.Equal(want) {
+		t.Errorf("first slot starts at %s, want %s", slots[0].Start, want)
+	}
+	for _, slot := range []AvailabilitySlot{slots[0], slots[len(slots)-1]} {
+		booking := &Booking{
+			CourtID:     court.ID,
+			UserID:      player.ID,
+			StartTime:   slot.Start,
+			EndTime:     slot.End,
+			Status:      BookingStatusConfirmed,
+			BookingType: BookingTypeRegular,
+		}
+		if err := CreateBooking(db, booking); err != nil {
+			t.Errorf("book the slot at %s: %v", slot.Start.Format("15:04"), err)
+		}
+	}
+}
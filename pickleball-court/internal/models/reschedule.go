@@ -0,0 +1,153 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// ErrNotReschedulable is returned when a booking can no longer be moved
+var ErrNotReschedulable = errors.New("only upcoming pending or confirmed bookings can be changed")
+
+// RescheduleBooking moves a booking to another time or court in a single
+// transaction. The move only happens if the target is free and allowed by
+// the booking policy; otherwise the booking is left untouched. A zero
+// courtID keeps the current court. The slot given up is offered to the
+// waitlist, and the offers are returned.
+func RescheduleBooking(db *sql.DB, bookingID, courtID int64, start, end time.Time, changedBy int64) (*Booking, []*WaitlistEntry, error) {
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	booking, err := getBooking(tx, bookingID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if err := checkChangeable(booking, now); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	old := *booking
+	if courtID == 0 {
+		courtID = booking.CourtID
+	}
+	if err := checkSameClub(tx, booking.UserID, courtID); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	booking.CourtID = courtID
+	booking.StartTime = start.UTC()
+	booking.EndTime = end.UTC()
+
+	policy, err := bookingPolicyFor(tx, booking)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if err := policy.Check(tx, booking, now); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	available, err := isCourtAvailableExcept(tx, booking.CourtID, booking.StartTime, booking.EndTime, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if !available {
+		tx.Rollback()
+		return nil, nil, ErrCourtUnavailable
+	}
+
+	// A booking moved to a new time is reminded again before it
+	_, err = tx.Exec(`
+		UPDATE bookings SET court_id = ?, start_time = ?, end_time = ?,
+			reminder_sent_at = CASE WHEN julianday(start_time) = julianday(?) THEN reminder_sent_at END
+		WHERE id = ?
+	`, booking.CourtID, booking.StartTime, booking.EndTime, booking.StartTime, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		if isOverlapError(err) {
+			return nil, nil, ErrCourtUnavailable
+		}
+		return nil, nil, err
+	}
+
+	err = recordBookingAudit(tx, &BookingAuditEntry{
+		BookingID:    booking.ID,
+		Action:       AuditActionReschedule,
+		ChangedBy:    changedBy,
+		OldCourtID:   old.CourtID,
+		NewCourtID:   booking.CourtID,
+		OldStartTime: old.StartTime,
+		NewStartTime: booking.StartTime,
+		OldEndTime:   old.EndTime,
+		NewEndTime:   booking.EndTime,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	offers, err := offerFreedSlot(tx, old.CourtID, old.StartTime, old.EndTime, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	// Reload so the joined court name matches the new court
+	booking, err = getBooking(tx, bookingID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	event, err := recordBookingEvent(tx, events.BookingMoved, bookingID, &events.Slot{
+		CourtID: old.CourtID, StartTime: old.StartTime, EndTime: old.EndTime,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	publish(event)
+	return booking, offers, nil
+}
+
+// checkChangeable reports whether a booking may still be rescheduled or
+// transferred
+func checkChangeable(booking *Booking, now time.Time) error {
+	if booking.Status != BookingStatusPending && booking.Status != BookingStatusConfirmed {
+		return ErrNotReschedulable
+	}
+	if !booking.CheckedInAt.IsZero() {
+		return ErrNotReschedulable
+	}
+	if !booking.StartTime.After(now) {
+		return ErrBookingStarted
+	}
+	return nil
+}
+
+// bookingPolicyFor returns the policy an existing booking is held to on its
+// court. Series occurrences keep the longer series horizon.
+func bookingPolicyFor(q Querier, booking *Booking) (BookingPolicy, error) {
+	policy, err := GetCourtPolicy(q, booking.CourtID, booking.BookingType)
+	if err != nil {
+		return policy, err
+	}
+	if booking.SeriesID != 0 {
+		policy.MaxDaysAhead = config.Get().Booking.SeriesMaxDaysAhead
+	}
+	return policy, nil
+}
@@ -0,0 +1,623 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"pickleball-court/internal/events"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CourtHours are a court's opening hours on one day of the week. Times are
+// "HH:MM" in the facility's timezone; "24:00" closes at midnight.
+type CourtHours struct {
+	CourtID  int64
+	Weekday  time.Weekday
+	OpensAt  string
+	ClosesAt string
+	Closed   bool
+}
+
+// CourtHoursOverride replaces the weekly hours on a single date, for
+// holidays and special events. A zero CourtID applies to every court in
+// the club.
+type CourtHoursOverride struct {
+	ID        int64
+	ClubID    int64
+	CourtID   int64
+	Date      string // YYYY-MM-DD
+	OpensAt   string
+	ClosesAt  string
+	Closed    bool
+	Reason    string
+	CreatedAt time.Time
+
+	// Additional fields for joins
+	FacilityID int64 // zero for overrides covering every court
+}
+
+// CourtBlackout takes a court out of use for a stretch of time. A zero
+// CourtID blacks out every court in the club. Maintenance windows are
+// blackouts of kind BlackoutKindMaintenance; see ScheduleMaintenance.
+type CourtBlackout struct {
+	ID        int64
+	ClubID    int64
+	CourtID   int64
+	StartTime time.Time
+	EndTime   time.Time
+	Reason    string
+	Kind      string
+	CreatedBy int64
+	CreatedAt time.Time
+
+	// Additional fields for joins
+	CourtName  string // empty for blackouts covering every court
+	FacilityID int64  // zero for blackouts covering every court
+}
+
+const (
+	BlackoutKindClosure     = "closure"
+	BlackoutKindMaintenance = "maintenance"
+
+	dateLayout = "2006-01-02"
+)
+
+var (
+	ErrInvalidCourtHours = errors.New("opening hours must be HH:MM, closing after opening")
+	ErrInvalidOverride   = errors.New("overrides need a YYYY-MM-DD date and either valid hours or closed")
+	ErrInvalidBlackout   = errors.New("blackouts need a reason and must end after they start")
+)
+
+// column order expected by scanBlackout
+const blackoutSelect = `
+		SELECT
+			bo.id, COALESCE(bo.club_id, 0), COALESCE(bo.court_id, 0), bo.start_time, bo.end_time, bo.reason,
+			bo.kind, bo.created_by, bo.created_at, COALESCE(c.name, '') as court_name,
+			COALESCE(c.facility_id, 0) as facility_id
+		FROM court_blackouts bo
+		LEFT JOIN courts c ON bo.court_id = c.id
+`
+
+// GetCourtHours returns a court's opening hours for each day of the week,
+// Sunday first. Days without their own hours show the facility's defaults.
+func GetCourtHours(db *sql.DB, courtID int64) ([]*CourtHours, error) {
+	policy, err := GetCourtPolicy(db, courtID, BookingTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	week := make([]*CourtHours, 7)
+	for day := range week {
+		week[day] = &CourtHours{
+			CourtID:  courtID,
+			Weekday:  time.Weekday(day),
+			OpensAt:  fmt.Sprintf("%02d:00", policy.OpeningHour),
+			ClosesAt: fmt.Sprintf("%02d:00", policy.ClosingHour),
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT court_id, weekday, opens_at, closes_at, closed
+		FROM court_hours WHERE court_id = ?
+	`, courtID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hours := &CourtHours{}
+		if err := rows.Scan(&hours.CourtID, &hours.Weekday, &hours.OpensAt, &hours.ClosesAt, &hours.Closed); err != nil {
+			return nil, err
+		}
+		week[hours.Weekday] = hours
+	}
+	return week, rows.Err()
+}
+
+// SetCourtHours replaces a court's weekly opening hours. Weekdays left out
+// go back to the configured defaults.
+func SetCourtHours(db *sql.DB, courtID int64, week []*CourtHours) error {
+	for _, hours := range week {
+		if hours.Weekday < time.Sunday || hours.Weekday > time.Saturday {
+			return ErrInvalidCourtHours
+		}
+		if !hours.Closed {
+			if _, _, err := parseOpeningHours(hours.OpensAt, hours.ClosesAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM court_hours WHERE court_id = ?`, courtID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, hours := range week {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO court_hours (court_id, weekday, opens_at, closes_at, closed)
+			VALUES (?, ?, ?, ?, ?)
+		`, courtID, int(hours.Weekday), hours.OpensAt, hours.ClosesAt, hours.Closed)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateHoursOverride adds opening hours for a single date. Overrides for
+// a court always belong to the court's club.
+func CreateHoursOverride(db *sql.DB, override *CourtHoursOverride) error {
+	if _, err := time.Parse(dateLayout, override.Date); err != nil {
+		return ErrInvalidOverride
+	}
+	if !override.Closed {
+		if _, _, err := parseOpeningHours(override.OpensAt, override.ClosesAt); err != nil {
+			return ErrInvalidOverride
+		}
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO court_hours_overrides (club_id, court_id, date, opens_at, closes_at, closed, reason, created_at)
+		VALUES (COALESCE((`+courtClubQuery+`), ?), ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, override.CourtID, override.ClubID, nullInt64(override.CourtID),
+		override.Date, override.OpensAt, override.ClosesAt, override.Closed, override.Reason)
+	if err != nil {
+		return err
+	}
+
+	override.ID, err = result.LastInsertId()
+	return err
+}
+
+// column order expected by scanHoursOverride
+const hoursOverrideSelect = `
+		SELECT
+			o.id, COALESCE(o.club_id, 0), COALESCE(o.court_id, 0), o.date, o.opens_at, o.closes_at, o.closed,
+			o.reason, o.created_at, COALESCE(c.facility_id, 0) as facility_id
+		FROM court_hours_overrides o
+		LEFT JOIN courts c ON o.court_id = c.id
+`
+
+// GetHoursOverrides retrieves a club's overrides on or after the given date
+func GetHoursOverrides(db *sql.DB, clubID int64, fromDate string) ([]*CourtHoursOverride, error) {
+	rows, err := db.Query(hoursOverrideSelect+`
+		WHERE o.club_id = ? AND o.date >= ?
+		ORDER BY o.date ASC, o.court_id ASC
+	`, clubID, fromDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []*CourtHoursOverride
+	for rows.Next() {
+		override := &CourtHoursOverride{}
+		if err := scanHoursOverride(rows, override); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+// GetHoursOverrideByID retrieves a club's date override by its ID
+func GetHoursOverrideByID(db *sql.DB, clubID int64, id interface{}) (*CourtHoursOverride, error) {
+	var overrideID int64
+	switch v := id.(type) {
+	case int64:
+		overrideID = v
+	case string:
+		var err error
+		overrideID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	override := &CourtHoursOverride{}
+	err := scanHoursOverride(db.QueryRow(hoursOverrideSelect+` WHERE o.id = ? AND `+inClub("o.club_id"),
+		overrideID, clubID, clubID), override)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("override not found")
+		}
+		return nil, err
+	}
+	return override, nil
+}
+
+// DeleteHoursOverride removes a date override
+func DeleteHoursOverride(db *sql.DB, id interface{}) error {
+	var overrideID int64
+	switch v := id.(type) {
+	case int64:
+		overrideID = v
+	case string:
+		var err error
+		overrideID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid ID type")
+	}
+
+	result, err := db.Exec(`DELETE FROM court_hours_overrides WHERE id = ?`, overrideID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return errors.New("override not found")
+	}
+	return nil
+}
+
+// CreateBlackout saves a blackout window. Existing bookings inside it are
+// left alone; they are returned so the admin can deal with them.
+func CreateBlackout(db *sql.DB, blackout *CourtBlackout) ([]*Booking, error) {
+	if strings.TrimSpace(blackout.Reason) == "" || !blackout.EndTime.After(blackout.StartTime) {
+		return nil, ErrInvalidBlackout
+	}
+
+	blackout.Kind = BlackoutKindClosure
+	if err := insertBlackout(db, blackout); err != nil {
+		return nil, err
+	}
+	announceSlot(blackout.ClubID, events.CourtClosed, blackout.CourtID, blackout.StartTime, blackout.EndTime)
+	return GetBlackoutConflicts(db, blackout)
+}
+
+// insertBlackout saves a validated blackout window
+func insertBlackout(q Querier, blackout *CourtBlackout) error {
+	blackout.StartTime = blackout.StartTime.UTC()
+	blackout.EndTime = blackout.EndTime.UTC()
+
+	result, err := q.Exec(`
+		INSERT INTO court_blackouts (club_id, court_id, start_time, end_time, reason, kind, created_by, created_at)
+		VALUES (COALESCE((`+courtClubQuery+`), ?), ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, blackout.CourtID, blackout.ClubID, nullInt64(blackout.CourtID),
+		blackout.StartTime, blackout.EndTime, blackout.Reason, blackout.Kind, blackout.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	blackout.ID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return q.QueryRow(`SELECT club_id FROM court_blackouts WHERE id = ?`, blackout.ID).Scan(&blackout.ClubID)
+}
+
+// courtClubQuery selects the club owning a court
+const courtClubQuery = `SELECT f.club_id FROM courts c JOIN facilities f ON f.id = c.facility_id WHERE c.id = ?`
+
+// GetBlackoutConflicts returns the active bookings a blackout would
+// overlap, so they can be reviewed before the blackout is saved
+func GetBlackoutConflicts(db *sql.DB, blackout *CourtBlackout) ([]*Booking, error) {
+	if !blackout.EndTime.After(blackout.StartTime) {
+		return nil, ErrInvalidBlackout
+	}
+
+	query := bookingSelect + `
+		WHERE (b.court_id = ? OR (? = 0 AND f.club_id = ?))
+		AND b.status NOT IN ('cancelled', 'rejected', 'no_show')
+		AND julianday(b.start_time) < julianday(?) AND julianday(b.end_time) > julianday(?)
+		ORDER BY b.start_time ASC
+	`
+	return executeBookingQuery(db, query,
+		blackout.CourtID, blackout.CourtID, blackout.ClubID, blackout.EndTime.UTC(), blackout.StartTime.UTC())
+}
+
+// GetUpcomingBlackouts retrieves a club's blackouts that have not ended yet
+func GetUpcomingBlackouts(db *sql.DB, clubID int64, now time.Time) ([]*CourtBlackout, error) {
+	return executeBlackoutQuery(db, blackoutSelect+`
+		WHERE bo.club_id = ? AND julianday(bo.end_time) > julianday(?)
+		ORDER BY bo.start_time ASC
+	`, clubID, now.UTC())
+}
+
+// executeBlackoutQuery runs a blackoutSelect query and scans every row
+func executeBlackoutQuery(q Querier, query string, args ...interface{}) ([]*CourtBlackout, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blackouts []*CourtBlackout
+	for rows.Next() {
+		blackout := &CourtBlackout{}
+		if err := scanBlackout(rows, blackout); err != nil {
+			return nil, err
+		}
+		blackouts = append(blackouts, blackout)
+	}
+	return blackouts, rows.Err()
+}
+
+// GetBlackoutByID retrieves a club's blackout window by its ID
+func GetBlackoutByID(db *sql.DB, clubID int64, id interface{}) (*CourtBlackout, error) {
+	var blackoutID int64
+	switch v := id.(type) {
+	case int64:
+		blackoutID = v
+	case string:
+		var err error
+		blackoutID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	blackout := &CourtBlackout{}
+	err := scanBlackout(db.QueryRow(blackoutSelect+` WHERE bo.id = ? AND `+inClub("bo.club_id"),
+		blackoutID, clubID, clubID), blackout)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("blackout not found")
+		}
+		return nil, err
+	}
+	return blackout, nil
+}
+
+// DeleteBlackout removes a blackout window
+func DeleteBlackout(db *sql.DB, id interface{}) error {
+	var blackoutID int64
+	switch v := id.(type) {
+	case int64:
+		blackoutID = v
+	case string:
+		var err error
+		blackoutID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid ID type")
+	}
+
+	// Loaded first so the reopened slot can be announced
+	blackout, err := GetBlackoutByID(db, AnyClub, blackoutID)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`DELETE FROM court_blackouts WHERE id = ?`, blackoutID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return errors.New("blackout not found")
+	}
+	announceSlot(blackout.ClubID, events.CourtReopened, blackout.CourtID, blackout.StartTime, blackout.EndTime)
+	return nil
+}
+
+// CheckCourtOpen reports whether a court is open for the whole of a time
+// range, returning a PolicyError if not
+func CheckCourtOpen(db *sql.DB, courtID int64, start, end time.Time) error {
+	policy, err := GetCourtPolicy(db, courtID, BookingTypeRegular)
+	if err != nil {
+		return err
+	}
+	return checkCourtSchedule(db, policy, courtID, start, end)
+}
+
+// checkCourtSchedule checks a booking range against the court's opening
+// hours for the day it starts on and against any blackout windows
+func checkCourtSchedule(q Querier, p BookingPolicy, courtID int64, start, end time.Time) error {
+	start = start.In(p.Location)
+	end = end.In(p.Location)
+
+	schedule, err := loadCourtSchedule(q, p, start, start)
+	if err != nil {
+		return err
+	}
+
+	window, open := schedule.openWindow(courtID, start)
+	if !open {
+		return &PolicyError{Rule: PolicyRuleOperatingHours, Message: "the court is closed on this day"}
+	}
+	if start.Before(window.Start) || end.After(window.End) {
+		return &PolicyError{
+			Rule: PolicyRuleOperatingHours,
+			Message: fmt.Sprintf("bookings on this court must be between %s and %s",
+				window.Start.Format("15:04"), formatClosing(window)),
+		}
+	}
+
+	var reason string
+	err = q.QueryRow(`
+		SELECT reason FROM court_blackouts
+		WHERE (court_id = ? OR (court_id IS NULL AND club_id = ?))
+		AND julianday(start_time) < julianday(?) AND julianday(end_time) > julianday(?)
+		ORDER BY start_time ASC
+		LIMIT 1
+	`, courtID, p.ClubID, end.UTC(), start.UTC()).Scan(&reason)
+	if err == nil {
+		return &PolicyError{Rule: PolicyRuleBlackout, Message: "the court is unavailable at this time: " + reason}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// courtSchedule resolves opening hours per court and date. A court-specific
+// override wins over an all-courts override, which wins over the court's
+// weekly hours, which win over the configured defaults.
+type courtSchedule struct {
+	policy    BookingPolicy
+	weekly    map[int64]map[time.Weekday]CourtHours
+	overrides map[int64]map[string]CourtHoursOverride // court 0 is every court in the club
+}
+
+// loadCourtSchedule loads weekly hours and the overrides for the days from
+// first to last inclusive
+func loadCourtSchedule(q Querier, p BookingPolicy, first, last time.Time) (*courtSchedule, error) {
+	schedule := &courtSchedule{
+		policy:    p,
+		weekly:    make(map[int64]map[time.Weekday]CourtHours),
+		overrides: make(map[int64]map[string]CourtHoursOverride),
+	}
+
+	rows, err := q.Query(`SELECT court_id, weekday, opens_at, closes_at, closed FROM court_hours`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hours CourtHours
+		if err := rows.Scan(&hours.CourtID, &hours.Weekday, &hours.OpensAt, &hours.ClosesAt, &hours.Closed); err != nil {
+			return nil, err
+		}
+		if schedule.weekly[hours.CourtID] == nil {
+			schedule.weekly[hours.CourtID] = make(map[time.Weekday]CourtHours)
+		}
+		schedule.weekly[hours.CourtID][hours.Weekday] = hours
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT COALESCE(court_id, 0), date, opens_at, closes_at, closed, reason
+		FROM court_hours_overrides
+		WHERE (court_id IS NOT NULL OR club_id = ?)
+		AND date >= ? AND date <= ?
+		ORDER BY id ASC
+	`, p.ClubID, first.In(p.Location).Format(dateLayout), last.In(p.Location).Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var override CourtHoursOverride
+		err := rows.Scan(&override.CourtID, &override.Date, &override.OpensAt,
+			&override.ClosesAt, &override.Closed, &override.Reason)
+		if err != nil {
+			return nil, err
+		}
+		if schedule.overrides[override.CourtID] == nil {
+			schedule.overrides[override.CourtID] = make(map[string]CourtHoursOverride)
+		}
+		schedule.overrides[override.CourtID][override.Date] = override
+	}
+	return schedule, rows.Err()
+}
+
+// openWindow returns a court's opening hours on the given day, or false if
+// the court is closed all day
+func (s *courtSchedule) openWindow(courtID int64, day time.Time) (Interval, bool) {
+	day = day.In(s.policy.Location)
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.policy.Location)
+	date := midnight.Format(dateLayout)
+
+	opensAt, closesAt, closed := fmt.Sprintf("%02d:00", s.policy.OpeningHour), fmt.Sprintf("%02d:00", s.policy.ClosingHour), false
+	if hours, ok := s.weekly[courtID][day.Weekday()]; ok {
+		opensAt, closesAt, closed = hours.OpensAt, hours.ClosesAt, hours.Closed
+	}
+	if override, ok := s.overrides[0][date]; ok {
+		opensAt, closesAt, closed = override.OpensAt, override.ClosesAt, override.Closed
+	}
+	if override, ok := s.overrides[courtID][date]; ok {
+		opensAt, closesAt, closed = override.OpensAt, override.ClosesAt, override.Closed
+	}
+	if closed {
+		return Interval{}, false
+	}
+
+	opens, closes, err := parseOpeningHours(opensAt, closesAt)
+	if err != nil {
+		return Interval{}, false
+	}
+	return Interval{Start: addClock(midnight, opens), End: addClock(midnight, closes)}, true
+}
+
+// parseOpeningHours parses an opening and closing time, checking that the
+// court closes after it opens
+func parseOpeningHours(opensAt, closesAt string) (time.Duration, time.Duration, error) {
+	opens, err := parseClock(opensAt)
+	if err != nil {
+		return 0, 0, err
+	}
+	closes, err := parseClock(closesAt)
+	if err != nil {
+		return 0, 0, err
+	}
+	if closes <= opens {
+		return 0, 0, ErrInvalidCourtHours
+	}
+	return opens, closes, nil
+}
+
+// parseClock parses an "HH:MM" time of day, allowing "24:00" for midnight
+func parseClock(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, ErrInvalidCourtHours
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrInvalidCourtHours
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, ErrInvalidCourtHours
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, ErrInvalidCourtHours
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// addClock returns the wall-clock time of day on the given midnight, so
+// that daylight-saving changes do not shift opening hours
+func addClock(midnight time.Time, clock time.Duration) time.Time {
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day(),
+		int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, midnight.Location())
+}
+
+// formatClosing formats a closing time, showing midnight as 24:00
+func formatClosing(window Interval) string {
+	if window.End.Day() != window.Start.Day() {
+		return "24:00"
+	}
+	return window.End.Format("15:04")
+}
+
+// scanBlackout scans a row selected with blackoutSelect
+func scanBlackout(row rowScanner, blackout *CourtBlackout) error {
+	return row.Scan(
+		&blackout.ID, &blackout.ClubID, &blackout.CourtID, &blackout.StartTime, &blackout.EndTime,
+		&blackout.Reason, &blackout.Kind, &blackout.CreatedBy, &blackout.CreatedAt, &blackout.CourtName,
+		&blackout.FacilityID,
+	)
+}
+
+// scanHoursOverride scans a row selected with hoursOverrideSelect
+func scanHoursOverride(row rowScanner, override *CourtHoursOverride) error {
+	return row.Scan(
+		&override.ID, &override.ClubID, &override.CourtID, &override.Date, &override.OpensAt,
+		&override.ClosesAt, &override.Closed, &override.Reason, &override.CreatedAt,
+		&override.FacilityID,
+	)
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Page is a window into a listing. A zero Limit returns every row.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// BookingSearch narrows a club's bookings. Zero fields match everything;
+// From and To keep bookings overlapping that range.
+type BookingSearch struct {
+	ClubID     int64
+	FacilityID int64
+	UserID     int64
+	CourtID    int64
+	Status     string
+	From       time.Time
+	To         time.Time
+	Page
+}
+
+// UserSearch narrows a club's members. Query matches part of a username or
+// email address.
+type UserSearch struct {
+	ClubID int64
+	Role   string
+	Query  string
+	Page
+}
+
+// TrainingSearch narrows a club's training sessions. Zero fields match
+// everything; From and To keep sessions overlapping that range.
+type TrainingSearch struct {
+	ClubID  int64
+	CoachID int64
+	From    time.Time
+	To      time.Time
+	Page
+}
+
+// SearchBookings retrieves one page of a club's bookings, latest first,
+// and how many match in all
+func SearchBookings(db *sql.DB, s BookingSearch) ([]*Booking, int, error) {
+	where := []string{"f.club_id = ?"}
+	args := []interface{}{s.ClubID}
+	if s.FacilityID != 0 {
+		where = append(where, "c.facility_id = ?")
+		args = append(args, s.FacilityID)
+	}
+	if s.UserID != 0 {
+		where = append(where, "b.user_id = ?")
+		args = append(args, s.UserID)
+	}
+	if s.CourtID != 0 {
+		where = append(where, "b.court_id = ?")
+		args = append(args, s.CourtID)
+	}
+	if s.Status != "" {
+		where = append(where, "b.status = ?")
+		args = append(args, s.Status)
+	}
+	where, args = overlapping("b", where, args, s.From, s.To)
+
+	filter := ` WHERE ` + strings.Join(where, " AND ")
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM bookings b
+		JOIN courts c ON b.court_id = c.id
+		LEFT JOIN facilities f ON c.facility_id = f.id
+	`+filter, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query, args := paged(bookingSelect+filter+` ORDER BY b.start_time DESC, b.id DESC`, args, s.Page)
+	bookings, err := executeBookingQuery(db, query, args...)
+	return bookings, total, err
+}
+
+// SearchUsers retrieves one page of a club's members, by username, and how
+// many match in all
+func SearchUsers(db *sql.DB, s UserSearch) ([]*User, int, error) {
+	where := []string{"club_id = ?"}
+	args := []interface{}{s.ClubID}
+	if s.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, s.Role)
+	}
+	if s.Query != "" {
+		where = append(where, "(username LIKE ? OR email LIKE ?)")
+		pattern := "%" + s.Query + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	filter := ` WHERE ` + strings.Join(where, " AND ")
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query, args := paged(`SELECT `+userColumns+` FROM users`+filter+` ORDER BY username ASC, id ASC`, args, s.Page)
+	users, err := executeUserQuery(db, query, args...)
+	return users, total, err
+}
+
+// SearchTrainingSessions retrieves one page of a club's training sessions,
+// soonest first, with how many players are enrolled in each, and how many
+// match in all
+func SearchTrainingSessions(db *sql.DB, s TrainingSearch) ([]*TrainingSession, int, error) {
+	where := []string{"c." + courtsInClub}
+	args := []interface{}{s.ClubID}
+	if s.CoachID != 0 {
+		where = append(where, "t.coach_id = ?")
+		args = append(args, s.CoachID)
+	}
+	where, args = overlapping("t", where, args, s.From, s.To)
+
+	filter := ` WHERE ` + strings.Join(where, " AND ")
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM training_sessions t
+		JOIN courts c ON t.court_id = c.id
+	`+filter, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query, args := paged(`
+		SELECT
+			t.id, t.coach_id, t.court_id, t.title, t.description,
+			t.start_time, t.end_time, t.max_participants, t.created_at,
+			u.username as coach_name, c.name as court_name,
+			(SELECT COUNT(*) FROM training_session_participants WHERE session_id = t.id)
+		FROM training_sessions t
+		JOIN users u ON t.coach_id = u.id
+		JOIN courts c ON t.court_id = c.id
+	`+filter+` ORDER BY t.start_time ASC, t.id ASC`, args, s.Page)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var sessions []*TrainingSession
+	for rows.Next() {
+		session := &TrainingSession{ClubID: s.ClubID}
+		err := rows.Scan(
+			&session.ID, &session.CoachID, &session.CourtID,
+			&session.Title, &session.Description, &session.StartTime,
+			&session.EndTime, &session.MaxParticipants, &session.CreatedAt,
+			&session.CoachName, &session.CourtName, &session.Enrolled,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, total, rows.Err()
+}
+
+// overlapping adds conditions keeping rows of the aliased table whose
+// start_time and end_time overlap [from, to). Zero times are left open.
+func overlapping(alias string, where []string, args []interface{}, from, to time.Time) ([]string, []interface{}) {
+	if !from.IsZero() {
+		where = append(where, "julianday("+alias+".end_time) > julianday(?)")
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		where = append(where, "julianday("+alias+".start_time) < julianday(?)")
+		args = append(args, to.UTC())
+	}
+	return where, args
+}
+
+// paged adds the LIMIT and OFFSET of a page to a query
+func paged(query string, args []interface{}, page Page) (string, []interface{}) {
+	if page.Limit <= 0 {
+		return query, args
+	}
+	return query + ` LIMIT ? OFFSET ?`, append(args, page.Limit, page.Offset)
+}
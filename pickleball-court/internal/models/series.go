@@ -0,0 +1,318 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// BookingSeries is a recurring booking of one court at the same time of day
+type BookingSeries struct {
+	ID        int64
+	UserID    int64
+	CourtID   int64
+	StartTime time.Time // first occurrence
+	EndTime   time.Time
+	Frequency string
+	Until     time.Time // zero when the series is bounded by Count
+	Count     int       // zero when the series is bounded by Until
+	Status    string
+	CreatedAt time.Time
+
+	// Additional fields for joins
+	CourtName string
+	UserName  string
+}
+
+// SeriesConflict describes an occurrence that could not be booked
+type SeriesConflict struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+const (
+	SeriesFrequencyWeekly   = "weekly"
+	SeriesFrequencyBiweekly = "biweekly"
+
+	SeriesStatusActive    = "active"
+	SeriesStatusCancelled = "cancelled"
+
+	// MaxSeriesOccurrences caps how many bookings a single series may create
+	MaxSeriesOccurrences = 52
+)
+
+// Occurrences returns the start times of every occurrence in the series.
+// Each falls at the first occurrence's wall-clock time in loc, so that the
+// series keeps its time of day across daylight saving changes.
+func (s *BookingSeries) Occurrences(loc *time.Location) ([]time.Time, error) {
+	var step int
+	switch s.Frequency {
+	case SeriesFrequencyWeekly:
+		step = 7
+	case SeriesFrequencyBiweekly:
+		step = 14
+	default:
+		return nil, errors.New("frequency must be weekly or biweekly")
+	}
+
+	if s.Until.IsZero() == (s.Count == 0) {
+		return nil, errors.New("series must end either on a date or after a number of occurrences")
+	}
+	if s.Count < 0 || s.Count > MaxSeriesOccurrences {
+		return nil, errors.New("series may have at most " + strconv.Itoa(MaxSeriesOccurrences) + " occurrences")
+	}
+
+	first := s.StartTime.In(loc)
+	var starts []time.Time
+	for i := 0; ; i++ {
+		start := first.AddDate(0, 0, i*step)
+		if s.Count > 0 && i >= s.Count {
+			break
+		}
+		if !s.Until.IsZero() && start.After(s.Until) {
+			break
+		}
+		if len(starts) >= MaxSeriesOccurrences {
+			return nil, errors.New("series may have at most " + strconv.Itoa(MaxSeriesOccurrences) + " occurrences")
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// CreateBookingSeries stores a series and books every occurrence that is
+// free. Occurrences that clash with existing bookings or break the booking
+// policy are skipped and returned as conflicts.
+func CreateBookingSeries(db *sql.DB, series *BookingSeries, status string) ([]*Booking, []SeriesConflict, error) {
+	policy, err := GetCourtPolicy(db, series.CourtID, BookingTypeRegular)
+	if err != nil {
+		return nil, nil, err
+	}
+	policy.MaxDaysAhead = config.Get().Booking.SeriesMaxDaysAhead
+
+	starts, err := series.Occurrences(policy.Location)
+	if err != nil {
+		return nil, nil, err
+	}
+	duration := series.EndTime.Sub(series.StartTime)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	series.Status = SeriesStatusActive
+	query := `
+		INSERT INTO booking_series (
+			user_id, court_id, start_time, end_time, frequency,
+			until_date, occurrences, status, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`
+	result, err := tx.Exec(query,
+		series.UserID, series.CourtID, series.StartTime.UTC(), series.EndTime.UTC(),
+		series.Frequency, nullTime(series.Until), series.Count, series.Status,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	series.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	var bookings []*Booking
+	var conflicts []SeriesConflict
+	for _, start := range starts {
+		booking := &Booking{
+			CourtID:     series.CourtID,
+			UserID:      series.UserID,
+			StartTime:   start,
+			EndTime:     start.Add(duration),
+			Status:      status,
+			BookingType: BookingTypeRegular,
+			SeriesID:    series.ID,
+		}
+
+		err := createBookingWithPolicy(tx, booking, policy)
+		var policyErr *PolicyError
+		switch {
+		case err == nil:
+			bookings = append(bookings, booking)
+		case errors.As(err, &policyErr), errors.Is(err, ErrCourtUnavailable):
+			conflicts = append(conflicts, SeriesConflict{
+				StartTime: booking.StartTime,
+				EndTime:   booking.EndTime,
+				Reason:    err.Error(),
+			})
+		default:
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	if len(bookings) == 0 {
+		tx.Rollback()
+		return nil, conflicts, errors.New("no occurrence of the series could be booked")
+	}
+
+	var recorded []events.Event
+	for _, booking := range bookings {
+		event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		recorded = append(recorded, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	publish(recorded...)
+	return bookings, conflicts, nil
+}
+
+// GetBookingSeriesByID retrieves a club's series by its ID
+func GetBookingSeriesByID(db *sql.DB, clubID int64, id interface{}) (*BookingSeries, error) {
+	var seriesID int64
+	switch v := id.(type) {
+	case int64:
+		seriesID = v
+	case string:
+		var err error
+		seriesID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	series := &BookingSeries{}
+	var until sql.NullTime
+	var count sql.NullInt64
+	query := `
+		SELECT
+			s.id, s.user_id, s.court_id, s.start_time, s.end_time, s.frequency,
+			s.until_date, s.occurrences, s.status, s.created_at,
+			c.name as court_name, u.username as user_name
+		FROM booking_series s
+		JOIN courts c ON s.court_id = c.id
+		JOIN users u ON s.user_id = u.id
+		WHERE s.id = ? AND ` + inClub("(SELECT f.club_id FROM facilities f WHERE f.id = c.facility_id)") + `
+	`
+	err := db.QueryRow(query, seriesID, clubID, clubID).Scan(
+		&series.ID, &series.UserID, &series.CourtID, &series.StartTime,
+		&series.EndTime, &series.Frequency, &until, &count,
+		&series.Status, &series.CreatedAt,
+		&series.CourtName, &series.UserName,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("booking series not found")
+		}
+		return nil, err
+	}
+	series.Until = until.Time
+	series.Count = int(count.Int64)
+	return series, nil
+}
+
+// GetSeriesBookings retrieves every occurrence booked for a series
+func GetSeriesBookings(db *sql.DB, seriesID int64) ([]*Booking, error) {
+	query := bookingSelect + `
+		WHERE b.series_id = ?
+		ORDER BY b.start_time ASC
+	`
+	return executeBookingQuery(db, query, seriesID)
+}
+
+// CancelSeriesFrom cancels every remaining occurrence of a series that starts
+// at or after from. The series itself is marked cancelled so that it no
+// longer shows as active.
+func CancelSeriesFrom(db *sql.DB, seriesID int64, from time.Time, cancelledBy int64) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Only pending and confirmed occurrences can still be cancelled. They
+	// are loaded first so the freed slots can be announced.
+	affected, err := executeBookingQuery(tx, bookingSelect+`
+		WHERE b.series_id = ? AND b.status IN (?, ?)
+		AND julianday(b.start_time) >= julianday(?)
+	`, seriesID, BookingStatusPending, BookingStatusConfirmed, from.UTC())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO booking_status_history (
+			booking_id, from_status, to_status, changed_by, reason, changed_at
+		)
+		SELECT id, status, ?, ?, 'series cancelled', ?
+		FROM bookings
+		WHERE series_id = ? AND status IN (?, ?)
+		AND julianday(start_time) >= julianday(?)
+	`, BookingStatusCancelled, cancelledBy, time.Now().UTC(),
+		seriesID, BookingStatusPending, BookingStatusConfirmed, from.UTC())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE bookings SET status = ?
+		WHERE series_id = ? AND status IN (?, ?)
+		AND julianday(start_time) >= julianday(?)
+	`, BookingStatusCancelled, seriesID, BookingStatusPending, BookingStatusConfirmed, from.UTC())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE booking_series SET status = ? WHERE id = ?`, SeriesStatusCancelled, seriesID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var recorded []events.Event
+	for _, booking := range affected {
+		event, err := recordBookingEvent(tx, events.BookingCancelled, booking.ID, nil)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		recorded = append(recorded, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	publish(recorded...)
+	return cancelled, nil
+}
+
+// nullTime maps a zero time to SQL NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesOccurrencesKeepWallClockTimeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// A 7 PM league night starting in daylight time, sent as a fixed
+	// UTC offset the way clients send RFC 3339 times
+	start, err := time.Parse(time.RFC3339, "2026-10-20T19:00:00-04:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := &BookingSeries{
+		StartTime: start,
+		EndTime:   start.Add(2 * time.Hour),
+		Frequency: SeriesFrequencyWeekly,
+		Count:     4,
+	}
+
+	starts, err := series.Occurrences(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 4 {
+		t.Fatalf("got %d occurrences, want 4", len(starts))
+	}
+	for _, start := range starts {
+		local := start.In(loc)
+		if local.Hour() != 19 || local.Minute() != 0 {
+			t.Errorf("occurrence on %s starts at %s, want 19:00", local.Format("Jan 2"), local.Format("15:04"))
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// BookingTransfer hands a booking from one player to another. The booking
+// only changes hands once the recipient accepts.
+type BookingTransfer struct {
+	ID          int64
+	BookingID   int64
+	FromUserID  int64
+	ToUserID    int64
+	Status      string
+	CreatedAt   time.Time
+	RespondedAt time.Time // zero while pending
+
+	// Additional fields for joins
+	FromUserName string
+	ToUserName   string
+	CourtName    string
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+const (
+	TransferStatusPending   = "pending"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusDeclined  = "declined"
+	TransferStatusCancelled = "cancelled"
+)
+
+var (
+	ErrTransferPending    = errors.New("booking already has a pending transfer")
+	ErrTransferNotPending = errors.New("transfer is no longer pending")
+	ErrInvalidRecipient   = errors.New("bookings can only be transferred to another player")
+)
+
+// column order expected by scanBookingTransfer
+const transferSelect = `
+		SELECT
+			t.id, t.booking_id, t.from_user_id, t.to_user_id, t.status,
+			t.created_at, t.responded_at,
+			fu.username as from_user_name, tu.username as to_user_name,
+			c.name as court_name, b.start_time, b.end_time
+		FROM booking_transfers t
+		JOIN users fu ON t.from_user_id = fu.id
+		JOIN users tu ON t.to_user_id = tu.id
+		JOIN bookings b ON t.booking_id = b.id
+		JOIN courts c ON b.court_id = c.id
+`
+
+// RequestBookingTransfer offers a booking to another player
+func RequestBookingTransfer(db *sql.DB, bookingID, toUserID int64) (*BookingTransfer, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := requestBookingTransfer(tx, bookingID, toUserID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func requestBookingTransfer(tx *sql.Tx, bookingID, toUserID int64, now time.Time) (*BookingTransfer, error) {
+	booking, err := getBooking(tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkChangeable(booking, now); err != nil {
+		return nil, err
+	}
+
+	var role string
+	err = tx.QueryRow(`SELECT role FROM users WHERE id = ?`, toUserID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	if role != RolePlayer || toUserID == booking.UserID {
+		return nil, ErrInvalidRecipient
+	}
+	if err := checkSameClubUsers(tx, booking.UserID, toUserID); err != nil {
+		return nil, err
+	}
+
+	var pending bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM booking_transfers WHERE booking_id = ? AND status = ?)
+	`, bookingID, TransferStatusPending).Scan(&pending)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrTransferPending
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO booking_transfers (booking_id, from_user_id, to_user_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, bookingID, booking.UserID, toUserID, TransferStatusPending, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	transferID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordBookingAudit(tx, &BookingAuditEntry{
+		BookingID: bookingID,
+		Action:    AuditActionTransferRequested,
+		ChangedBy: booking.UserID,
+		OldUserID: booking.UserID,
+		NewUserID: toUserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getBookingTransfer(tx, transferID)
+}
+
+// AcceptBookingTransfer hands the booking to the recipient. The recipient
+// must be allowed to hold the booking under the booking policy.
+func AcceptBookingTransfer(db *sql.DB, transferID int64, now time.Time) (*Booking, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := getBookingTransfer(tx, transferID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if transfer.Status != TransferStatusPending {
+		tx.Rollback()
+		return nil, ErrTransferNotPending
+	}
+
+	booking, err := getBooking(tx, transfer.BookingID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if booking.UserID != transfer.FromUserID {
+		tx.Rollback()
+		return nil, ErrTransferNotPending
+	}
+	if err := checkChangeable(booking, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking.UserID = transfer.ToUserID
+	policy, err := bookingPolicyFor(tx, booking)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := policy.Check(tx, booking, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE bookings SET user_id = ? WHERE id = ?`, transfer.ToUserID, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE booking_transfers SET status = ?, responded_at = ? WHERE id = ?
+	`, TransferStatusAccepted, now.UTC(), transferID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = recordBookingAudit(tx, &BookingAuditEntry{
+		BookingID: booking.ID,
+		Action:    AuditActionTransferAccepted,
+		ChangedBy: transfer.ToUserID,
+		OldUserID: transfer.FromUserID,
+		NewUserID: transfer.ToUserID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking, err = getBooking(tx, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// DeclineBookingTransfer lets the recipient turn a transfer down
+func DeclineBookingTransfer(db *sql.DB, transferID, userID int64) error {
+	return closeBookingTransfer(db, transferID, TransferStatusDeclined, AuditActionTransferDeclined, userID)
+}
+
+// CancelBookingTransfer lets the booker withdraw a transfer
+func CancelBookingTransfer(db *sql.DB, transferID, userID int64) error {
+	return closeBookingTransfer(db, transferID, TransferStatusCancelled, AuditActionTransferCancelled, userID)
+}
+
+// closeBookingTransfer ends a pending transfer without moving the booking
+func closeBookingTransfer(db *sql.DB, transferID int64, status, action string, changedBy int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	transfer, err := getBookingTransfer(tx, transferID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if transfer.Status != TransferStatusPending {
+		tx.Rollback()
+		return ErrTransferNotPending
+	}
+
+	_, err = tx.Exec(`
+		UPDATE booking_transfers SET status = ?, responded_at = ? WHERE id = ?
+	`, status, time.Now().UTC(), transferID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordBookingAudit(tx, &BookingAuditEntry{
+		BookingID: transfer.BookingID,
+		Action:    action,
+		ChangedBy: changedBy,
+		OldUserID: transfer.FromUserID,
+		NewUserID: transfer.ToUserID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetBookingTransferByID retrieves a transfer by its ID
+func GetBookingTransferByID(db *sql.DB, id interface{}) (*BookingTransfer, error) {
+	var transferID int64
+	switch v := id.(type) {
+	case int64:
+		transferID = v
+	case string:
+		var err error
+		transferID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getBookingTransfer(db, transferID)
+}
+
+func getBookingTransfer(q Querier, transferID int64) (*BookingTransfer, error) {
+	transfer := &BookingTransfer{}
+	err := scanBookingTransfer(q.QueryRow(transferSelect+` WHERE t.id = ?`, transferID), transfer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("transfer not found")
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetPendingTransfers retrieves pending transfers sent to or from a player
+func GetPendingTransfers(db *sql.DB, userID int64) ([]*BookingTransfer, error) {
+	query := transferSelect + `
+		WHERE t.status = ? AND (t.to_user_id = ? OR t.from_user_id = ?)
+		ORDER BY b.start_time ASC
+	`
+	rows, err := db.Query(query, TransferStatusPending, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []*BookingTransfer
+	for rows.Next() {
+		transfer := &BookingTransfer{}
+		if err := scanBookingTransfer(rows, transfer); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+// scanBookingTransfer scans a row selected with transferSelect
+func scanBookingTransfer(row rowScanner, transfer *BookingTransfer) error {
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&transfer.ID, &transfer.BookingID, &transfer.FromUserID, &transfer.ToUserID,
+		&transfer.Status, &transfer.CreatedAt, &respondedAt,
+		&transfer.FromUserName, &transfer.ToUserName,
+		&transfer.CourtName, &transfer.StartTime, &transfer.EndTime,
+	)
+	if err != nil {
+		return err
+	}
+	transfer.RespondedAt = respondedAt.Time
+	return nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"strconv"
 	"time"
@@ -9,33 +10,54 @@ import (
 )
 
 type User struct {
-	ID        int64
-	Username  string
-	Password  string
-	Email     string
-	Role      string
-	CreatedAt time.Time
+	ID         int64
+	Username   string
+	Password   string
+	Email      string
+	Role       string
+	Trusted    bool    // trusted members have their bookings confirmed automatically
+	SkillLevel float64 // club rating, 0 when unrated
+	ClubID     int64   // club the user belongs to, 0 for super admins
+	FacilityID int64   // facility an admin or staff member works at, 0 for club-wide
+	CreatedAt  time.Time
 }
 
+const userColumns = `id, username, password, email, role, trusted, skill_level, COALESCE(club_id, 0), COALESCE(facility_id, 0), created_at`
+
 const (
-	RoleAdmin  = "admin"
-	RoleCoach  = "coach"
-	RolePlayer = "player"
+	RoleAdmin      = "admin"
+	RoleCoach      = "coach"
+	RolePlayer     = "player"
+	RoleStaff      = "staff"      // front-desk staff who check players in
+	RoleSuperAdmin = "superadmin" // manages the clubs hosted on the deployment
 )
 
-// CreateUser creates a new user in the database
+// ErrFacilityNotInClub is returned when a user is assigned to a facility
+// of another club
+var ErrFacilityNotInClub = errors.New("facility does not belong to the user's club")
+
+// CreateUser creates a new user in the database. Everyone but super admins
+// must belong to a club.
 func CreateUser(db *sql.DB, user *User) error {
+	if (user.ClubID == 0) != (user.Role == RoleSuperAdmin) {
+		return ErrOtherClub
+	}
+	if err := checkFacilityClub(db, user.FacilityID, user.ClubID); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
 	query := `
-		INSERT INTO users (username, password, email, role, created_at)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO users (username, password, email, role, club_id, facility_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`
 
-	result, err := db.Exec(query, user.Username, string(hashedPassword), user.Email, user.Role)
+	result, err := db.Exec(query, user.Username, string(hashedPassword), user.Email, user.Role,
+		nullInt64(user.ClubID), nullInt64(user.FacilityID))
 	if err != nil {
 		return err
 	}
@@ -49,11 +71,12 @@ func CreateUser(db *sql.DB, user *User) error {
 	return nil
 }
 
-// GetUserByID retrieves a user by their ID
-func GetUserByID(db *sql.DB, id int64) (*User, error) {
+// GetUserByID retrieves a club's user by their ID. Super admins belong to no
+// club and are only found with AnyClub.
+func GetUserByID(db *sql.DB, clubID, id int64) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, password, email, role, created_at FROM users WHERE id = ?`
-	err := db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.CreatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ? AND ` + inClub("club_id")
+	err := scanUser(db.QueryRow(query, id, clubID, clubID), user)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -66,8 +89,8 @@ func GetUserByID(db *sql.DB, id int64) (*User, error) {
 // GetUserByUsername retrieves a user by their username
 func GetUserByUsername(db *sql.DB, username string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, password, email, role, created_at FROM users WHERE username = ?`
-	err := db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.CreatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	err := scanUser(db.QueryRow(query, username), user)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -77,12 +100,16 @@ func GetUserByUsername(db *sql.DB, username string) (*User, error) {
 	return user, nil
 }
 
-// AuthenticateUser verifies user credentials and returns the user if valid
-func AuthenticateUser(db *sql.DB, username, password string) (*User, error) {
+// AuthenticateUser verifies user credentials and returns the user if valid.
+// Users can only sign in to their own club; super admins to any.
+func AuthenticateUser(db *sql.DB, clubID int64, username, password string) (*User, error) {
 	user, err := GetUserByUsername(db, username)
 	if err != nil {
 		return nil, err
 	}
+	if !user.InClub(clubID) {
+		return nil, errors.New("user not found")
+	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
@@ -92,14 +119,30 @@ func AuthenticateUser(db *sql.DB, username, password string) (*User, error) {
 	return user, nil
 }
 
-// UpdateUser updates user information
+// UpdateUser updates user information. A user's club never changes.
 func UpdateUser(db *sql.DB, user *User) error {
+	var clubID int64
+	err := db.QueryRow(`SELECT COALESCE(club_id, 0) FROM users WHERE id = ?`, user.ID).Scan(&clubID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	if (clubID == 0) != (user.Role == RoleSuperAdmin) {
+		return ErrOtherClub
+	}
+	if err := checkFacilityClub(db, user.FacilityID, clubID); err != nil {
+		return err
+	}
+	user.ClubID = clubID
+
 	query := `
 		UPDATE users 
-		SET username = ?, email = ?, role = ?
+		SET username = ?, email = ?, role = ?, trusted = ?, skill_level = ?, facility_id = ?
 		WHERE id = ?
 	`
-	_, err := db.Exec(query, user.Username, user.Email, user.Role, user.ID)
+	_, err = db.Exec(query, user.Username, user.Email, user.Role, user.Trusted, user.SkillLevel, nullInt64(user.FacilityID), user.ID)
 	return err
 }
 
@@ -136,10 +179,21 @@ func DeleteUser(db *sql.DB, id interface{}) error {
 	return err
 }
 
-// GetAllUsers retrieves all users from the database
-func GetAllUsers(db *sql.DB) ([]*User, error) {
-	query := `SELECT id, username, password, email, role, created_at FROM users`
-	rows, err := db.Query(query)
+// GetAllUsers retrieves all users of a club
+func GetAllUsers(db *sql.DB, clubID int64) ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE club_id = ?`
+	return executeUserQuery(db, query, clubID)
+}
+
+// GetUsersByRole retrieves all users of a club with a specific role
+func GetUsersByRole(db *sql.DB, clubID int64, role string) ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE club_id = ? AND role = ?`
+	return executeUserQuery(db, query, clubID, role)
+}
+
+// Helper function to execute user queries
+func executeUserQuery(db *sql.DB, query string, args ...interface{}) ([]*User, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -148,8 +202,7 @@ func GetAllUsers(db *sql.DB) ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		user := &User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.CreatedAt)
-		if err != nil {
+		if err := scanUser(rows, user); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -157,23 +210,52 @@ func GetAllUsers(db *sql.DB) ([]*User, error) {
 	return users, nil
 }
 
-// GetUsersByRole retrieves all users with a specific role
-func GetUsersByRole(db *sql.DB, role string) ([]*User, error) {
-	query := `SELECT id, username, password, email, role, created_at FROM users WHERE role = ?`
-	rows, err := db.Query(query, role)
-	if err != nil {
-		return nil, err
+// MarshalJSON leaves the password hash out of JSON responses. Binding a
+// password from a request still works.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return json.Marshal(struct {
+		user
+		Password string `json:",omitempty"`
+	}{user: user(u)})
+}
+
+// ManagesFacility reports whether the user administers a facility. Admins
+// without a facility manage every one.
+func (u *User) ManagesFacility(facilityID int64) bool {
+	return u.Role == RoleAdmin && (u.FacilityID == 0 || u.FacilityID == facilityID)
+}
+
+// IsClubAdmin reports whether the user is an admin not tied to a facility
+func (u *User) IsClubAdmin() bool {
+	return u.Role == RoleAdmin && u.FacilityID == 0
+}
+
+// InClub reports whether the user may act in a club: their own, or any
+// club for super admins
+func (u *User) InClub(clubID int64) bool {
+	return u.Role == RoleSuperAdmin || u.ClubID == clubID
+}
+
+// checkFacilityClub returns ErrFacilityNotInClub unless a user's facility,
+// if any, belongs to their club
+func checkFacilityClub(q Querier, facilityID, clubID int64) error {
+	if facilityID == 0 {
+		return nil
 	}
-	defer rows.Close()
 
-	var users []*User
-	for rows.Next() {
-		user := &User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+	var facilityClubID int64
+	err := q.QueryRow(`SELECT COALESCE(club_id, 0) FROM facilities WHERE id = ?`, facilityID).Scan(&facilityClubID)
+	if err == sql.ErrNoRows || (err == nil && facilityClubID != clubID) {
+		return ErrFacilityNotInClub
 	}
-	return users, nil
+	return err
+}
+
+// scanUser scans a row selected with userColumns into user
+func scanUser(row rowScanner, user *User) error {
+	return row.Scan(
+		&user.ID, &user.Username, &user.Password, &user.Email,
+		&user.Role, &user.Trusted, &user.SkillLevel, &user.ClubID, &user.FacilityID, &user.CreatedAt,
+	)
 }
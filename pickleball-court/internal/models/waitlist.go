@@ -0,0 +1,386 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/events"
+)
+
+// WaitlistEntry is a player waiting for a court and time range to free up.
+// A zero CourtID means any court will do.
+type WaitlistEntry struct {
+	ID             int64
+	UserID         int64
+	CourtID        int64
+	StartTime      time.Time
+	EndTime        time.Time
+	Status         string
+	OfferedCourtID int64     // court held for the player while offered
+	OfferedAt      time.Time // zero until the entry is offered a slot
+	HoldExpiresAt  time.Time
+	BookingID      int64 // booking created when the hold is claimed
+	CreatedAt      time.Time
+
+	// Additional fields for joins
+	UserName         string
+	CourtName        string
+	OfferedCourtName string
+}
+
+const (
+	WaitlistStatusWaiting = "waiting"
+	WaitlistStatusOffered = "offered"
+	WaitlistStatusClaimed = "claimed"
+	WaitlistStatusExpired = "expired"
+	WaitlistStatusLeft    = "left"
+)
+
+var (
+	ErrAlreadyWaitlisted = errors.New("already on the waitlist for this time")
+	ErrNoWaitlistHold    = errors.New("no slot is being held for this waitlist entry")
+	ErrWaitlistHoldGone  = errors.New("the hold on this slot has expired")
+)
+
+// column order expected by scanWaitlistEntry
+const waitlistSelect = `
+		SELECT
+			w.id, w.user_id, w.court_id, w.start_time, w.end_time, w.status,
+			w.offered_court_id, w.offered_at, w.hold_expires_at, w.booking_id, w.created_at,
+			u.username as user_name,
+			COALESCE(c.name, '') as court_name,
+			COALESCE(oc.name, '') as offered_court_name
+		FROM waitlist_entries w
+		JOIN users u ON w.user_id = u.id
+		LEFT JOIN courts c ON w.court_id = c.id
+		LEFT JOIN courts oc ON w.offered_court_id = oc.id
+`
+
+// JoinWaitlist adds a player to the waitlist for a court and time range
+func JoinWaitlist(db *sql.DB, entry *WaitlistEntry) error {
+	if !entry.EndTime.After(entry.StartTime) {
+		return errors.New("end time must be after start time")
+	}
+	if !entry.StartTime.After(time.Now()) {
+		return errors.New("cannot join the waitlist for a time in the past")
+	}
+
+	if entry.CourtID != 0 {
+		if err := checkSameClub(db, entry.UserID, entry.CourtID); err != nil {
+			return err
+		}
+	}
+
+	entry.StartTime = entry.StartTime.UTC()
+	entry.EndTime = entry.EndTime.UTC()
+
+	// A player may only wait once for the same court and time
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM waitlist_entries
+			WHERE user_id = ? AND COALESCE(court_id, 0) = ?
+			AND julianday(start_time) = julianday(?) AND julianday(end_time) = julianday(?)
+			AND status IN (?, ?)
+		)
+	`, entry.UserID, entry.CourtID, entry.StartTime, entry.EndTime,
+		WaitlistStatusWaiting, WaitlistStatusOffered).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAlreadyWaitlisted
+	}
+
+	entry.Status = WaitlistStatusWaiting
+	result, err := db.Exec(`
+		INSERT INTO waitlist_entries (user_id, court_id, start_time, end_time, status, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, entry.UserID, nullInt64(entry.CourtID), entry.StartTime, entry.EndTime, entry.Status)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetWaitlistEntryByID retrieves a waitlist entry by its ID
+func GetWaitlistEntryByID(db *sql.DB, id interface{}) (*WaitlistEntry, error) {
+	var entryID int64
+	switch v := id.(type) {
+	case int64:
+		entryID = v
+	case string:
+		var err error
+		entryID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	return getWaitlistEntry(db, entryID)
+}
+
+func getWaitlistEntry(q Querier, entryID int64) (*WaitlistEntry, error) {
+	entry := &WaitlistEntry{}
+	err := scanWaitlistEntry(q.QueryRow(waitlistSelect+` WHERE w.id = ?`, entryID), entry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("waitlist entry not found")
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetUserWaitlist retrieves a player's active waitlist entries, soonest first
+func GetUserWaitlist(db *sql.DB, userID int64) ([]*WaitlistEntry, error) {
+	query := waitlistSelect + `
+		WHERE w.user_id = ? AND w.status IN (?, ?)
+		ORDER BY w.start_time ASC
+	`
+	return executeWaitlistQuery(db, query, userID, WaitlistStatusWaiting, WaitlistStatusOffered)
+}
+
+// LeaveWaitlist removes a player from the waitlist. If a slot was being held
+// for them it is passed on to the next player in line.
+func LeaveWaitlist(db *sql.DB, entryID int64, now time.Time) ([]*WaitlistEntry, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := getWaitlistEntry(tx, entryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if entry.Status != WaitlistStatusWaiting && entry.Status != WaitlistStatusOffered {
+		tx.Rollback()
+		return nil, errors.New("waitlist entry is no longer active")
+	}
+
+	_, err = tx.Exec(`UPDATE waitlist_entries SET status = ? WHERE id = ?`, WaitlistStatusLeft, entryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var offers []*WaitlistEntry
+	if entry.Status == WaitlistStatusOffered {
+		offers, err = offerFreedSlot(tx, entry.OfferedCourtID, entry.StartTime, entry.EndTime, now)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// ClaimWaitlistHold turns a held waitlist slot into a booking for the player.
+// The booking goes through the normal policy checks once the hold is released.
+func ClaimWaitlistHold(db *sql.DB, entryID int64, now time.Time) (*Booking, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := getWaitlistEntry(tx, entryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if entry.Status != WaitlistStatusOffered {
+		tx.Rollback()
+		return nil, ErrNoWaitlistHold
+	}
+	if !entry.HoldExpiresAt.After(now) {
+		tx.Rollback()
+		return nil, ErrWaitlistHoldGone
+	}
+
+	// Release the hold first so it does not block its own booking
+	_, err = tx.Exec(`UPDATE waitlist_entries SET status = ? WHERE id = ?`, WaitlistStatusClaimed, entryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking := &Booking{
+		CourtID:     entry.OfferedCourtID,
+		UserID:      entry.UserID,
+		StartTime:   entry.StartTime,
+		EndTime:     entry.EndTime,
+		Status:      BookingStatusPending,
+		BookingType: BookingTypeRegular,
+	}
+	if err := createBooking(tx, booking); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE waitlist_entries SET booking_id = ? WHERE id = ?`, booking.ID, entryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	event, err := recordBookingEvent(tx, events.BookingCreated, booking.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publish(event)
+	return booking, nil
+}
+
+// ExpireWaitlistHolds expires holds that were not claimed in time and passes
+// each freed slot on to the next player in line. It returns the new offers.
+func ExpireWaitlistHolds(db *sql.DB, now time.Time) ([]*WaitlistEntry, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := executeWaitlistQuery(tx, waitlistSelect+`
+		WHERE w.status = ? AND julianday(w.hold_expires_at) <= julianday(?)
+		ORDER BY w.hold_expires_at ASC
+	`, WaitlistStatusOffered, now.UTC())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var offers []*WaitlistEntry
+	for _, entry := range expired {
+		_, err := tx.Exec(`UPDATE waitlist_entries SET status = ? WHERE id = ?`, WaitlistStatusExpired, entry.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		next, err := offerFreedSlot(tx, entry.OfferedCourtID, entry.StartTime, entry.EndTime, now)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		offers = append(offers, next...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// offerFreedSlot offers a court that has just been freed between start and
+// end to waiting players, first come first served. Each player is offered
+// the part of the range they asked for, held for the configured hold time.
+func offerFreedSlot(tx *sql.Tx, courtID int64, start, end, now time.Time) ([]*WaitlistEntry, error) {
+	waiting, err := executeWaitlistQuery(tx, waitlistSelect+`
+		WHERE w.status = ? AND (w.court_id = ? OR (w.court_id IS NULL AND w.user_id IN (
+			SELECT id FROM users WHERE club_id = (`+courtClubQuery+`)
+		)))
+		AND julianday(w.start_time) >= julianday(?) AND julianday(w.end_time) <= julianday(?)
+		AND julianday(w.start_time) > julianday(?)
+		ORDER BY w.created_at ASC, w.id ASC
+	`, WaitlistStatusWaiting, courtID, courtID, start.UTC(), end.UTC(), now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	holdExpiresAt := now.Add(config.Get().GetWaitlistHoldDuration()).UTC()
+	policy, err := GetCourtPolicy(tx, courtID, BookingTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	var offers []*WaitlistEntry
+	for _, entry := range waiting {
+		available, err := isCourtAvailable(tx, courtID, entry.StartTime, entry.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			continue
+		}
+
+		// Slots freed by a maintenance window or blackout cannot be played
+		err = checkCourtSchedule(tx, policy, courtID, entry.StartTime, entry.EndTime)
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE waitlist_entries
+			SET status = ?, offered_court_id = ?, offered_at = ?, hold_expires_at = ?
+			WHERE id = ?
+		`, WaitlistStatusOffered, courtID, now.UTC(), holdExpiresAt, entry.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Status = WaitlistStatusOffered
+		entry.OfferedCourtID = courtID
+		entry.OfferedAt = now.UTC()
+		entry.HoldExpiresAt = holdExpiresAt
+		offers = append(offers, entry)
+	}
+	return offers, nil
+}
+
+// scanWaitlistEntry scans a row selected with waitlistSelect
+func scanWaitlistEntry(row rowScanner, entry *WaitlistEntry) error {
+	var courtID, offeredCourtID, bookingID sql.NullInt64
+	var offeredAt, holdExpiresAt sql.NullTime
+	err := row.Scan(
+		&entry.ID, &entry.UserID, &courtID, &entry.StartTime, &entry.EndTime, &entry.Status,
+		&offeredCourtID, &offeredAt, &holdExpiresAt, &bookingID, &entry.CreatedAt,
+		&entry.UserName, &entry.CourtName, &entry.OfferedCourtName,
+	)
+	if err != nil {
+		return err
+	}
+	entry.CourtID = courtID.Int64
+	entry.OfferedCourtID = offeredCourtID.Int64
+	entry.OfferedAt = offeredAt.Time
+	entry.HoldExpiresAt = holdExpiresAt.Time
+	entry.BookingID = bookingID.Int64
+	return nil
+}
+
+// executeWaitlistQuery runs a waitlistSelect query and scans every row
+func executeWaitlistQuery(q Querier, query string, args ...interface{}) ([]*WaitlistEntry, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*WaitlistEntry
+	for rows.Next() {
+		entry := &WaitlistEntry{}
+		if err := scanWaitlistEntry(rows, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
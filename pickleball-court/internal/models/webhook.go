@@ -0,0 +1,531 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook is an endpoint an admin registered to be told about events at
+// their club. Each delivery is signed with the webhook's secret.
+type Webhook struct {
+	ID         int64
+	ClubID     int64
+	URL        string
+	EventTypes []string
+	Secret     string
+	Active     bool
+	CreatedBy  int64
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is one event queued for a webhook, and the log of trying
+// to deliver it. Failed deliveries are retried until MaxWebhookAttempts is
+// reached.
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int
+	ResponseStatus int // HTTP status of the last attempt, 0 if none was received
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    time.Time // zero until delivered
+}
+
+// Events a webhook can subscribe to
+const (
+	WebhookBookingCreated    = "booking.created"
+	WebhookBookingUpdated    = "booking.updated"
+	WebhookBookingCancelled  = "booking.cancelled"
+	WebhookBookingMoved      = "booking.moved"
+	WebhookTrainingEnrolled  = "training.enrolled"
+	WebhookTrainingCancelled = "training.cancelled"
+	WebhookUserRegistered    = "user.registered"
+)
+
+// WebhookEventTypes lists the events a webhook can subscribe to
+var WebhookEventTypes = []string{
+	WebhookBookingCreated, WebhookBookingUpdated, WebhookBookingCancelled, WebhookBookingMoved,
+	WebhookTrainingEnrolled, WebhookTrainingCancelled, WebhookUserRegistered,
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // gave up after MaxWebhookAttempts
+)
+
+// WebhookEnvelope is the JSON body of every delivery
+type WebhookEnvelope struct {
+	Type      string      `json:"type"`
+	ClubID    int64       `json:"club_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookSlot is a court between two times
+type WebhookSlot struct {
+	CourtID   int64     `json:"court_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// WebhookBookingData is the data of a booking event. From is the slot a
+// moved booking left.
+type WebhookBookingData struct {
+	ID          int64        `json:"id"`
+	UserID      int64        `json:"user_id"`
+	CourtID     int64        `json:"court_id"`
+	CourtName   string       `json:"court_name"`
+	StartTime   time.Time    `json:"start_time"`
+	EndTime     time.Time    `json:"end_time"`
+	Status      string       `json:"status"`
+	BookingType string       `json:"booking_type"`
+	From        *WebhookSlot `json:"from,omitempty"`
+}
+
+// MaxWebhookAttempts is how many times delivery of an event is tried
+const MaxWebhookAttempts = 8
+
+var (
+	ErrInvalidWebhook  = errors.New("webhooks need an http or https URL and at least one known event type")
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+const webhookColumns = `id, club_id, url, event_types, secret, active, created_by, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_type, payload, status, attempts, response_status,
+	last_error, next_attempt_at, created_at, delivered_at`
+
+// CreateWebhook registers a webhook with a newly generated secret
+func CreateWebhook(db *sql.DB, webhook *Webhook) error {
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	webhook.Secret = secret
+
+	result, err := db.Exec(`
+		INSERT INTO webhooks (club_id, url, event_types, secret, active, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, webhook.ClubID, webhook.URL, strings.Join(webhook.EventTypes, ","), webhook.Secret,
+		webhook.Active, webhook.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	webhook.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetClubWebhooks retrieves every webhook registered at a club
+func GetClubWebhooks(db *sql.DB, clubID int64) ([]*Webhook, error) {
+	rows, err := db.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE club_id = ? ORDER BY id ASC`, clubID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		webhook := &Webhook{}
+		if err := scanWebhook(rows, webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhookByID retrieves a club's webhook by its ID
+func GetWebhookByID(db *sql.DB, clubID int64, id interface{}) (*Webhook, error) {
+	var webhookID int64
+	switch v := id.(type) {
+	case int64:
+		webhookID = v
+	case string:
+		var err error
+		webhookID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	webhook := &Webhook{}
+	err := scanWebhook(db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND `+inClub("club_id"),
+		webhookID, clubID, clubID), webhook)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook changes a webhook's URL, events and whether it is active.
+// Deliveries already queued for it keep their payloads.
+func UpdateWebhook(db *sql.DB, webhook *Webhook) error {
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		UPDATE webhooks SET url = ?, event_types = ?, active = ? WHERE id = ?
+	`, webhook.URL, strings.Join(webhook.EventTypes, ","), webhook.Active, webhook.ID)
+	return err
+}
+
+// RotateWebhookSecret gives a webhook a new secret. Deliveries from then
+// on, including retries, are signed with it.
+func RotateWebhookSecret(db *sql.DB, webhook *Webhook) error {
+	secret, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`UPDATE webhooks SET secret = ? WHERE id = ?`, secret, webhook.ID); err != nil {
+		return err
+	}
+	webhook.Secret = secret
+	return nil
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func DeleteWebhook(db *sql.DB, webhookID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, webhookID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, webhookID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		return ErrWebhookNotFound
+	}
+	return tx.Commit()
+}
+
+// QueueWebhookEvent queues a delivery of payload to every active webhook at
+// the club subscribed to eventType. It returns how many were queued.
+func QueueWebhookEvent(q Querier, clubID int64, eventType string, payload []byte, now time.Time) (int64, error) {
+	// event_types is a comma-separated list, so match it as one
+	result, err := q.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT id, ?, ?, ?, ?, CURRENT_TIMESTAMP
+		FROM webhooks
+		WHERE club_id = ? AND active = 1 AND ',' || event_types || ',' LIKE ?
+	`, eventType, string(payload), WebhookDeliveryPending, now.UTC(), clubID, "%,"+eventType+",%")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// queueBookingWebhooks queues a booking event for the club's webhooks
+// subscribed to it. The booking event types share their names with the
+// webhook events.
+func queueBookingWebhooks(q Querier, booking *Booking, eventType string, from *WebhookSlot, now time.Time) error {
+	payload, err := json.Marshal(WebhookEnvelope{
+		Type:      eventType,
+		ClubID:    booking.ClubID,
+		CreatedAt: now.UTC(),
+		Data: WebhookBookingData{
+			ID:          booking.ID,
+			UserID:      booking.UserID,
+			CourtID:     booking.CourtID,
+			CourtName:   booking.CourtName,
+			StartTime:   booking.StartTime,
+			EndTime:     booking.EndTime,
+			Status:      booking.Status,
+			BookingType: booking.BookingType,
+			From:        from,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = QueueWebhookEvent(q, booking.ClubID, eventType, payload, now)
+	return err
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries to active
+// webhooks whose next attempt is due, oldest first, with their webhooks
+func GetDueWebhookDeliveries(db *sql.DB, now time.Time, limit int) ([]*WebhookDelivery, map[int64]*Webhook, error) {
+	rows, err := db.Query(`
+		SELECT `+prefixColumns("d", webhookDeliveryColumns)+`, `+prefixColumns("w", webhookColumns)+`
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.status = ? AND w.active = 1 AND julianday(d.next_attempt_at) <= julianday(?)
+		ORDER BY d.next_attempt_at ASC, d.id ASC
+		LIMIT ?
+	`, WebhookDeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	webhooks := make(map[int64]*Webhook)
+	for rows.Next() {
+		delivery := &WebhookDelivery{}
+		webhook := &Webhook{}
+		var deliveredAt sql.NullTime
+		var eventTypes string
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+			&delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.NextAttemptAt,
+			&delivery.CreatedAt, &deliveredAt,
+			&webhook.ID, &webhook.ClubID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.Active,
+			&webhook.CreatedBy, &webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		delivery.DeliveredAt = deliveredAt.Time
+		webhook.EventTypes = splitEventTypes(eventTypes)
+		deliveries = append(deliveries, delivery)
+		webhooks[webhook.ID] = webhook
+	}
+	return deliveries, webhooks, rows.Err()
+}
+
+// GetWebhookDeliveries retrieves a webhook's most recent deliveries, up to
+// limit, newest first
+func GetWebhookDeliveries(db *sql.DB, webhookID int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.Query(`
+		SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		delivery := &WebhookDelivery{}
+		if err := scanWebhookDelivery(rows, delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDeliveryByID retrieves a delivery by its ID
+func GetWebhookDeliveryByID(db *sql.DB, id interface{}) (*WebhookDelivery, error) {
+	var deliveryID int64
+	switch v := id.(type) {
+	case int64:
+		deliveryID = v
+	case string:
+		var err error
+		deliveryID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid ID type")
+	}
+
+	delivery := &WebhookDelivery{}
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = ?`
+	if err := scanWebhookDelivery(db.QueryRow(query, deliveryID), delivery); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("delivery not found")
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// RedeliverWebhook queues a fresh delivery of an earlier delivery's
+// payload, due straight away. The original stays in the log as it was.
+func RedeliverWebhook(db *sql.DB, original *WebhookDelivery, now time.Time) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{
+		WebhookID:     original.WebhookID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now.UTC(),
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, delivery.WebhookID, delivery.EventType, delivery.Payload, delivery.Status, delivery.NextAttemptAt)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ClaimWebhookDelivery reserves a due delivery for one sender by moving its
+// next attempt to until. It reports false if the delivery was made, given
+// up on or claimed by another sender since it was loaded. A sender that
+// stops before marking the delivery leaves it to be tried again at until.
+func ClaimWebhookDelivery(db *sql.DB, delivery *WebhookDelivery, now, until time.Time) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND julianday(next_attempt_at) <= julianday(?)
+	`, until.UTC(), delivery.ID, WebhookDeliveryPending, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// MarkWebhookDelivered records that a delivery was accepted by its endpoint
+func MarkWebhookDelivered(db *sql.DB, delivery *WebhookDelivery, responseStatus int, now time.Time) error {
+	delivery.Attempts++
+	delivery.Status = WebhookDeliveryDelivered
+	delivery.ResponseStatus = responseStatus
+	delivery.LastError = ""
+	delivery.DeliveredAt = now.UTC()
+
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = '', delivered_at = ?
+		WHERE id = ?
+	`, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.DeliveredAt, delivery.ID)
+	return err
+}
+
+// MarkWebhookFailed records a failed delivery attempt. The delivery is
+// tried again at retryAt, or given up on once it has used
+// MaxWebhookAttempts.
+func MarkWebhookFailed(db *sql.DB, delivery *WebhookDelivery, responseStatus int, sendErr error, retryAt time.Time) error {
+	delivery.Attempts++
+	delivery.ResponseStatus = responseStatus
+	delivery.LastError = sendErr.Error()
+	delivery.NextAttemptAt = retryAt.UTC()
+	if delivery.Attempts >= MaxWebhookAttempts {
+		delivery.Status = WebhookDeliveryFailed
+	}
+
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError,
+		delivery.NextAttemptAt, delivery.ID)
+	return err
+}
+
+// PurgeOldWebhookDeliveries deletes deliveries that were delivered or given
+// up on before cutoff, returning how many went
+func PurgeOldWebhookDeliveries(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM webhook_deliveries
+		WHERE status != ? AND julianday(created_at) < julianday(?)
+	`, WebhookDeliveryPending, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// validateWebhook checks a webhook's URL and events, dropping duplicate
+// events
+func validateWebhook(webhook *Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrInvalidWebhook
+	}
+
+	seen := make(map[string]bool)
+	var eventTypes []string
+	for _, eventType := range webhook.EventTypes {
+		if !isWebhookEventType(eventType) {
+			return ErrInvalidWebhook
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	if len(eventTypes) == 0 {
+		return ErrInvalidWebhook
+	}
+	webhook.EventTypes = eventTypes
+	return nil
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, known := range WebhookEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+func splitEventTypes(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// prefixColumns qualifies each column in a comma-separated list with a
+// table alias
+func prefixColumns(alias, columns string) string {
+	fields := strings.Split(columns, ",")
+	for i, field := range fields {
+		fields[i] = alias + "." + strings.TrimSpace(field)
+	}
+	return strings.Join(fields, ", ")
+}
+
+func scanWebhook(row rowScanner, webhook *Webhook) error {
+	var eventTypes string
+	err := row.Scan(
+		&webhook.ID, &webhook.ClubID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.Active,
+		&webhook.CreatedBy, &webhook.CreatedAt,
+	)
+	webhook.EventTypes = splitEventTypes(eventTypes)
+	return err
+}
+
+func scanWebhookDelivery(row rowScanner, delivery *WebhookDelivery) error {
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.CreatedAt, &deliveredAt,
+	)
+	delivery.DeliveredAt = deliveredAt.Time
+	return err
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"pickleball-court/internal/events"
+	"testing"
+	"time"
+)
+
+func TestBookingWebhooksAreQueuedWithTheChange(t *testing.T) {
+	db := openTestDB(t)
+	court := createTestCourt(t, db, 1, "Court 1")
+	admin := createTestUser(t, db, 1, "admin", RoleAdmin)
+	player := createTestUser(t, db, 1, "player", RolePlayer)
+
+	webhook := &Webhook{
+		ClubID:     1,
+		URL:        "https://example.com/hooks",
+		EventTypes: []string{WebhookBookingCreated, WebhookBookingCancelled},
+		Active:     true,
+		CreatedBy:  admin.ID,
+	}
+	if err := CreateWebhook(db, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	start := tomorrowAt(10, 0)
+	booking := &Booking{
+		CourtID: court.ID, UserID: player.ID, StartTime: start, EndTime: start.Add(time.Hour),
+		Status: BookingStatusConfirmed, BookingType: BookingTypeRegular,
+	}
+	if err := CreateBooking(db, booking); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := GetWebhookDeliveries(db, webhook.ID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, err %v; want 1 for the new booking", len(deliveries), err)
+	}
+	var envelope struct {
+		Type string
+		Data WebhookBookingData
+	}
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Type != WebhookBookingCreated || envelope.Data.ID != booking.ID || envelope.Data.CourtName != court.Name {
+		t.Errorf("got %s for booking %d on %q, want %s for booking %d on %q", envelope.Type,
+			envelope.Data.ID, envelope.Data.CourtName, WebhookBookingCreated, booking.ID, court.Name)
+	}
+
+	// A change that is rolled back queues nothing
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recordBookingEvent(tx, events.BookingCancelled, booking.ID, nil); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if deliveries, err := GetWebhookDeliveries(db, webhook.ID, 10); err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries after a rollback, err %v; want 1", len(deliveries), err)
+	}
+
+	if _, err := CancelBooking(db, booking.ID, CancelOptions{CancelledBy: admin.ID, Override: true}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = GetWebhookDeliveries(db, webhook.ID, 10)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("got %d deliveries, err %v; want 2 after cancelling", len(deliveries), err)
+	}
+	if deliveries[0].EventType != WebhookBookingCancelled {
+		t.Errorf("got latest delivery %s, want %s", deliveries[0].EventType, WebhookBookingCancelled)
+	}
+}
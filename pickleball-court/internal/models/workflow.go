@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BookingStatusChange is one entry in a booking's status history
+type BookingStatusChange struct {
+	ID         int64
+	BookingID  int64
+	FromStatus string // empty for the initial status
+	ToStatus   string
+	ChangedBy  int64
+	Reason     string
+	ChangedAt  time.Time
+
+	// Additional fields for joins
+	ChangedByName string
+}
+
+// bookingTransitions lists the statuses each status may move to. Statuses
+// without an entry are final.
+var bookingTransitions = map[string][]string{
+	BookingStatusPending:   {BookingStatusConfirmed, BookingStatusRejected, BookingStatusCancelled},
+	BookingStatusConfirmed: {BookingStatusCompleted, BookingStatusNoShow, BookingStatusCancelled},
+}
+
+// ErrInvalidTransition is returned when a status change is not allowed
+var ErrInvalidTransition = errors.New("booking status change is not allowed")
+
+// IsValidBookingStatus reports whether status is a known booking status
+func IsValidBookingStatus(status string) bool {
+	switch status {
+	case BookingStatusPending, BookingStatusConfirmed, BookingStatusRejected,
+		BookingStatusCompleted, BookingStatusNoShow, BookingStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a booking may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range bookingTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionBooking moves a booking to a new status, recording who made the
+// change and why. Cancellations go through CancelBooking instead so that the
+// notice window and penalties apply.
+func TransitionBooking(db *sql.DB, id interface{}, to string, changedBy int64, reason string) error {
+	var bookingID int64
+	switch v := id.(type) {
+	case int64:
+		bookingID = v
+	case string:
+		var err error
+		bookingID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid ID type")
+	}
+
+	if to == BookingStatusCancelled {
+		return errors.New("use CancelBooking to cancel a booking")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := transitionBooking(tx, bookingID, to, changedBy, reason); err != nil {
+		tx.Rollback()
+		return err
+	}
+	event, err := recordBookingEvent(tx, statusEvent(to), bookingID, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publish(event)
+	return nil
+}
+
+// transitionBooking validates and applies a status change within tx
+func transitionBooking(tx *sql.Tx, bookingID int64, to string, changedBy int64, reason string) error {
+	var from string
+	err := tx.QueryRow(`SELECT status FROM bookings WHERE id = ?`, bookingID).Scan(&from)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("booking not found")
+		}
+		return err
+	}
+
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	_, err = tx.Exec(`UPDATE bookings SET status = ? WHERE id = ?`, to, bookingID)
+	if err != nil {
+		if isOverlapError(err) {
+			return ErrCourtUnavailable
+		}
+		return err
+	}
+
+	return recordStatusChange(tx, bookingID, from, to, changedBy, reason)
+}
+
+// recordStatusChange appends an entry to a booking's status history
+func recordStatusChange(q Querier, bookingID int64, from, to string, changedBy int64, reason string) error {
+	_, err := q.Exec(`
+		INSERT INTO booking_status_history (
+			booking_id, from_status, to_status, changed_by, reason, changed_at
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`, bookingID, from, to, changedBy, reason, time.Now().UTC())
+	return err
+}
+
+// GetBookingStatusHistory retrieves the status history of a booking, oldest first
+func GetBookingStatusHistory(db *sql.DB, bookingID int64) ([]*BookingStatusChange, error) {
+	query := `
+		SELECT
+			h.id, h.booking_id, h.from_status, h.to_status, h.changed_by,
+			h.reason, h.changed_at, COALESCE(u.username, '') as changed_by_name
+		FROM booking_status_history h
+		LEFT JOIN users u ON h.changed_by = u.id
+		WHERE h.booking_id = ?
+		ORDER BY h.changed_at ASC, h.id ASC
+	`
+	rows, err := db.Query(query, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*BookingStatusChange
+	for rows.Next() {
+		change := &BookingStatusChange{}
+		err := rows.Scan(
+			&change.ID, &change.BookingID, &change.FromStatus, &change.ToStatus,
+			&change.ChangedBy, &change.Reason, &change.ChangedAt, &change.ChangedByName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, nil
+}
+
+// GetBookingsByStatus retrieves a club's bookings with the given status,
+// soonest first
+func GetBookingsByStatus(db *sql.DB, clubID int64, status string) ([]*Booking, error) {
+	query := bookingSelect + `
+		WHERE f.club_id = ? AND b.status = ?
+		ORDER BY b.start_time ASC
+	`
+	return executeBookingQuery(db, query, clubID, status)
+}
+
+// shouldAutoConfirm reports whether a new booking skips approval because the
+// booker is a trusted member or the court confirms bookings automatically
+func shouldAutoConfirm(q Querier, userID, courtID int64) (bool, error) {
+	var trusted, autoConfirm bool
+	err := q.QueryRow(`
+		SELECT
+			COALESCE((SELECT trusted FROM users WHERE id = ?), 0),
+			COALESCE((SELECT auto_confirm FROM courts WHERE id = ?), 0)
+	`, userID, courtID).Scan(&trusted, &autoConfirm)
+	if err != nil {
+		return false, err
+	}
+	return trusted || autoConfirm, nil
+}
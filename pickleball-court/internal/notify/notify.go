@@ -0,0 +1,393 @@
+// Package notify tells players about their bookings, in their in-app inbox,
+// by email or both, as each player prefers. Notifications are stored by the
+// request that triggers them. Emails are queued in the database and
+// delivered in the background by Deliver, so that a slow or failing mail
+// server never holds up a request and nothing is lost on restart.
+package notify
+
+import (
+	"database/sql"
+	"log"
+	"pickleball-court/internal/email"
+	"pickleball-court/internal/models"
+	"time"
+)
+
+const (
+	startFormat = "Mon Jan 2, 2006 3:04 PM"
+	endFormat   = "3:04 PM MST"
+
+	// How many queued emails one delivery pass sends at most
+	deliveryBatch = 50
+
+	// Delay before the first retry of a failed email, doubled each attempt
+	retryBackoff = time.Minute
+
+	// How long a claimed email is left to its sender before another
+	// delivery pass may try it
+	claimTimeout = 5 * time.Minute
+)
+
+// BookingCreated tells the booker that their booking was received
+func BookingCreated(db *sql.DB, booking *models.Booking) {
+	notifyBooking(db, KindBookingCreated, booking, nil, "", nil)
+}
+
+// BookingConfirmed tells the booker that their booking was approved
+func BookingConfirmed(db *sql.DB, booking *models.Booking) {
+	notifyBooking(db, KindBookingConfirmed, booking, nil, "", nil)
+}
+
+// BookingCancelled tells the booker and the accepted participants among
+// participants that their booking was cancelled, with the reason if one
+// was given
+func BookingCancelled(db *sql.DB, booking *models.Booking, reason string, participants []*models.BookingParticipant) {
+	notifyBooking(db, KindBookingCancelled, booking, nil, reason, participants)
+}
+
+// BookingRescheduled tells the booker and the accepted participants among
+// participants that their booking moved from old to its current court and
+// time
+func BookingRescheduled(db *sql.DB, booking, old *models.Booking, reason string, participants []*models.BookingParticipant) {
+	notifyBooking(db, KindBookingRescheduled, booking, old, reason, participants)
+}
+
+// TrainingEnrolled tells a player that they joined a training session
+func TrainingEnrolled(db *sql.DB, userID int64, session *models.TrainingSession) {
+	notifyTraining(db, KindTrainingEnrolled, userID, session)
+}
+
+// TrainingCancelled tells the players enrolled in a training session that
+// its coach cancelled it
+func TrainingCancelled(db *sql.DB, userIDs []int64, session *models.TrainingSession) {
+	for _, userID := range userIDs {
+		notifyTraining(db, KindTrainingCancelled, userID, session)
+	}
+}
+
+// WaitlistOffer tells a waitlisted player that a slot is being held for them
+func WaitlistOffer(db *sql.DB, offer *models.WaitlistEntry) {
+	user, err := models.GetUserByID(db, models.AnyClub, offer.UserID)
+	if err != nil {
+		log.Printf("Notify: failed to load user %d: %v\n", offer.UserID, err)
+		return
+	}
+
+	courtName := offer.OfferedCourtName
+	loc := facilityLocation(db, 0)
+	if court, err := models.GetCourtByID(db, models.AnyClub, offer.OfferedCourtID); err == nil {
+		courtName = court.Name
+		loc = facilityLocation(db, court.FacilityID)
+	}
+
+	data := &messageData{
+		Name:    user.Username,
+		Club:    clubName(db, user.ClubID),
+		Court:   courtName,
+		Expires: offer.HoldExpiresAt.In(loc).Format(endFormat),
+	}
+	data.Start, data.End = formatTimes(offer.StartTime, offer.EndTime, loc)
+
+	if err := deliver(db, user, KindWaitlistOffer, data); err != nil {
+		log.Printf("Notify: failed to send %s to user %d: %v\n", KindWaitlistOffer, user.ID, err)
+	}
+}
+
+// PasswordReset emails a user the link for choosing a new password
+func PasswordReset(db *sql.DB, user *models.User, link string) {
+	data := &messageData{
+		Name: user.Username,
+		Club: clubName(db, user.ClubID),
+		Link: link,
+	}
+	if err := deliver(db, user, KindPasswordReset, data); err != nil {
+		log.Printf("Notify: failed to send %s to user %d: %v\n", KindPasswordReset, user.ID, err)
+	}
+}
+
+// SendBookingReminders reminds the booker of every active booking starting
+// within ahead of now that has not had a reminder, and returns how many
+// bookings were reminded
+func SendBookingReminders(db *sql.DB, now time.Time, ahead time.Duration) (int, error) {
+	bookings, err := models.GetBookingsDueReminder(db, now, ahead)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, booking := range bookings {
+		user, err := models.GetUserByID(db, models.AnyClub, booking.UserID)
+		if err != nil {
+			return sent, err
+		}
+		data := bookingData(db, user, booking, nil, "")
+
+		// Send the reminder and mark it sent together, so that a booking is
+		// never reminded twice or not at all
+		tx, err := db.Begin()
+		if err != nil {
+			return sent, err
+		}
+		if err := deliver(tx, user, KindBookingReminder, data); err != nil {
+			tx.Rollback()
+			return sent, err
+		}
+		if err := models.MarkReminderSent(tx, booking.ID, now); err != nil {
+			tx.Rollback()
+			return sent, err
+		}
+		if err := tx.Commit(); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// SendTrainingReminders reminds every player enrolled in a training session
+// starting within ahead of now who has not had a reminder, and returns how
+// many were reminded
+func SendTrainingReminders(db *sql.DB, now time.Time, ahead time.Duration) (int, error) {
+	enrollments, err := models.GetTrainingEnrollmentsDueReminder(db, now, ahead)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, enrollment := range enrollments {
+		user, err := models.GetUserByID(db, models.AnyClub, enrollment.UserID)
+		if err != nil {
+			return sent, err
+		}
+		session, err := models.GetTrainingSessionByID(db, models.AnyClub, enrollment.SessionID)
+		if err != nil {
+			return sent, err
+		}
+		data := trainingData(db, user, session)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return sent, err
+		}
+		if err := deliver(tx, user, KindTrainingReminder, data); err != nil {
+			tx.Rollback()
+			return sent, err
+		}
+		if err := models.MarkTrainingReminderSent(tx, session.ID, user.ID, now); err != nil {
+			tx.Rollback()
+			return sent, err
+		}
+		if err := tx.Commit(); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Deliver sends the queued emails that are due. Failed emails are retried
+// with exponential backoff until models.MaxEmailAttempts is reached. It
+// returns how many emails were sent.
+func Deliver(db *sql.DB, sender email.Sender, now time.Time) (int, error) {
+	emails, err := models.GetDueEmails(db, now, deliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, queued := range emails {
+		// Another instance may be working through the same batch
+		claimed, err := models.ClaimEmail(db, queued, now, now.Add(claimTimeout))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		err = sender.Send(email.Message{To: queued.Recipient, Subject: queued.Subject, Body: queued.Body})
+		if err != nil {
+			retryAt := now.Add(retryBackoff << uint(queued.Attempts))
+			if err := models.MarkEmailFailed(db, queued, err, retryAt); err != nil {
+				return sent, err
+			}
+			if queued.Status == models.EmailStatusFailed {
+				log.Printf("Email: giving up on email %d to %s: %s\n", queued.ID, queued.Recipient, queued.LastError)
+			}
+			continue
+		}
+		if err := models.MarkEmailSent(db, queued.ID, now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// deliver renders a notification for user and puts it in their inbox, the
+// email outbox or both, as their preferences for its kind say. Password
+// resets, and guests, who have no account and so a zero ID, always go by
+// email alone.
+func deliver(q models.Querier, user *models.User, kind string, data *messageData) error {
+	preference := &models.NotificationPreference{Kind: kind, Email: true}
+	if kind != KindPasswordReset && user.ID != 0 {
+		var err error
+		preference, err = models.GetNotificationPreference(q, user.ID, kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	subject, text, err := render(kind, data)
+	if err != nil {
+		return err
+	}
+
+	if preference.Inbox {
+		err := models.CreateNotification(q, &models.Notification{
+			UserID: user.ID,
+			Kind:   kind,
+			Title:  subject,
+			Body:   text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if preference.Email && user.Email != "" {
+		body, err := emailBody(data, text)
+		if err != nil {
+			return err
+		}
+		err = models.QueueEmail(q, &models.QueuedEmail{Recipient: user.Email, Subject: subject, Body: body})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyBooking notifies the booker and the accepted participants about a
+// booking, logging rather than returning failures so that the booking
+// itself still succeeds. The booking is reloaded for its court and
+// facility, which callers that just created or changed it may not have
+// filled in.
+func notifyBooking(db *sql.DB, kind string, booking, old *models.Booking, reason string, participants []*models.BookingParticipant) {
+	booking, err := models.GetBookingByID(db, models.AnyClub, booking.ID)
+	if err != nil {
+		log.Printf("Notify: failed to load booking: %v\n", err)
+		return
+	}
+
+	var recipients []*models.User
+	user, err := models.GetUserByID(db, models.AnyClub, booking.UserID)
+	if err != nil {
+		log.Printf("Notify: failed to load user %d: %v\n", booking.UserID, err)
+	} else {
+		recipients = append(recipients, user)
+	}
+	for _, participant := range participants {
+		if participant.Status != models.ParticipantStatusAccepted || participant.UserID == booking.UserID {
+			continue
+		}
+		if participant.UserID == 0 {
+			recipients = append(recipients, &models.User{
+				Username: participant.GuestName,
+				Email:    participant.GuestEmail,
+				ClubID:   booking.ClubID,
+			})
+			continue
+		}
+		user, err := models.GetUserByID(db, models.AnyClub, participant.UserID)
+		if err != nil {
+			log.Printf("Notify: failed to load user %d: %v\n", participant.UserID, err)
+			continue
+		}
+		recipients = append(recipients, user)
+	}
+
+	for _, user := range recipients {
+		data := bookingData(db, user, booking, old, reason)
+		if err := deliver(db, user, kind, data); err != nil {
+			log.Printf("Notify: failed to send %s for booking %d: %v\n", kind, booking.ID, err)
+		}
+	}
+}
+
+// notifyTraining notifies a player about a training session, logging
+// failures
+func notifyTraining(db *sql.DB, kind string, userID int64, session *models.TrainingSession) {
+	user, err := models.GetUserByID(db, models.AnyClub, userID)
+	if err != nil {
+		log.Printf("Notify: failed to load user %d: %v\n", userID, err)
+		return
+	}
+	data := trainingData(db, user, session)
+	if err := deliver(db, user, kind, data); err != nil {
+		log.Printf("Notify: failed to send %s to user %d: %v\n", kind, user.ID, err)
+	}
+}
+
+// bookingData fills in the templates for a notification about a booking
+func bookingData(db *sql.DB, user *models.User, booking, old *models.Booking, reason string) *messageData {
+	loc := facilityLocation(db, booking.FacilityID)
+	data := &messageData{
+		Name:   user.Username,
+		Club:   clubName(db, user.ClubID),
+		Court:  booking.CourtName,
+		Status: booking.Status,
+		Reason: reason,
+	}
+	data.Start, data.End = formatTimes(booking.StartTime, booking.EndTime, loc)
+	if old != nil {
+		data.OldCourt = old.CourtName
+		data.OldStart, data.OldEnd = formatTimes(old.StartTime, old.EndTime, loc)
+	}
+	return data
+}
+
+// trainingData fills in the templates for a notification about a training
+// session
+func trainingData(db *sql.DB, user *models.User, session *models.TrainingSession) *messageData {
+	data := &messageData{
+		Name:  user.Username,
+		Club:  clubName(db, user.ClubID),
+		Court: session.CourtName,
+		Title: session.Title,
+		Coach: session.CoachName,
+	}
+	loc := courtLocation(db, session.CourtID)
+	data.Start, data.End = formatTimes(session.StartTime, session.EndTime, loc)
+	return data
+}
+
+// formatTimes formats a start and end time for a notification in loc
+func formatTimes(start, end time.Time, loc *time.Location) (string, string) {
+	return start.In(loc).Format(startFormat), end.In(loc).Format(endFormat)
+}
+
+// clubName returns the name notifications are signed with
+func clubName(db *sql.DB, clubID int64) string {
+	if club, err := models.GetClubByID(db, clubID); err == nil {
+		return club.Name
+	}
+	return "Pickleball Court Booking"
+}
+
+// facilityLocation returns the timezone of a facility
+func facilityLocation(db *sql.DB, facilityID int64) *time.Location {
+	facility, err := models.GetFacilityByID(db, models.AnyClub, facilityID)
+	if err != nil {
+		facility = &models.Facility{}
+	}
+	return facility.Location()
+}
+
+// courtLocation returns the timezone of the facility a court belongs to
+func courtLocation(db *sql.DB, courtID int64) *time.Location {
+	court, err := models.GetCourtByID(db, models.AnyClub, courtID)
+	if err != nil {
+		return facilityLocation(db, 0)
+	}
+	return facilityLocation(db, court.FacilityID)
+}
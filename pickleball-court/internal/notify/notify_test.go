@@ -0,0 +1,345 @@
+package notify
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/email"
+	"pickleball-court/internal/models"
+)
+
+// openTestDB opens a fresh database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	config.Load()
+
+	db, err := models.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser creates a user in club 1
+func createTestUser(t *testing.T, db *sql.DB, username, role string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Password: "password", Email: username + "@example.com", Role: role, ClubID: 1}
+	if err := models.CreateUser(db, user); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestBooking books a confirmed hour on a new court tomorrow at hour
+func createTestBooking(t *testing.T, db *sql.DB, user *models.User, hour int) *models.Booking {
+	t.Helper()
+	court := &models.Court{Name: "Court 1", ClubID: 1}
+	if err := models.CreateCourt(db, court); err != nil {
+		t.Fatal(err)
+	}
+	start := tomorrowAt(hour)
+	booking := &models.Booking{
+		CourtID:     court.ID,
+		UserID:      user.ID,
+		StartTime:   start,
+		EndTime:     start.Add(time.Hour),
+		Status:      models.BookingStatusConfirmed,
+		BookingType: models.BookingTypeRegular,
+	}
+	if err := models.CreateBooking(db, booking); err != nil {
+		t.Fatal(err)
+	}
+	return booking
+}
+
+// tomorrowAt returns the given hour tomorrow in the configured timezone
+func tomorrowAt(hour int) time.Time {
+	now := time.Now().In(config.Get().GetTimeZone())
+	return time.Date(now.Year(), now.Month(), now.Day()+1, hour, 0, 0, 0, now.Location())
+}
+
+// queuedEmail reloads an email from the outbox
+func queuedEmail(t *testing.T, db *sql.DB, id int64) *models.QueuedEmail {
+	t.Helper()
+	queued := &models.QueuedEmail{ID: id}
+	err := db.QueryRow(`SELECT status, attempts, last_error, next_attempt_at FROM email_queue WHERE id = ?`, id).
+		Scan(&queued.Status, &queued.Attempts, &queued.LastError, &queued.NextAttemptAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return queued
+}
+
+func TestDeliverRetriesWithExponentialBackoff(t *testing.T) {
+	db := openTestDB(t)
+	queued := &models.QueuedEmail{Recipient: "player@example.com", Subject: "Booking confirmed", Body: "See you on court"}
+	if err := models.QueueEmail(db, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &email.MemorySender{Err: errors.New("connection refused")}
+	now := time.Now().Add(time.Second)
+	for attempt := 1; attempt <= models.MaxEmailAttempts; attempt++ {
+		// Not tried again before its retry time
+		if attempt > 1 {
+			if _, err := Deliver(db, sender, now.Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if got := queuedEmail(t, db, queued.ID).Attempts; got != attempt-1 {
+				t.Fatalf("attempt %d early: got %d attempts, want %d", attempt, got, attempt-1)
+			}
+		}
+
+		if sent, err := Deliver(db, sender, now); err != nil || sent != 0 {
+			t.Fatalf("attempt %d: sent %d, err %v", attempt, sent, err)
+		}
+		got := queuedEmail(t, db, queued.ID)
+		if got.Attempts != attempt || got.LastError != "connection refused" {
+			t.Fatalf("attempt %d: got %d attempts and error %q", attempt, got.Attempts, got.LastError)
+		}
+		if attempt == models.MaxEmailAttempts {
+			if got.Status != models.EmailStatusFailed {
+				t.Errorf("got status %s after %d attempts, want failed", got.Status, attempt)
+			}
+			break
+		}
+		if got.Status != models.EmailStatusPending {
+			t.Fatalf("attempt %d: got status %s, want pending", attempt, got.Status)
+		}
+
+		// Each retry waits twice as long as the one before
+		wait := retryBackoff << uint(attempt-1)
+		if delay := got.NextAttemptAt.Sub(now); delay != wait {
+			t.Errorf("attempt %d: retry in %s, want %s", attempt, delay, wait)
+		}
+		now = got.NextAttemptAt
+	}
+
+	// A failed email stays failed once the server is back
+	sender.Err = nil
+	if sent, err := Deliver(db, sender, now.Add(24*time.Hour)); err != nil || sent != 0 {
+		t.Errorf("after giving up: sent %d, err %v", sent, err)
+	}
+	if len(sender.Sent()) != 0 {
+		t.Errorf("got %d messages sent after giving up, want 0", len(sender.Sent()))
+	}
+}
+
+func TestDeliverSendsOnRetry(t *testing.T) {
+	db := openTestDB(t)
+	queued := &models.QueuedEmail{Recipient: "player@example.com", Subject: "Booking confirmed", Body: "See you on court"}
+	if err := models.QueueEmail(db, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &email.MemorySender{Err: errors.New("connection refused")}
+	now := time.Now().Add(time.Second)
+	if _, err := Deliver(db, sender, now); err != nil {
+		t.Fatal(err)
+	}
+
+	sender.Err = nil
+	sent, err := Deliver(db, sender, now.Add(retryBackoff))
+	if err != nil || sent != 1 {
+		t.Fatalf("sent %d, err %v; want 1 sent", sent, err)
+	}
+	if got := queuedEmail(t, db, queued.ID); got.Status != models.EmailStatusSent || got.Attempts != 2 {
+		t.Errorf("got status %s after %d attempts, want sent after 2", got.Status, got.Attempts)
+	}
+	messages := sender.Sent()
+	if len(messages) != 1 || messages[0].To != queued.Recipient || messages[0].Body != queued.Body {
+		t.Errorf("got messages %+v, want the queued email", messages)
+	}
+
+	// Sent emails are not sent again
+	if sent, err := Deliver(db, sender, now.Add(time.Hour)); err != nil || sent != 0 {
+		t.Errorf("second run: sent %d, err %v; want none", sent, err)
+	}
+}
+
+func TestDeliverSkipsEmailsClaimedElsewhere(t *testing.T) {
+	db := openTestDB(t)
+	queued := &models.QueuedEmail{Recipient: "player@example.com", Subject: "Booking confirmed", Body: "See you on court"}
+	if err := models.QueueEmail(db, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance loaded the same batch and claimed the email first
+	now := time.Now().Add(time.Second)
+	claimed, err := models.ClaimEmail(db, queued, now, now.Add(claimTimeout))
+	if err != nil || !claimed {
+		t.Fatalf("claim: %v, %v", claimed, err)
+	}
+	if claimed, err := models.ClaimEmail(db, queued, now, now.Add(claimTimeout)); err != nil || claimed {
+		t.Fatalf("second claim: %v, %v; want false", claimed, err)
+	}
+
+	sender := &email.MemorySender{}
+	if sent, err := Deliver(db, sender, now); err != nil || sent != 0 {
+		t.Errorf("sent %d, err %v; want the claimed email left alone", sent, err)
+	}
+
+	// If that instance dies, the email is sent once the claim runs out
+	if sent, err := Deliver(db, sender, now.Add(claimTimeout)); err != nil || sent != 1 {
+		t.Errorf("after the claim ran out: sent %d, err %v; want 1", sent, err)
+	}
+}
+
+func TestBookingCancelledReachesAcceptedParticipants(t *testing.T) {
+	db := openTestDB(t)
+	users := map[string]*models.User{}
+	for _, name := range []string{"booker", "partner", "invitee"} {
+		users[name] = createTestUser(t, db, name, models.RolePlayer)
+	}
+	booking := createTestBooking(t, db, users["booker"], 10)
+	now := time.Now()
+
+	partner, err := models.InviteParticipant(db, booking.ID, users["partner"].ID, booking.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.AcceptInvitation(db, partner.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.InviteParticipant(db, booking.ID, users["invitee"].ID, booking.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.AddGuest(db, booking.ID, "Guest", "guest@example.com", booking.UserID); err != nil {
+		t.Fatal(err)
+	}
+	participants, err := models.GetBookingParticipants(db, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	BookingCancelled(db, booking, "Court resurfacing", participants)
+
+	sender := &email.MemorySender{}
+	if _, err := Deliver(db, sender, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, message := range sender.Sent() {
+		got[message.To] = true
+	}
+	for _, address := range []string{"booker@example.com", "partner@example.com", "guest@example.com"} {
+		if !got[address] {
+			t.Errorf("no email to %s", address)
+		}
+	}
+	if got["invitee@example.com"] || len(got) != 3 {
+		t.Errorf("got emails to %v, want only the booker, the partner and the guest", got)
+	}
+
+	// Members also find it in their inbox; guests have none
+	for _, name := range []string{"booker", "partner"} {
+		notifications, err := models.GetUserNotifications(db, users[name].ID, false, 10)
+		if err != nil || len(notifications) != 1 || notifications[0].Kind != KindBookingCancelled {
+			t.Errorf("%s: got %d notifications, err %v; want the cancellation", name, len(notifications), err)
+		}
+	}
+	var guestNotifications int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = 0`).Scan(&guestNotifications); err != nil {
+		t.Fatal(err)
+	}
+	if guestNotifications != 0 {
+		t.Errorf("got %d inbox notifications for the guest, want 0", guestNotifications)
+	}
+}
+
+func TestRescheduledBookingIsRemindedAgain(t *testing.T) {
+	db := openTestDB(t)
+	player := createTestUser(t, db, "player", models.RolePlayer)
+	booking := createTestBooking(t, db, player, 10)
+
+	remind := func() int {
+		t.Helper()
+		reminded, err := SendBookingReminders(db, time.Now(), 48*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reminded
+	}
+	if got := remind(); got != 1 {
+		t.Fatalf("reminded %d bookings, want 1", got)
+	}
+
+	// Moving to another court at the same time needs no new reminder
+	other := &models.Court{Name: "Court 2", ClubID: 1}
+	if err := models.CreateCourt(db, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := models.RescheduleBooking(db, booking.ID, other.ID, booking.StartTime, booking.EndTime, player.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := remind(); got != 0 {
+		t.Errorf("reminded %d bookings after a court change, want 0", got)
+	}
+
+	start := tomorrowAt(12)
+	if _, _, err := models.RescheduleBooking(db, booking.ID, other.ID, start, start.Add(time.Hour), player.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := remind(); got != 1 {
+		t.Errorf("reminded %d bookings after moving to a new time, want 1", got)
+	}
+}
+
+func TestRescheduledTrainingIsRemindedAgain(t *testing.T) {
+	db := openTestDB(t)
+	coach := createTestUser(t, db, "coach", models.RoleCoach)
+	player := createTestUser(t, db, "player", models.RolePlayer)
+	court := &models.Court{Name: "Court 1", ClubID: 1}
+	if err := models.CreateCourt(db, court); err != nil {
+		t.Fatal(err)
+	}
+	start := tomorrowAt(10)
+	session := &models.TrainingSession{
+		CoachID:         coach.ID,
+		CourtID:         court.ID,
+		Title:           "Drills",
+		StartTime:       start,
+		EndTime:         start.Add(time.Hour),
+		MaxParticipants: 4,
+	}
+	if err := models.CreateTrainingSession(db, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.EnrollInTrainingSession(db, player.ID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	remind := func() int {
+		t.Helper()
+		reminded, err := SendTrainingReminders(db, time.Now(), 48*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reminded
+	}
+	if got := remind(); got != 1 {
+		t.Fatalf("reminded %d players, want 1", got)
+	}
+
+	session.Title = "Drills and games"
+	if err := models.UpdateTrainingSession(db, session); err != nil {
+		t.Fatal(err)
+	}
+	if got := remind(); got != 0 {
+		t.Errorf("reminded %d players after a new title, want 0", got)
+	}
+
+	session.StartTime = tomorrowAt(14)
+	session.EndTime = session.StartTime.Add(time.Hour)
+	if err := models.UpdateTrainingSession(db, session); err != nil {
+		t.Fatal(err)
+	}
+	if got := remind(); got != 1 {
+		t.Errorf("reminded %d players after moving to a new time, want 1", got)
+	}
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"strings"
+	"text/template"
+)
+
+// Kinds of notification. Each has a "<kind>.subject" template, used as the
+// email subject and inbox title, and a "<kind>.text" template with the
+// message itself. Emails wrap the text in a greeting and sign-off.
+const (
+	KindBookingCreated     = "booking_created"
+	KindBookingConfirmed   = "booking_confirmed"
+	KindBookingCancelled   = "booking_cancelled"
+	KindBookingRescheduled = "booking_rescheduled"
+	KindBookingReminder    = "booking_reminder"
+	KindTrainingEnrolled   = "training_enrolled"
+	KindTrainingCancelled  = "training_cancelled"
+	KindTrainingReminder   = "training_reminder"
+	KindWaitlistOffer      = "waitlist_offer"
+	KindPasswordReset      = "password_reset"
+)
+
+// Kinds lists the notifications players can choose to get in their inbox,
+// by email or both. Password resets always go by email.
+var Kinds = []string{
+	KindBookingCreated,
+	KindBookingConfirmed,
+	KindBookingCancelled,
+	KindBookingRescheduled,
+	KindBookingReminder,
+	KindTrainingEnrolled,
+	KindTrainingCancelled,
+	KindTrainingReminder,
+	KindWaitlistOffer,
+}
+
+var templates = template.Must(template.New("email").Parse(`
+{{define "email"}}Hi {{.Name}},
+
+{{.Text}}
+
+{{.Club}}
+{{end}}
+
+{{define "booking_created.subject"}}Booking received: {{.Court}} on {{.Start}}{{end}}
+{{define "booking_created.text"}}Your booking of {{.Court}} from {{.Start}} to {{.End}} has been received.
+{{if eq .Status "pending"}}It is waiting for approval and we will let you know once it is confirmed.{{else}}It is {{.Status}}.{{end}}{{end}}
+
+{{define "booking_confirmed.subject"}}Booking confirmed: {{.Court}} on {{.Start}}{{end}}
+{{define "booking_confirmed.text"}}Your booking of {{.Court}} from {{.Start}} to {{.End}} is confirmed. See you on court!{{end}}
+
+{{define "booking_cancelled.subject"}}Booking cancelled: {{.Court}} on {{.Start}}{{end}}
+{{define "booking_cancelled.text"}}Your booking of {{.Court}} from {{.Start}} to {{.End}} has been cancelled.{{if .Reason}}
+Reason: {{.Reason}}{{end}}{{end}}
+
+{{define "booking_rescheduled.subject"}}Booking moved: {{.Court}} on {{.Start}}{{end}}
+{{define "booking_rescheduled.text"}}Your booking of {{.OldCourt}} from {{.OldStart}} to {{.OldEnd}} has moved.
+It is now on {{.Court}} from {{.Start}} to {{.End}}.{{if .Reason}}
+Reason: {{.Reason}}{{end}}{{end}}
+
+{{define "booking_reminder.subject"}}Reminder: {{.Court}} on {{.Start}}{{end}}
+{{define "booking_reminder.text"}}A reminder that you have {{.Court}} booked from {{.Start}} to {{.End}}.
+Please check in when you arrive, or cancel if you can no longer make it.{{end}}
+
+{{define "training_enrolled.subject"}}Enrolled: {{.Title}} on {{.Start}}{{end}}
+{{define "training_enrolled.text"}}You are enrolled in {{.Title}} with {{.Coach}} on {{.Court}} from {{.Start}} to {{.End}}.{{end}}
+
+{{define "training_cancelled.subject"}}Cancelled: {{.Title}} on {{.Start}}{{end}}
+{{define "training_cancelled.text"}}{{.Coach}} has cancelled {{.Title}} on {{.Court}} from {{.Start}} to {{.End}}.{{end}}
+
+{{define "training_reminder.subject"}}Reminder: {{.Title}} on {{.Start}}{{end}}
+{{define "training_reminder.text"}}A reminder that {{.Title}} with {{.Coach}} is on {{.Court}} from {{.Start}} to {{.End}}.{{end}}
+
+{{define "waitlist_offer.subject"}}Court available: {{.Court}} on {{.Start}}{{end}}
+{{define "waitlist_offer.text"}}A slot you were waiting for has opened up: {{.Court}} from {{.Start}} to {{.End}}.
+It is held for you until {{.Expires}}. Claim it from your dashboard before then.{{end}}
+
+{{define "password_reset.subject"}}Reset your {{.Club}} password{{end}}
+{{define "password_reset.text"}}Someone asked to reset the password for your account. To choose a new one,
+open this link within the hour:
+
+{{.Link}}
+
+If it wasn't you, you can ignore this email and your password stays the same.{{end}}
+`))
+
+// messageData fills in the templates. Times are already formatted in the
+// facility's timezone.
+type messageData struct {
+	Name     string
+	Club     string
+	Court    string
+	Start    string
+	End      string
+	Status   string
+	Reason   string
+	OldCourt string
+	OldStart string
+	OldEnd   string
+	Title    string
+	Coach    string
+	Expires  string
+	Link     string
+	Text     string // the rendered text, for the email wrapper
+}
+
+// render returns the subject and text of a notification of the given kind
+func render(kind string, data *messageData) (string, string, error) {
+	var subject, text strings.Builder
+	if err := templates.ExecuteTemplate(&subject, kind+".subject", data); err != nil {
+		return "", "", err
+	}
+	if err := templates.ExecuteTemplate(&text, kind+".text", data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subject.String()), strings.TrimSpace(text.String()), nil
+}
+
+// emailBody wraps a notification's text in a greeting and sign-off
+func emailBody(data *messageData, text string) (string, error) {
+	var body strings.Builder
+	wrapped := *data
+	wrapped.Text = text
+	if err := templates.ExecuteTemplate(&body, "email", &wrapped); err != nil {
+		return "", err
+	}
+	return strings.TrimLeft(body.String(), "\n"), nil
+}
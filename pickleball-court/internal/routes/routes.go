@@ -3,8 +3,10 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"pickleball-court/internal/api"
 	"pickleball-court/internal/handlers"
 	"pickleball-court/internal/middleware"
+	"strings"
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,8 +23,15 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 	router.POST("/login", handlers.LoginHandler(db))
 	router.GET("/register", handlers.ShowRegisterHandler())
 	router.POST("/register", handlers.RegisterHandler(db))
+	router.POST("/password/forgot", handlers.ForgotPasswordHandler(db))
+	router.POST("/password/reset", handlers.ResetPasswordHandler(db))
 	router.GET("/logout", handlers.LogoutHandler())
 
+	// iCal feeds for calendar apps; personal feeds are secured by the
+	// secret token in their URL
+	router.GET("/calendar/users/:token/bookings.ics", handlers.UserCalendarFeedHandler(db))
+	router.GET("/calendar/courts/:id/bookings.ics", handlers.CourtCalendarFeedHandler(db))
+
 	// Protected routes
 	authorized := router.Group("/")
 	authorized.Use(middleware.AuthRequired())
@@ -31,6 +40,15 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		authorized.GET("/profile", handlers.ProfileHandler(db))
 		authorized.POST("/profile/update", handlers.UpdateProfileHandler(db))
 		authorized.POST("/profile/password", handlers.UpdatePasswordHandler(db))
+		authorized.GET("/profile/calendar", handlers.CalendarLinkHandler(db))
+		authorized.POST("/profile/calendar/reset", handlers.ResetCalendarLinkHandler(db))
+
+		// In-app notifications and where each kind is delivered
+		authorized.GET("/notifications", handlers.ListNotificationsHandler(db))
+		authorized.POST("/notifications/read", handlers.MarkAllNotificationsReadHandler(db))
+		authorized.POST("/notifications/:id/read", handlers.MarkNotificationReadHandler(db))
+		authorized.GET("/notifications/preferences", handlers.GetNotificationPreferencesHandler(db))
+		authorized.PUT("/notifications/preferences", handlers.UpdateNotificationPreferencesHandler(db))
 
 		// Court viewing routes
 		authorized.GET("/courts", handlers.ListCourtsHandler(db))
@@ -41,6 +59,31 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		authorized.GET("/bookings/:id", handlers.GetBookingHandler(db))
 		authorized.POST("/bookings", handlers.CreateBookingHandler(db))
 		authorized.POST("/bookings/:id/cancel", handlers.CancelBookingHandler(db))
+		authorized.POST("/bookings/:id/checkin", handlers.CheckInBookingHandler(db))
+
+		// A booking as an .ics file to add to a calendar
+		authorized.GET("/bookings/:id/calendar.ics", handlers.BookingCalendarHandler(db))
+		authorized.POST("/bookings/:id/reschedule", handlers.RescheduleBookingHandler(db))
+		authorized.POST("/bookings/:id/transfer", handlers.TransferBookingHandler(db))
+		authorized.GET("/bookings/:id/participants", handlers.ListParticipantsHandler(db))
+		authorized.POST("/bookings/:id/participants", handlers.AddParticipantHandler(db))
+		authorized.DELETE("/bookings/:id/participants/:participant_id", handlers.RemoveParticipantHandler(db))
+		authorized.GET("/invitations", handlers.ListInvitationsHandler(db))
+		authorized.POST("/invitations/:id/accept", handlers.AcceptInvitationHandler(db))
+		authorized.POST("/invitations/:id/decline", handlers.DeclineInvitationHandler(db))
+		authorized.GET("/transfers", handlers.ListTransfersHandler(db))
+		authorized.POST("/transfers/:id/accept", handlers.AcceptTransferHandler(db))
+		authorized.POST("/transfers/:id/decline", handlers.DeclineTransferHandler(db))
+		authorized.POST("/transfers/:id/cancel", handlers.CancelTransferHandler(db))
+		authorized.POST("/holds", handlers.CreateSlotHoldHandler(db))
+		authorized.POST("/holds/:id/confirm", handlers.ConfirmSlotHoldHandler(db))
+		authorized.DELETE("/holds/:id", handlers.ReleaseSlotHoldHandler(db))
+		authorized.GET("/open-play", handlers.ListOpenPlayHandler(db))
+		authorized.GET("/open-play/:id/queue", handlers.GetOpenPlayQueueHandler(db))
+		authorized.POST("/open-play/:id/rotate", handlers.RotateOpenPlayHandler(db))
+
+		// Facilities, for picking a venue
+		authorized.GET("/facilities", handlers.ListFacilitiesHandler(db))
 
 		// Admin routes
 		admin := authorized.Group("/admin")
@@ -54,14 +97,77 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 			admin.PUT("/users/:id", handlers.UpdateUserHandler(db))
 			admin.DELETE("/users/:id", handlers.DeleteUserHandler(db))
 			
+			// Facility management; facility admins can only update their own
+			admin.POST("/facilities", handlers.CreateFacilityHandler(db))
+			admin.PUT("/facilities/:id", handlers.UpdateFacilityHandler(db))
+			admin.DELETE("/facilities/:id", handlers.DeleteFacilityHandler(db))
+
 			// Court management
 			admin.POST("/courts", handlers.CreateCourtHandler(db))
 			admin.PUT("/courts/:id", handlers.UpdateCourtHandler(db))
 			admin.DELETE("/courts/:id", handlers.DeleteCourtHandler(db))
+
+			// Court schedules: weekly hours, date overrides and blackouts
+			admin.GET("/courts/:id/hours", handlers.GetCourtHoursHandler(db))
+			admin.PUT("/courts/:id/hours", handlers.UpdateCourtHoursHandler(db))
+			admin.GET("/hours-overrides", handlers.ListHoursOverridesHandler(db))
+			admin.POST("/hours-overrides", handlers.CreateHoursOverrideHandler(db))
+			admin.DELETE("/hours-overrides/:id", handlers.DeleteHoursOverrideHandler(db))
+			admin.GET("/blackouts", handlers.ListBlackoutsHandler(db))
+			admin.POST("/blackouts", handlers.CreateBlackoutHandler(db))
+			admin.POST("/blackouts/preview", handlers.PreviewBlackoutHandler(db))
+			admin.DELETE("/blackouts/:id", handlers.DeleteBlackoutHandler(db))
+
+			// Maintenance windows; end one early by deleting it as a blackout
+			admin.GET("/courts/:id/maintenance", handlers.ListCourtMaintenanceHandler(db))
+			admin.POST("/courts/:id/maintenance", handlers.ScheduleMaintenanceHandler(db))
 			
 			// Booking management
 			admin.GET("/bookings/all", handlers.ListAllBookingsHandler(db))
+			admin.GET("/bookings/pending", handlers.ListPendingBookingsHandler(db))
+			admin.GET("/bookings/:id/history", handlers.BookingHistoryHandler(db))
 			admin.PUT("/bookings/:id", handlers.UpdateBookingHandler(db))
+			admin.POST("/bookings/:id/cancel", handlers.AdminCancelBookingHandler(db))
+			admin.GET("/cancellations/late", handlers.ListLateCancellationsHandler(db))
+
+			// Late-cancellation penalties; only club-wide admins can change them
+			admin.GET("/penalty-policy", handlers.GetPenaltyPolicyHandler(db))
+			admin.PUT("/penalty-policy", handlers.UpdatePenaltyPolicyHandler(db))
+
+			// No-show tracking; ?facility_id= selects a facility's own policy
+			admin.GET("/no-show-policy", handlers.GetNoShowPolicyHandler(db))
+			admin.PUT("/no-show-policy", handlers.UpdateNoShowPolicyHandler(db))
+			admin.DELETE("/no-show-policy", handlers.DeleteNoShowPolicyHandler(db))
+			admin.POST("/open-play", handlers.CreateOpenPlayHandler(db))
+			admin.DELETE("/open-play/:id", handlers.CancelOpenPlayHandler(db))
+
+			// Outbound webhooks and their delivery log
+			admin.GET("/webhooks", handlers.ListWebhooksHandler(db))
+			admin.POST("/webhooks", handlers.CreateWebhookHandler(db))
+			admin.PUT("/webhooks/:id", handlers.UpdateWebhookHandler(db))
+			admin.DELETE("/webhooks/:id", handlers.DeleteWebhookHandler(db))
+			admin.POST("/webhooks/:id/secret", handlers.RotateWebhookSecretHandler(db))
+			admin.GET("/webhooks/:id/deliveries", handlers.ListWebhookDeliveriesHandler(db))
+			admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", handlers.RedeliverWebhookHandler(db))
+		}
+
+		// Super admin routes, for managing the clubs on this deployment
+		super := authorized.Group("/super")
+		super.Use(middleware.RoleRequired("superadmin"))
+		{
+			super.GET("/clubs", handlers.ListClubsHandler(db))
+			super.POST("/clubs", handlers.CreateClubHandler(db))
+			super.PUT("/clubs/:id", handlers.UpdateClubHandler(db))
+			super.DELETE("/clubs/:id", handlers.DeleteClubHandler(db))
+			super.POST("/clubs/:id/admins", handlers.CreateClubAdminHandler(db))
+
+			// Mark no-shows at every club now rather than waiting for the job
+			super.POST("/bookings/sweep-no-shows", handlers.SweepNoShowsHandler(db))
+
+			// Background jobs
+			super.GET("/jobs", handlers.ListJobsHandler(db))
+			super.GET("/jobs/dashboard", handlers.JobsPageHandler(db))
+			super.POST("/jobs/:id/run", handlers.RunJobHandler(db))
 		}
 
 		// Coach routes
@@ -75,6 +181,10 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 			coach.POST("/sessions", handlers.CreateTrainingSessionHandler(db))
 			coach.PUT("/sessions/:id", handlers.UpdateTrainingSessionHandler(db))
 			coach.DELETE("/sessions/:id", handlers.DeleteTrainingSessionHandler(db))
+
+			// Open play blocks
+			coach.POST("/open-play", handlers.CreateOpenPlayHandler(db))
+			coach.DELETE("/open-play/:id", handlers.CancelOpenPlayHandler(db))
 		}
 
 		// Player routes
@@ -82,6 +192,19 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		player.Use(middleware.RoleRequired("player"))
 		{
 			player.GET("/dashboard", handlers.PlayerDashboardHandler(db))
+			player.GET("/courts", handlers.SearchCourtsHandler(db))
+			player.GET("/courts/availability/stream", handlers.StreamAvailabilityHandler(db))
+
+			// Recurring bookings
+			player.POST("/series", handlers.CreateBookingSeriesHandler(db))
+			player.GET("/series/:id", handlers.GetBookingSeriesHandler(db))
+			player.POST("/series/:id/cancel", handlers.CancelBookingSeriesHandler(db))
+			player.GET("/waitlist", handlers.ListWaitlistHandler(db))
+			player.POST("/waitlist", handlers.JoinWaitlistHandler(db))
+			player.POST("/waitlist/:id/claim", handlers.ClaimWaitlistHandler(db))
+			player.DELETE("/waitlist/:id", handlers.LeaveWaitlistHandler(db))
+			player.POST("/open-play/:id/join", handlers.JoinOpenPlayHandler(db))
+			player.POST("/open-play/:id/leave", handlers.LeaveOpenPlayHandler(db))
 			
 			// Training session enrollment
 			player.GET("/training", handlers.ListAvailableTrainingHandler(db))
@@ -90,8 +213,38 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		}
 	}
 
+	// Versioned JSON API. It shares the web app's session cookie, so
+	// clients sign in through /api/v1/session.
+	v1 := router.Group("/api/v1")
+	{
+		v1.POST("/session", api.LoginHandler(db))
+		v1.DELETE("/session", api.LogoutHandler())
+
+		authed := v1.Group("/")
+		authed.Use(api.AuthRequired())
+		{
+			authed.GET("/me", api.MeHandler())
+			authed.GET("/courts", api.ListCourtsHandler(db))
+			authed.GET("/courts/:id", api.GetCourtHandler(db))
+			authed.GET("/availability", api.AvailabilityHandler(db))
+			authed.GET("/bookings", api.ListBookingsHandler(db))
+			authed.POST("/bookings", api.CreateBookingHandler(db))
+			authed.GET("/bookings/:id", api.GetBookingHandler(db))
+			authed.POST("/bookings/:id/cancel", api.CancelBookingHandler(db))
+			authed.GET("/training-sessions", api.ListTrainingSessionsHandler(db))
+			authed.POST("/training-sessions/:id/enrollment", api.EnrollTrainingHandler(db))
+			authed.DELETE("/training-sessions/:id/enrollment", api.CancelEnrollmentHandler(db))
+			authed.GET("/users", api.ListUsersHandler(db))
+			authed.GET("/users/:id", api.GetUserHandler(db))
+		}
+	}
+
 	// Error handlers
 	router.NoRoute(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			api.NotFoundHandler()(c)
+			return
+		}
 		c.HTML(http.StatusNotFound, "error.html", gin.H{
 			"title": "Page Not Found",
 			"error": "The page you're looking for doesn't exist.",
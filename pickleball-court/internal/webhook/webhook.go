@@ -0,0 +1,282 @@
+// Package webhook tells other systems, such as a chat bot or a door
+// controller, about events at a club by POSTing JSON to the endpoints its
+// admins registered. Deliveries are queued in the database and sent in the
+// background by Deliver, retrying failures with exponential backoff, so
+// that a slow or failing endpoint never holds up a request. Booking events
+// are queued by the models layer in the same transaction as the change, so
+// a committed change is always delivered and a rolled back one never is.
+// Training and user events are queued by this package once the change has
+// been made; if queuing fails the event is logged and dropped.
+//
+// Every request carries the event type and delivery ID in the
+// X-Webhook-Event and X-Webhook-Delivery headers, and is signed in the
+// X-Webhook-Signature header as "t=<unix time>,v1=<hex HMAC-SHA256 of
+// "<unix time>.<body>" keyed with the webhook's secret>". Receivers should
+// check the signature and reject old timestamps.
+//
+// Deliveries only go to public addresses, and redirects are not followed.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"pickleball-court/internal/models"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// How many queued deliveries one delivery pass sends at most
+	deliveryBatch = 50
+
+	// Delay before the first retry of a failed delivery, doubled each
+	// attempt
+	retryBackoff = 30 * time.Second
+
+	// How long an endpoint has to answer
+	requestTimeout = 10 * time.Second
+
+	// How much of a response body is read before the connection is
+	// closed. Bodies are never stored.
+	maxResponseBody = 64 << 10
+
+	// How long a claimed delivery is left to its sender before another
+	// delivery pass may try it
+	claimTimeout = 5 * time.Minute
+)
+
+// Training is the data of a training event. UserID is the player enrolled
+// or, when a session is cancelled, one of the players who were.
+type Training struct {
+	SessionID int64     `json:"session_id"`
+	Title     string    `json:"title"`
+	CoachID   int64     `json:"coach_id"`
+	CourtID   int64     `json:"court_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	UserID    int64     `json:"user_id"`
+}
+
+// User is the data of a user event
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+// ErrPrivateAddress is returned when a webhook URL leads to an address
+// that is not on the public internet
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// blockedNetworks are special-purpose ranges not covered by the net.IP
+// checks in publicAddress
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8",     // "this network"
+	"100.64.0.0/10", // carrier-grade NAT, also used for cloud metadata
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved, and broadcast
+	"64:ff9b::/96",  // NAT64, which can reach private IPv4 addresses
+)
+
+// NewClient returns the HTTP client deliveries are sent with. Admins
+// choose webhook URLs, so the client only connects to public addresses,
+// keeping the app's own network and cloud metadata services out of reach.
+// The address is checked when connecting, after DNS resolution, so a host
+// name cannot be pointed somewhere else between a check and the request.
+// Redirects are not followed, as they could lead to such an address too.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return publicAddress(address)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicAddress returns ErrPrivateAddress unless address, an IP and port,
+// is on the public internet
+func publicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// Queue queues an event for every webhook at the club subscribed to it.
+// Failures are logged rather than returned, since the change that caused
+// the event has already happened.
+func Queue(db *sql.DB, clubID int64, eventType string, data interface{}) {
+	now := time.Now()
+	payload, err := json.Marshal(models.WebhookEnvelope{Type: eventType, ClubID: clubID, CreatedAt: now.UTC(), Data: data})
+	if err != nil {
+		log.Printf("Webhook: failed to encode %s: %v\n", eventType, err)
+		return
+	}
+	if _, err := models.QueueWebhookEvent(db, clubID, eventType, payload, now); err != nil {
+		log.Printf("Webhook: failed to queue %s: %v\n", eventType, err)
+	}
+}
+
+// TrainingEnrolled announces a player joining a training session
+func TrainingEnrolled(db *sql.DB, userID int64, session *models.TrainingSession) {
+	Queue(db, session.ClubID, models.WebhookTrainingEnrolled, training(session, userID))
+}
+
+// TrainingCancelled announces a coach cancelling a training session, once
+// for each player who was enrolled
+func TrainingCancelled(db *sql.DB, userIDs []int64, session *models.TrainingSession) {
+	for _, userID := range userIDs {
+		Queue(db, session.ClubID, models.WebhookTrainingCancelled, training(session, userID))
+	}
+}
+
+// UserRegistered announces a new member signing up
+func UserRegistered(db *sql.DB, user *models.User) {
+	Queue(db, user.ClubID, models.WebhookUserRegistered, User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	})
+}
+
+// Deliver sends up to a batch of due deliveries. Deliveries that fail are
+// retried with exponential backoff until models.MaxWebhookAttempts is
+// reached. It returns how many were delivered.
+func Deliver(db *sql.DB, client *http.Client, now time.Time) (int, error) {
+	deliveries, webhooks, err := models.GetDueWebhookDeliveries(db, now, deliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		// Another instance may be working through the same batch
+		claimed, err := models.ClaimWebhookDelivery(db, delivery, now, now.Add(claimTimeout))
+		if err != nil {
+			return delivered, err
+		}
+		if !claimed {
+			continue
+		}
+
+		webhook := webhooks[delivery.WebhookID]
+		status, err := send(client, webhook, delivery, now)
+		if err != nil {
+			retryAt := now.Add(retryBackoff << uint(delivery.Attempts))
+			if err := models.MarkWebhookFailed(db, delivery, status, err, retryAt); err != nil {
+				return delivered, err
+			}
+			if delivery.Status == models.WebhookDeliveryFailed {
+				log.Printf("Webhook: giving up on delivery %d to %s: %s\n", delivery.ID, webhook.URL, delivery.LastError)
+			}
+			continue
+		}
+		if err := models.MarkWebhookDelivered(db, delivery, status, now); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// send POSTs a delivery to its webhook, returning the response status. Any
+// status other than 2xx is a failure.
+func send(client *http.Client, webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pickleball-court-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(webhook.Secret, timestamp, body)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body is read only so the connection can be reused. It is not
+	// kept, so that whatever an endpoint answers never reaches the
+	// delivery log admins can read.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a delivery body sent at
+// timestamp, as carried in the v1 part of X-Webhook-Signature
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func training(session *models.TrainingSession, userID int64) Training {
+	return Training{
+		SessionID: session.ID,
+		Title:     session.Title,
+		CoachID:   session.CoachID,
+		CourtID:   session.CourtID,
+		StartTime: session.StartTime,
+		EndTime:   session.EndTime,
+		UserID:    userID,
+	}
+}
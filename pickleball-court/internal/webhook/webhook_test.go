@@ -0,0 +1,329 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pickleball-court/config"
+	"pickleball-court/internal/models"
+)
+
+// openTestDB opens a fresh database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	config.Load()
+
+	db, err := models.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// receiver is a webhook endpoint that records what it is sent and answers
+// with status
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		w.WriteHeader(r.status)
+		io.WriteString(w, "internal detail the admin must not see")
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) answer(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// createTestWebhook registers a webhook at club 1 for user.registered
+// events, and queues one such event for it
+func createTestWebhook(t *testing.T, db *sql.DB, url string) *models.Webhook {
+	t.Helper()
+	admin := &models.User{Username: "admin", Password: "password", Email: "admin@example.com", Role: models.RoleAdmin, ClubID: 1}
+	if err := models.CreateUser(db, admin); err != nil {
+		t.Fatal(err)
+	}
+	webhook := &models.Webhook{
+		ClubID:     1,
+		URL:        url,
+		EventTypes: []string{models.WebhookUserRegistered},
+		Active:     true,
+		CreatedBy:  admin.ID,
+	}
+	if err := models.CreateWebhook(db, webhook); err != nil {
+		t.Fatal(err)
+	}
+	UserRegistered(db, admin)
+	return webhook
+}
+
+// onlyDelivery returns the one delivery queued for a webhook
+func onlyDelivery(t *testing.T, db *sql.DB, webhookID int64) *models.WebhookDelivery {
+	t.Helper()
+	deliveries, err := models.GetWebhookDeliveries(db, webhookID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, err %v; want 1", len(deliveries), err)
+	}
+	return deliveries[0]
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	db := openTestDB(t)
+	endpoint := newReceiver(t)
+	webhook := createTestWebhook(t, db, endpoint.URL)
+
+	now := time.Now().Add(time.Second)
+	if delivered, err := Deliver(db, endpoint.Client(), now); err != nil || delivered != 1 {
+		t.Fatalf("delivered %d, err %v; want 1", delivered, err)
+	}
+	requests := endpoint.received()
+	if len(requests) != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	delivery := onlyDelivery(t, db, webhook.ID)
+
+	if got := req.header.Get("X-Webhook-Event"); got != models.WebhookUserRegistered {
+		t.Errorf("got event header %q, want %q", got, models.WebhookUserRegistered)
+	}
+	if got := req.header.Get("X-Webhook-Delivery"); got != strconv.FormatInt(delivery.ID, 10) {
+		t.Errorf("got delivery header %q, want %d", got, delivery.ID)
+	}
+
+	// The receiver checks the signature the way the package doc describes
+	var timestamp, signature string
+	for _, part := range strings.Split(req.header.Get("X-Webhook-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("got signature time %q, want %d", timestamp, now.Unix())
+	}
+	unix, _ := strconv.ParseInt(timestamp, 10, 64)
+	if !hmac.Equal([]byte(signature), []byte(Sign(webhook.Secret, unix, req.body))) {
+		t.Error("signature does not match the body")
+	}
+	if hmac.Equal([]byte(signature), []byte(Sign("another secret", unix, req.body))) {
+		t.Error("signature matches a different secret")
+	}
+
+	var envelope models.WebhookEnvelope
+	if err := json.Unmarshal(req.body, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Type != models.WebhookUserRegistered || envelope.ClubID != 1 {
+		t.Errorf("got %s at club %d, want %s at club 1", envelope.Type, envelope.ClubID, models.WebhookUserRegistered)
+	}
+
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("got status %s answered %d, want delivered answered 200", delivery.Status, delivery.ResponseStatus)
+	}
+}
+
+func TestDeliverRetriesWithExponentialBackoff(t *testing.T) {
+	db := openTestDB(t)
+	endpoint := newReceiver(t)
+	endpoint.answer(http.StatusInternalServerError)
+	webhook := createTestWebhook(t, db, endpoint.URL)
+
+	now := time.Now().Add(time.Second)
+	for attempt := 1; attempt <= models.MaxWebhookAttempts; attempt++ {
+		// Not tried again before its retry time
+		if attempt > 1 {
+			if _, err := Deliver(db, endpoint.Client(), now.Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(endpoint.received()); got != attempt-1 {
+				t.Fatalf("attempt %d early: endpoint got %d requests, want %d", attempt, got, attempt-1)
+			}
+		}
+
+		if delivered, err := Deliver(db, endpoint.Client(), now); err != nil || delivered != 0 {
+			t.Fatalf("attempt %d: delivered %d, err %v", attempt, delivered, err)
+		}
+		got := onlyDelivery(t, db, webhook.ID)
+		if got.Attempts != attempt || got.ResponseStatus != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: got %d attempts answered %d", attempt, got.Attempts, got.ResponseStatus)
+		}
+		if strings.Contains(got.LastError, "internal detail") {
+			t.Errorf("attempt %d: response body kept in error %q", attempt, got.LastError)
+		}
+		if attempt == models.MaxWebhookAttempts {
+			if got.Status != models.WebhookDeliveryFailed {
+				t.Errorf("got status %s after %d attempts, want failed", got.Status, attempt)
+			}
+			break
+		}
+		if got.Status != models.WebhookDeliveryPending {
+			t.Fatalf("attempt %d: got status %s, want pending", attempt, got.Status)
+		}
+
+		// Each retry waits twice as long as the one before
+		wait := retryBackoff << uint(attempt-1)
+		if delay := got.NextAttemptAt.Sub(now); delay != wait {
+			t.Errorf("attempt %d: retry in %s, want %s", attempt, delay, wait)
+		}
+		now = got.NextAttemptAt
+	}
+
+	// A failed delivery stays failed once the endpoint is back
+	endpoint.answer(http.StatusOK)
+	if delivered, err := Deliver(db, endpoint.Client(), now.Add(24*time.Hour)); err != nil || delivered != 0 {
+		t.Errorf("after giving up: delivered %d, err %v", delivered, err)
+	}
+	if got := len(endpoint.received()); got != models.MaxWebhookAttempts {
+		t.Errorf("endpoint got %d requests, want %d", got, models.MaxWebhookAttempts)
+	}
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	db := openTestDB(t)
+	endpoint := newReceiver(t)
+	webhook := createTestWebhook(t, db, endpoint.URL)
+
+	now := time.Now().Add(time.Second)
+	if _, err := Deliver(db, endpoint.Client(), now); err != nil {
+		t.Fatal(err)
+	}
+	original := onlyDelivery(t, db, webhook.ID)
+
+	redelivery, err := models.RedeliverWebhook(db, original, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivery.ID == original.ID || redelivery.Status != models.WebhookDeliveryPending {
+		t.Fatalf("got delivery %d with status %s, want a new pending delivery", redelivery.ID, redelivery.Status)
+	}
+	if delivered, err := Deliver(db, endpoint.Client(), now.Add(time.Second)); err != nil || delivered != 1 {
+		t.Fatalf("delivered %d, err %v; want the redelivery", delivered, err)
+	}
+
+	requests := endpoint.received()
+	if len(requests) != 2 || string(requests[1].body) != original.Payload {
+		t.Fatalf("endpoint got %d requests, want the original payload sent again", len(requests))
+	}
+	if got := requests[1].header.Get("X-Webhook-Delivery"); got != strconv.FormatInt(redelivery.ID, 10) {
+		t.Errorf("got delivery header %q, want %d", got, redelivery.ID)
+	}
+	again, err := models.GetWebhookDeliveryByID(db, original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Attempts != original.Attempts || again.Status != original.Status {
+		t.Errorf("original changed to %s after %d attempts", again.Status, again.Attempts)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	db := openTestDB(t)
+	target := newReceiver(t)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+	webhook := createTestWebhook(t, db, redirect.URL)
+
+	// The test servers are on loopback, so keep the redirect rule but not
+	// the address check
+	client := NewClient()
+	client.Transport = http.DefaultTransport
+
+	if delivered, err := Deliver(db, client, time.Now().Add(time.Second)); err != nil || delivered != 0 {
+		t.Fatalf("delivered %d, err %v; want none", delivered, err)
+	}
+	if got := len(target.received()); got != 0 {
+		t.Errorf("redirect target got %d requests, want 0", got)
+	}
+	if got := onlyDelivery(t, db, webhook.ID); got.ResponseStatus != http.StatusFound {
+		t.Errorf("got response status %d, want %d", got.ResponseStatus, http.StatusFound)
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	endpoint := newReceiver(t)
+
+	resp, err := NewClient().Post(endpoint.URL, "application/json", strings.NewReader("{}"))
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got error %v, want %v", err, ErrPrivateAddress)
+	}
+	if got := len(endpoint.received()); got != 0 {
+		t.Errorf("endpoint got %d requests, want 0", got)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:4700:4700::1111]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.100.100.200:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[64:ff9b::a9fe:a9fe]:80", false},
+		{"224.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+	}
+	for _, tt := range tests {
+		err := publicAddress(tt.address)
+		if tt.public && err != nil {
+			t.Errorf("%s: got error %v, want public", tt.address, err)
+		}
+		if !tt.public && err != ErrPrivateAddress {
+			t.Errorf("%s: got error %v, want %v", tt.address, err, ErrPrivateAddress)
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"database/sql"
 	"log"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"pickleball-court/config"
+	"pickleball-court/internal/api"
+	"pickleball-court/internal/email"
 	"pickleball-court/internal/handlers"
+	"pickleball-court/internal/jobs"
 	"pickleball-court/internal/middleware"
 	"pickleball-court/internal/models"
+	"pickleball-court/internal/notify"
+	"pickleball-court/internal/webhook"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -38,6 +47,10 @@ func main() {
 	}
 	defer db.Close()
 
+	// Run background jobs. Only one instance sharing the database runs
+	// them at a time.
+	go newScheduler(db).Run(5 * time.Second)
+
 	// Create necessary directories if they don't exist
 	dirs := []string{"static", "static/css", "static/js", "static/images", "templates"}
 	for _, dir := range dirs {
@@ -62,8 +75,15 @@ func main() {
 	router.POST("/login", handlers.LoginHandler(db))
 	router.GET("/register", handlers.ShowRegisterHandler())
 	router.POST("/register", handlers.RegisterHandler(db))
+	router.POST("/password/forgot", handlers.ForgotPasswordHandler(db))
+	router.POST("/password/reset", handlers.ResetPasswordHandler(db))
 	router.GET("/logout", handlers.LogoutHandler())
 
+	// iCal feeds for calendar apps; personal feeds are secured by the
+	// secret token in their URL
+	router.GET("/calendar/users/:token/bookings.ics", handlers.UserCalendarFeedHandler(db))
+	router.GET("/calendar/courts/:id/bookings.ics", handlers.CourtCalendarFeedHandler(db))
+
 	// Protected routes
 	authorized := router.Group("/")
 	authorized.Use(middleware.AuthRequired())
@@ -72,6 +92,29 @@ func main() {
 		authorized.GET("/profile", handlers.ProfileHandler(db))
 		authorized.POST("/profile/update", handlers.UpdateProfileHandler(db))
 		authorized.POST("/profile/password", handlers.UpdatePasswordHandler(db))
+		authorized.GET("/profile/calendar", handlers.CalendarLinkHandler(db))
+		authorized.POST("/profile/calendar/reset", handlers.ResetCalendarLinkHandler(db))
+
+		// In-app notifications and where each kind is delivered
+		authorized.GET("/notifications", handlers.ListNotificationsHandler(db))
+		authorized.POST("/notifications/read", handlers.MarkAllNotificationsReadHandler(db))
+		authorized.POST("/notifications/:id/read", handlers.MarkNotificationReadHandler(db))
+		authorized.GET("/notifications/preferences", handlers.GetNotificationPreferencesHandler(db))
+		authorized.PUT("/notifications/preferences", handlers.UpdateNotificationPreferencesHandler(db))
+
+		// Check-in for players and front-desk staff
+		authorized.POST("/bookings/:id/checkin", handlers.CheckInBookingHandler(db))
+
+		// A booking as an .ics file to add to a calendar
+		authorized.GET("/bookings/:id/calendar.ics", handlers.BookingCalendarHandler(db))
+
+		// Open play schedule and rotation queue
+		authorized.GET("/open-play", handlers.ListOpenPlayHandler(db))
+		authorized.GET("/open-play/:id/queue", handlers.GetOpenPlayQueueHandler(db))
+		authorized.POST("/open-play/:id/rotate", handlers.RotateOpenPlayHandler(db))
+
+		// Facilities, for picking a venue
+		authorized.GET("/facilities", handlers.ListFacilitiesHandler(db))
 
 		// Admin routes
 		admin := authorized.Group("/admin")
@@ -85,15 +128,80 @@ func main() {
 			admin.PUT("/users/:id", handlers.UpdateUserHandler(db))
 			admin.DELETE("/users/:id", handlers.DeleteUserHandler(db))
 			
+			// Facility management; facility admins can only update their own
+			admin.POST("/facilities", handlers.CreateFacilityHandler(db))
+			admin.PUT("/facilities/:id", handlers.UpdateFacilityHandler(db))
+			admin.DELETE("/facilities/:id", handlers.DeleteFacilityHandler(db))
+
 			// Court management
 			admin.GET("/courts", handlers.ListCourtsHandler(db))
 			admin.POST("/courts", handlers.CreateCourtHandler(db))
 			admin.PUT("/courts/:id", handlers.UpdateCourtHandler(db))
 			admin.DELETE("/courts/:id", handlers.DeleteCourtHandler(db))
+
+			// Court schedules: weekly hours, date overrides and blackouts
+			admin.GET("/courts/:id/hours", handlers.GetCourtHoursHandler(db))
+			admin.PUT("/courts/:id/hours", handlers.UpdateCourtHoursHandler(db))
+			admin.GET("/hours-overrides", handlers.ListHoursOverridesHandler(db))
+			admin.POST("/hours-overrides", handlers.CreateHoursOverrideHandler(db))
+			admin.DELETE("/hours-overrides/:id", handlers.DeleteHoursOverrideHandler(db))
+			admin.GET("/blackouts", handlers.ListBlackoutsHandler(db))
+			admin.POST("/blackouts", handlers.CreateBlackoutHandler(db))
+			admin.POST("/blackouts/preview", handlers.PreviewBlackoutHandler(db))
+			admin.DELETE("/blackouts/:id", handlers.DeleteBlackoutHandler(db))
+
+			// Maintenance windows; end one early by deleting it as a blackout
+			admin.GET("/courts/:id/maintenance", handlers.ListCourtMaintenanceHandler(db))
+			admin.POST("/courts/:id/maintenance", handlers.ScheduleMaintenanceHandler(db))
 			
 			// Booking management
 			admin.GET("/bookings", handlers.ListAllBookingsHandler(db))
+			admin.GET("/bookings/pending", handlers.ListPendingBookingsHandler(db))
+			admin.GET("/bookings/:id/history", handlers.BookingHistoryHandler(db))
 			admin.PUT("/bookings/:id", handlers.UpdateBookingHandler(db))
+			admin.POST("/bookings/:id/cancel", handlers.AdminCancelBookingHandler(db))
+			admin.GET("/cancellations/late", handlers.ListLateCancellationsHandler(db))
+
+			// Late-cancellation penalties; only club-wide admins can change them
+			admin.GET("/penalty-policy", handlers.GetPenaltyPolicyHandler(db))
+			admin.PUT("/penalty-policy", handlers.UpdatePenaltyPolicyHandler(db))
+
+			// No-show tracking; ?facility_id= selects a facility's own policy
+			admin.GET("/no-show-policy", handlers.GetNoShowPolicyHandler(db))
+			admin.PUT("/no-show-policy", handlers.UpdateNoShowPolicyHandler(db))
+			admin.DELETE("/no-show-policy", handlers.DeleteNoShowPolicyHandler(db))
+
+			// Open play
+			admin.POST("/open-play", handlers.CreateOpenPlayHandler(db))
+			admin.DELETE("/open-play/:id", handlers.CancelOpenPlayHandler(db))
+
+			// Outbound webhooks and their delivery log
+			admin.GET("/webhooks", handlers.ListWebhooksHandler(db))
+			admin.POST("/webhooks", handlers.CreateWebhookHandler(db))
+			admin.PUT("/webhooks/:id", handlers.UpdateWebhookHandler(db))
+			admin.DELETE("/webhooks/:id", handlers.DeleteWebhookHandler(db))
+			admin.POST("/webhooks/:id/secret", handlers.RotateWebhookSecretHandler(db))
+			admin.GET("/webhooks/:id/deliveries", handlers.ListWebhookDeliveriesHandler(db))
+			admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", handlers.RedeliverWebhookHandler(db))
+		}
+
+		// Super admin routes, for managing the clubs on this deployment
+		super := authorized.Group("/super")
+		super.Use(middleware.RoleRequired("superadmin"))
+		{
+			super.GET("/clubs", handlers.ListClubsHandler(db))
+			super.POST("/clubs", handlers.CreateClubHandler(db))
+			super.PUT("/clubs/:id", handlers.UpdateClubHandler(db))
+			super.DELETE("/clubs/:id", handlers.DeleteClubHandler(db))
+			super.POST("/clubs/:id/admins", handlers.CreateClubAdminHandler(db))
+
+			// Mark no-shows at every club now rather than waiting for the job
+			super.POST("/bookings/sweep-no-shows", handlers.SweepNoShowsHandler(db))
+
+			// Background jobs
+			super.GET("/jobs", handlers.ListJobsHandler(db))
+			super.GET("/jobs/dashboard", handlers.JobsPageHandler(db))
+			super.POST("/jobs/:id/run", handlers.RunJobHandler(db))
 		}
 
 		// Coach routes
@@ -105,6 +213,8 @@ func main() {
 			coach.POST("/sessions", handlers.CreateTrainingSessionHandler(db))
 			coach.PUT("/sessions/:id", handlers.UpdateTrainingSessionHandler(db))
 			coach.DELETE("/sessions/:id", handlers.DeleteTrainingSessionHandler(db))
+			coach.POST("/open-play", handlers.CreateOpenPlayHandler(db))
+			coach.DELETE("/open-play/:id", handlers.CancelOpenPlayHandler(db))
 		}
 
 		// Player routes
@@ -112,26 +222,84 @@ func main() {
 		player.Use(middleware.RoleRequired("player"))
 		{
 			player.GET("/dashboard", handlers.PlayerDashboardHandler(db))
+			player.GET("/courts", handlers.SearchCourtsHandler(db))
 			player.GET("/courts/availability", handlers.GetCourtAvailabilityHandler(db))
+			player.GET("/courts/availability/stream", handlers.StreamAvailabilityHandler(db))
 			player.POST("/bookings", handlers.CreateBookingHandler(db))
 			player.POST("/bookings/:id/cancel", handlers.CancelBookingHandler(db))
+			player.POST("/bookings/:id/reschedule", handlers.RescheduleBookingHandler(db))
+			player.POST("/bookings/:id/transfer", handlers.TransferBookingHandler(db))
+			player.GET("/bookings/:id/participants", handlers.ListParticipantsHandler(db))
+			player.POST("/bookings/:id/participants", handlers.AddParticipantHandler(db))
+			player.DELETE("/bookings/:id/participants/:participant_id", handlers.RemoveParticipantHandler(db))
+			player.GET("/invitations", handlers.ListInvitationsHandler(db))
+			player.POST("/invitations/:id/accept", handlers.AcceptInvitationHandler(db))
+			player.POST("/invitations/:id/decline", handlers.DeclineInvitationHandler(db))
+			player.GET("/transfers", handlers.ListTransfersHandler(db))
+			player.POST("/transfers/:id/accept", handlers.AcceptTransferHandler(db))
+			player.POST("/transfers/:id/decline", handlers.DeclineTransferHandler(db))
+			player.POST("/transfers/:id/cancel", handlers.CancelTransferHandler(db))
+			player.POST("/holds", handlers.CreateSlotHoldHandler(db))
+			player.POST("/holds/:id/confirm", handlers.ConfirmSlotHoldHandler(db))
+			player.DELETE("/holds/:id", handlers.ReleaseSlotHoldHandler(db))
+			player.POST("/series", handlers.CreateBookingSeriesHandler(db))
+			player.GET("/series/:id", handlers.GetBookingSeriesHandler(db))
+			player.POST("/series/:id/cancel", handlers.CancelBookingSeriesHandler(db))
+			player.GET("/waitlist", handlers.ListWaitlistHandler(db))
+			player.POST("/waitlist", handlers.JoinWaitlistHandler(db))
+			player.POST("/waitlist/:id/claim", handlers.ClaimWaitlistHandler(db))
+			player.DELETE("/waitlist/:id", handlers.LeaveWaitlistHandler(db))
+			player.POST("/open-play/:id/join", handlers.JoinOpenPlayHandler(db))
+			player.POST("/open-play/:id/leave", handlers.LeaveOpenPlayHandler(db))
 			player.POST("/training/:id/enroll", handlers.EnrollTrainingHandler(db))
 			player.POST("/training/:id/cancel", handlers.CancelTrainingEnrollmentHandler(db))
 		}
 	}
 
+	// Versioned JSON API. It shares the web app's session cookie, so
+	// clients sign in through /api/v1/session.
+	v1 := router.Group("/api/v1")
+	{
+		v1.POST("/session", api.LoginHandler(db))
+		v1.DELETE("/session", api.LogoutHandler())
+
+		authed := v1.Group("/")
+		authed.Use(api.AuthRequired())
+		{
+			authed.GET("/me", api.MeHandler())
+			authed.GET("/courts", api.ListCourtsHandler(db))
+			authed.GET("/courts/:id", api.GetCourtHandler(db))
+			authed.GET("/availability", api.AvailabilityHandler(db))
+			authed.GET("/bookings", api.ListBookingsHandler(db))
+			authed.POST("/bookings", api.CreateBookingHandler(db))
+			authed.GET("/bookings/:id", api.GetBookingHandler(db))
+			authed.POST("/bookings/:id/cancel", api.CancelBookingHandler(db))
+			authed.GET("/training-sessions", api.ListTrainingSessionsHandler(db))
+			authed.POST("/training-sessions/:id/enrollment", api.EnrollTrainingHandler(db))
+			authed.DELETE("/training-sessions/:id/enrollment", api.CancelEnrollmentHandler(db))
+			authed.GET("/users", api.ListUsersHandler(db))
+			authed.GET("/users/:id", api.GetUserHandler(db))
+		}
+	}
+
 	// Error handlers
 	router.NoRoute(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			api.NotFoundHandler()(c)
+			return
+		}
 		c.HTML(404, "error.html", gin.H{
 			"title": "Page Not Found",
 			"code":  404,
 		})
 	})
 
-	// Start the server
+	// Start the server. Each request is served for the club named by its
+	// /c/<slug> path prefix or its subdomain of BASE_DOMAIN.
 	port := getEnv("PORT", "8000")
 	log.Printf("Server starting on port %s...\n", port)
-	if err := router.Run(":" + port); err != nil {
+	handler := middleware.Tenant(db, router, getEnv("BASE_DOMAIN", ""))
+	if err := http.ListenAndServe(":"+port, handler); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -143,3 +311,82 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// newScheduler registers the app's background jobs
+func newScheduler(db *sql.DB) *jobs.Scheduler {
+	scheduler := jobs.New(db)
+
+	// Mark missed bookings as no-shows
+	scheduler.Every("sweep-no-shows", 5*time.Minute, sweepNoShows)
+
+	// Pass unclaimed waitlist holds on to the next player in line
+	scheduler.Every("expire-waitlist-holds", time.Minute, expireWaitlistHolds)
+
+	// Clean up checkout holds that were never confirmed
+	scheduler.Every("reap-slot-holds", time.Minute, func(db *sql.DB, now time.Time) error {
+		_, err := models.ReapExpiredSlotHolds(db, now)
+		return err
+	})
+
+	// Deliver queued emails, retrying failures
+	sender := email.NewSender(config.Get().Email)
+	scheduler.Every("deliver-emails", 15*time.Second, func(db *sql.DB, now time.Time) error {
+		_, err := notify.Deliver(db, sender, now)
+		return err
+	})
+
+	// Deliver queued webhook events, retrying failures
+	client := webhook.NewClient()
+	scheduler.Every("deliver-webhooks", 15*time.Second, func(db *sql.DB, now time.Time) error {
+		_, err := webhook.Deliver(db, client, now)
+		return err
+	})
+
+	// Remind players of bookings and training sessions coming up
+	bookingLead, trainingLead := config.Get().GetReminderLeadTimes()
+	scheduler.Every("booking-reminders", time.Minute, func(db *sql.DB, now time.Time) error {
+		_, err := notify.SendBookingReminders(db, now, bookingLead)
+		return err
+	})
+	scheduler.Every("training-reminders", time.Minute, func(db *sql.DB, now time.Time) error {
+		_, err := notify.SendTrainingReminders(db, now, trainingLead)
+		return err
+	})
+
+	// Delete sent emails and expired reset links after a month
+	scheduler.Every("purge-old-emails", 24*time.Hour, func(db *sql.DB, now time.Time) error {
+		_, err := models.PurgeOldEmails(db, now.AddDate(0, 0, -30))
+		return err
+	})
+
+	// Delete webhook deliveries that are done with after a month
+	scheduler.Every("purge-old-webhook-deliveries", 24*time.Hour, func(db *sql.DB, now time.Time) error {
+		_, err := models.PurgeOldWebhookDeliveries(db, now.AddDate(0, 0, -30))
+		return err
+	})
+
+	return scheduler
+}
+
+// sweepNoShows releases courts held by players who never checked in
+func sweepNoShows(db *sql.DB, now time.Time) error {
+	result, err := models.SweepNoShows(db, now)
+	if err != nil {
+		return err
+	}
+	if result.NoShows > 0 || result.Completed > 0 {
+		log.Printf("No-show sweep: %d no-shows, %d completed, %d restrictions\n",
+			result.NoShows, result.Completed, result.Restrictions)
+	}
+	return nil
+}
+
+// expireWaitlistHolds expires waitlist holds that were not claimed
+func expireWaitlistHolds(db *sql.DB, now time.Time) error {
+	offers, err := models.ExpireWaitlistHolds(db, now)
+	if err != nil {
+		return err
+	}
+	handlers.NotifyWaitlistOffers(db, offers)
+	return nil
+}
@@ -1,3 +1,29 @@
+-- Clubs table; each club is a tenant reached by subdomain or /c/<slug>
+CREATE TABLE IF NOT EXISTS clubs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    brand_color VARCHAR(7) NOT NULL DEFAULT '',
+    logo_url TEXT NOT NULL DEFAULT '',
+    contact_email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Facilities table
+CREATE TABLE IF NOT EXISTS facilities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    club_id INTEGER REFERENCES clubs(id),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    opening_hour INTEGER,
+    closing_hour INTEGER,
+    max_days_ahead INTEGER,
+    min_hours_advance INTEGER,
+    max_hours_per_week INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Users table
 CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -5,6 +31,10 @@ CREATE TABLE IF NOT EXISTS users (
     password VARCHAR(255) NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
     role VARCHAR(20) NOT NULL,
+    trusted BOOLEAN NOT NULL DEFAULT 0,
+    skill_level REAL NOT NULL DEFAULT 0,
+    club_id INTEGER REFERENCES clubs(id),
+    facility_id INTEGER REFERENCES facilities(id),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -14,6 +44,14 @@ CREATE TABLE IF NOT EXISTS courts (
     name VARCHAR(100) NOT NULL,
     description TEXT,
     status VARCHAR(20) NOT NULL,
+    max_booking_minutes INTEGER NOT NULL DEFAULT 120,
+    auto_confirm BOOLEAN NOT NULL DEFAULT 0,
+    indoor BOOLEAN NOT NULL DEFAULT 0,
+    surface VARCHAR(20) NOT NULL DEFAULT '',
+    lighting BOOLEAN NOT NULL DEFAULT 0,
+    lines VARCHAR(20) NOT NULL DEFAULT '',
+    accessible BOOLEAN NOT NULL DEFAULT 0,
+    facility_id INTEGER REFERENCES facilities(id),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -26,11 +64,273 @@ CREATE TABLE IF NOT EXISTS bookings (
     end_time TIMESTAMP NOT NULL,
     status VARCHAR(20) NOT NULL,
     booking_type VARCHAR(20) NOT NULL,
+    series_id INTEGER,
+    checked_in_at TIMESTAMP,
+    checked_in_by INTEGER,
+    reminder_sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (court_id) REFERENCES courts(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (series_id) REFERENCES booking_series(id)
+);
+
+-- Recurring booking series table
+CREATE TABLE IF NOT EXISTS booking_series (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    court_id INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    frequency VARCHAR(20) NOT NULL,
+    until_date TIMESTAMP,
+    occurrences INTEGER,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (court_id) REFERENCES courts(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Reject overlapping bookings on the same court
+CREATE TRIGGER IF NOT EXISTS bookings_no_overlap_insert
+BEFORE INSERT ON bookings
+WHEN NEW.status NOT IN ('cancelled', 'rejected', 'no_show')
+BEGIN
+    SELECT RAISE(ABORT, 'booking overlaps an existing booking')
+    WHERE EXISTS (
+        SELECT 1 FROM bookings
+        WHERE court_id = NEW.court_id
+        AND status NOT IN ('cancelled', 'rejected', 'no_show')
+        AND julianday(start_time) < julianday(NEW.end_time)
+        AND julianday(end_time) > julianday(NEW.start_time)
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookings_no_overlap_update
+BEFORE UPDATE OF court_id, start_time, end_time, status ON bookings
+WHEN NEW.status NOT IN ('cancelled', 'rejected', 'no_show')
+BEGIN
+    SELECT RAISE(ABORT, 'booking overlaps an existing booking')
+    WHERE EXISTS (
+        SELECT 1 FROM bookings
+        WHERE court_id = NEW.court_id
+        AND id != NEW.id
+        AND status NOT IN ('cancelled', 'rejected', 'no_show')
+        AND julianday(start_time) < julianday(NEW.end_time)
+        AND julianday(end_time) > julianday(NEW.start_time)
+    );
+END;
+
+-- Booking status history
+CREATE TABLE IF NOT EXISTS booking_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id),
+    FOREIGN KEY (changed_by) REFERENCES users(id)
+);
+
+-- Booking cancellations, including late ones
+CREATE TABLE IF NOT EXISTS booking_cancellations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    cancelled_by INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    late BOOLEAN NOT NULL DEFAULT 0,
+    waived BOOLEAN NOT NULL DEFAULT 0,
+    cancelled_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (cancelled_by) REFERENCES users(id)
+);
+
+-- Late-cancellation penalty policy (single row)
+CREATE TABLE IF NOT EXISTS penalty_policy (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    strike_limit INTEGER NOT NULL,
+    strike_window_days INTEGER NOT NULL,
+    penalty VARCHAR(20) NOT NULL,
+    suspension_days INTEGER NOT NULL DEFAULT 0,
+    forfeit_credits INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- No-show policy (single row)
+CREATE TABLE IF NOT EXISTS no_show_policy (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    strike_limit INTEGER NOT NULL,
+    strike_window_days INTEGER NOT NULL,
+    restriction_days INTEGER NOT NULL,
+    max_hours_per_week INTEGER NOT NULL DEFAULT 0,
+    max_days_ahead INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Penalties applied to users
+CREATE TABLE IF NOT EXISTS user_penalties (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    penalty VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    credits INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Players on a booking besides the booker; user_id is NULL for guests
+CREATE TABLE IF NOT EXISTS booking_participants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL,
+    user_id INTEGER,
+    guest_name VARCHAR(100) NOT NULL DEFAULT '',
+    guest_email VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    invited_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (invited_by) REFERENCES users(id)
+);
+
+-- Audit trail of booking reschedules and transfers
+CREATE TABLE IF NOT EXISTS booking_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL,
+    action VARCHAR(30) NOT NULL,
+    changed_by INTEGER NOT NULL,
+    old_court_id INTEGER,
+    new_court_id INTEGER,
+    old_start_time TIMESTAMP,
+    new_start_time TIMESTAMP,
+    old_end_time TIMESTAMP,
+    new_end_time TIMESTAMP,
+    old_user_id INTEGER,
+    new_user_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Booking handovers between players, pending until the recipient accepts
+CREATE TABLE IF NOT EXISTS booking_transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL,
+    from_user_id INTEGER NOT NULL,
+    to_user_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id),
+    FOREIGN KEY (from_user_id) REFERENCES users(id),
+    FOREIGN KEY (to_user_id) REFERENCES users(id)
+);
+
+-- Short-lived slot holds taken while a player confirms a booking
+CREATE TABLE IF NOT EXISTS slot_holds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    court_id INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (court_id) REFERENCES courts(id)
+);
+
+-- Waitlist for fully-booked slots. court_id is NULL for any court.
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    court_id INTEGER,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    offered_court_id INTEGER,
+    offered_at TIMESTAMP,
+    hold_expires_at TIMESTAMP,
+    booking_id INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (court_id) REFERENCES courts(id),
+    FOREIGN KEY (offered_court_id) REFERENCES courts(id),
+    FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Open play sessions, each reserving its court through an open_play booking
+CREATE TABLE IF NOT EXISTS open_play_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    booking_id INTEGER NOT NULL UNIQUE,
+    created_by INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    capacity INTEGER NOT NULL,
+    min_skill REAL NOT NULL DEFAULT 0,
+    max_skill REAL NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Open play sign-ups; queued_at orders the rotation queue
+CREATE TABLE IF NOT EXISTS open_play_signups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    on_court BOOLEAN NOT NULL DEFAULT 0,
+    queued_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES open_play_sessions(id),
     FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
+-- Weekly opening hours per court; weekdays without a row use the defaults
+CREATE TABLE IF NOT EXISTS court_hours (
+    court_id INTEGER NOT NULL,
+    weekday INTEGER NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at VARCHAR(5) NOT NULL,
+    closes_at VARCHAR(5) NOT NULL,
+    closed BOOLEAN NOT NULL DEFAULT 0,
+    PRIMARY KEY (court_id, weekday),
+    FOREIGN KEY (court_id) REFERENCES courts(id)
+);
+
+-- Date-specific opening hours for holidays and events. court_id is NULL
+-- for every court in the club.
+CREATE TABLE IF NOT EXISTS court_hours_overrides (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    club_id INTEGER REFERENCES clubs(id),
+    court_id INTEGER,
+    date VARCHAR(10) NOT NULL,
+    opens_at VARCHAR(5) NOT NULL DEFAULT '',
+    closes_at VARCHAR(5) NOT NULL DEFAULT '',
+    closed BOOLEAN NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (court_id) REFERENCES courts(id)
+);
+
+-- Blackout windows closing a court, or every court in the club when
+-- court_id is NULL
+CREATE TABLE IF NOT EXISTS court_blackouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    club_id INTEGER REFERENCES clubs(id),
+    court_id INTEGER,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    reason TEXT NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'closure',
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (court_id) REFERENCES courts(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
 -- Training Sessions table
 CREATE TABLE IF NOT EXISTS training_sessions (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -50,19 +350,102 @@ CREATE TABLE IF NOT EXISTS training_sessions (
 CREATE TABLE IF NOT EXISTS training_session_participants (
     session_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
+    reminder_sent_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (session_id, user_id),
     FOREIGN KEY (session_id) REFERENCES training_sessions(id),
     FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
+-- Calendar Tokens table: the secret in each user's personal iCal feed URL
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id INTEGER PRIMARY KEY,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Email Queue table: the outbox of emails waiting to be sent
+CREATE TABLE IF NOT EXISTS email_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+-- Password Resets table: hashes of emailed reset tokens
+CREATE TABLE IF NOT EXISTS password_resets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Notifications table: each user's in-app inbox
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Notification Preferences table: where each user wants each kind of
+-- notification delivered; no row means both inbox and email
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    inbox BOOLEAN NOT NULL DEFAULT 1,
+    email BOOLEAN NOT NULL DEFAULT 1,
+    PRIMARY KEY (user_id, kind),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Jobs table: recurring background tasks and when each is next due
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    run_at TIMESTAMP NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Scheduler Leases table: the instance allowed to run jobs
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Insert the first club
+INSERT OR IGNORE INTO clubs (slug, name) VALUES ('main', 'Main Club');
+
 -- Insert default admin user
-INSERT OR IGNORE INTO users (username, password, email, role) 
-VALUES ('admin', '$2a$10$JmZ7EQj/r8bQqIGvj.oX6.TZJ3iBcKY7DgNHHFV.1UZqD8bJgv2Uy', 'admin@picklecourt.com', 'admin');
-
--- Insert some sample courts
-INSERT OR IGNORE INTO courts (name, description, status) VALUES
-('Court 1', 'Indoor court with professional lighting', 'available'),
-('Court 2', 'Outdoor court with shade coverage', 'available'),
-('Court 3', 'Indoor climate-controlled court', 'available'),
-('Court 4', 'Tournament-ready outdoor court', 'available');
+INSERT OR IGNORE INTO users (username, password, email, role, club_id) 
+VALUES ('admin', '$2a$10$JmZ7EQj/r8bQqIGvj.oX6.TZJ3iBcKY7DgNHHFV.1UZqD8bJgv2Uy', 'admin@picklecourt.com', 'admin', 1);
+
+-- Insert a facility and some sample courts
+INSERT OR IGNORE INTO facilities (name, club_id) VALUES ('Main', 1);
+
+INSERT OR IGNORE INTO courts (name, description, status, indoor, surface, lighting, lines, accessible, facility_id) VALUES
+('Court 1', 'Indoor court with professional lighting', 'available', 1, 'wood', 1, 'temporary', 1, 1),
+('Court 2', 'Outdoor court with shade coverage', 'available', 0, 'acrylic', 0, 'permanent', 1, 1),
+('Court 3', 'Indoor climate-controlled court', 'available', 1, 'modular', 1, 'permanent', 0, 1),
+('Court 4', 'Tournament-ready outdoor court', 'available', 0, 'acrylic', 1, 'permanent', 1, 1);